@@ -0,0 +1,206 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/sachanritik1/go-lang/internal/middleware"
+	"github.com/sachanritik1/go-lang/internal/store"
+	"github.com/sachanritik1/go-lang/internal/utils"
+)
+
+type ExerciseHandler struct {
+	store  store.ExerciseStore
+	logger *log.Logger
+}
+
+func NewExerciseHandler(store store.ExerciseStore, logger *log.Logger) *ExerciseHandler {
+	return &ExerciseHandler{store: store, logger: logger}
+}
+
+type ExerciseRequest struct {
+	Name         string   `json:"name"`
+	MuscleGroups []string `json:"muscle_groups"`
+	Equipment    string   `json:"equipment"`
+	MovementType string   `json:"movement_type"`
+	Aliases      []string `json:"aliases"`
+}
+
+func (req *ExerciseRequest) validate() utils.FieldErrors {
+	fe := utils.FieldErrors{}
+	req.Name = strings.Join(strings.Fields(req.Name), " ")
+	if req.Name == "" {
+		fe.Add("name", "is required")
+	} else if len(req.Name) > 100 {
+		fe.Add("name", "must not be more than 100 characters")
+	}
+	if len(req.Equipment) > 50 {
+		fe.Add("equipment", "must not be more than 50 characters")
+	}
+	if req.MovementType == "" {
+		req.MovementType = store.MovementReps
+	}
+	if !store.IsValidMovementType(req.MovementType) {
+		fe.Add("movement_type", "must be reps or timed")
+	}
+	return fe
+}
+
+// HandlerListExercises returns the built-in catalog and the caller's custom
+// exercises; ?q= narrows it to names or aliases containing the query.
+func (h *ExerciseHandler) HandlerListExercises(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	exercises, err := h.store.ListExercises(r.Context(), user.ID, r.URL.Query().Get("q"))
+	if err != nil {
+		h.logger.Printf("ERROR: listing exercises: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not retrieve exercises"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"exercises": exercises})
+}
+
+func (h *ExerciseHandler) HandlerGetExerciseByID(w http.ResponseWriter, r *http.Request) {
+	exercise, ok := h.readVisibleExercise(w, r)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"exercise": exercise})
+}
+
+func (h *ExerciseHandler) HandlerCreateExercise(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	var req ExerciseRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding create exercise request: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+	if fieldErrors := req.validate(); len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	exercise := &store.Exercise{
+		UserID:       &user.ID,
+		Name:         req.Name,
+		MuscleGroups: req.MuscleGroups,
+		Equipment:    req.Equipment,
+		MovementType: req.MovementType,
+		Aliases:      req.Aliases,
+	}
+	err = h.store.CreateExercise(r.Context(), exercise)
+	if errors.Is(err, store.ErrDuplicateExercise) {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: creating exercise: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not create exercise"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"exercise": exercise})
+}
+
+func (h *ExerciseHandler) HandlerUpdateExercise(w http.ResponseWriter, r *http.Request) {
+	exercise, ok := h.readOwnedExercise(w, r)
+	if !ok {
+		return
+	}
+
+	var req ExerciseRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding update exercise request: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+	if fieldErrors := req.validate(); len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	exercise.Name = req.Name
+	exercise.MuscleGroups = req.MuscleGroups
+	exercise.Equipment = req.Equipment
+	exercise.MovementType = req.MovementType
+	exercise.Aliases = req.Aliases
+
+	err = h.store.UpdateExercise(r.Context(), exercise)
+	if errors.Is(err, store.ErrDuplicateExercise) {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: updating exercise: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not update exercise"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"exercise": exercise})
+}
+
+func (h *ExerciseHandler) HandlerDeleteExercise(w http.ResponseWriter, r *http.Request) {
+	exercise, ok := h.readOwnedExercise(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.store.DeleteExercise(r.Context(), exercise.ID)
+	if errors.Is(err, store.ErrExerciseInUse) {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: deleting exercise: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not delete exercise"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "exercise deleted successfully"})
+}
+
+// readVisibleExercise loads the {id} exercise if it is built-in or the caller's
+// own; other users' custom exercises are reported as not found.
+func (h *ExerciseHandler) readVisibleExercise(w http.ResponseWriter, r *http.Request) (*store.Exercise, bool) {
+	exerciseID, err := utils.ReadIDParam(r)
+	if err != nil {
+		h.logger.Printf("ERROR: reading ID parameter: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid exercise ID parameter"})
+		return nil, false
+	}
+
+	exercise, err := h.store.GetExerciseByID(r.Context(), exerciseID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		h.logger.Printf("ERROR: getting exercise by ID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not retrieve exercise"})
+		return nil, false
+	}
+	if exercise == nil || (exercise.IsCustom() && *exercise.UserID != middleware.GetUser(r).ID) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "exercise not found"})
+		return nil, false
+	}
+	return exercise, true
+}
+
+// readOwnedExercise is readVisibleExercise for writes: the built-in catalog is read-only.
+func (h *ExerciseHandler) readOwnedExercise(w http.ResponseWriter, r *http.Request) (*store.Exercise, bool) {
+	exercise, ok := h.readVisibleExercise(w, r)
+	if !ok {
+		return nil, false
+	}
+	if !exercise.IsCustom() {
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "built-in exercises cannot be changed"})
+		return nil, false
+	}
+	return exercise, true
+}
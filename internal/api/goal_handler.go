@@ -0,0 +1,299 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sachanritik1/go-lang/internal/middleware"
+	"github.com/sachanritik1/go-lang/internal/store"
+	"github.com/sachanritik1/go-lang/internal/units"
+	"github.com/sachanritik1/go-lang/internal/utils"
+)
+
+type GoalHandler struct {
+	store  store.GoalStore
+	logger *log.Logger
+}
+
+func NewGoalHandler(store store.GoalStore, logger *log.Logger) *GoalHandler {
+	return &GoalHandler{store: store, logger: logger}
+}
+
+// UpdateGoalRequest changes the fields sent. An empty target_date clears it;
+// status can only be set to active or abandoned, the rest follow from progress.
+type UpdateGoalRequest struct {
+	Title       *string  `json:"title"`
+	TargetValue *float64 `json:"target_value"`
+	WeightUnit  string   `json:"weight_unit"`
+	TargetDate  *string  `json:"target_date"`
+	Status      *string  `json:"status"`
+}
+
+func validateGoal(fe utils.FieldErrors, goal *store.Goal) {
+	goal.Title = strings.TrimSpace(goal.Title)
+	if len(goal.Title) > 100 {
+		fe.Add("title", "must not be more than 100 characters")
+	}
+	if !store.IsValidGoalType(goal.GoalType) {
+		fe.Add("goal_type", "must be one of strength, frequency, volume, body_weight")
+		return
+	}
+	switch goal.GoalType {
+	case store.GoalStrength:
+		if goal.ExerciseID == nil {
+			fe.Add("exercise_id", "is required for a strength goal")
+		}
+	case store.GoalFrequency, store.GoalBodyWeight:
+		if goal.ExerciseID != nil {
+			fe.Add("exercise_id", "must not be set for a "+goal.GoalType+" goal")
+		}
+	}
+	if goal.GoalType == store.GoalFrequency && goal.TargetDate == nil {
+		fe.Add("target_date", "is required for a frequency goal")
+	}
+	if goal.TargetValue <= 0 {
+		fe.Add("target_value", "must be positive")
+	} else if goal.GoalType == store.GoalFrequency && goal.TargetValue > 14 {
+		fe.Add("target_value", "must not be more than 14 workouts per week")
+	}
+	if goal.StartValue != nil && *goal.StartValue <= 0 {
+		fe.Add("start_value", "must be positive")
+	}
+	if goal.WeightUnit != "" && !units.IsValidWeightUnit(goal.WeightUnit) {
+		fe.Add("weight_unit", "must be kg or lb")
+	}
+	validateGoalDates(fe, goal)
+}
+
+func validateGoalDates(fe utils.FieldErrors, goal *store.Goal) {
+	if _, err := time.Parse(time.DateOnly, goal.StartDate); err != nil {
+		fe.Add("start_date", "must be a YYYY-MM-DD date")
+		return
+	}
+	if goal.TargetDate == nil {
+		return
+	}
+	if _, err := time.Parse(time.DateOnly, *goal.TargetDate); err != nil {
+		fe.Add("target_date", "must be a YYYY-MM-DD date")
+	} else if *goal.TargetDate < goal.StartDate {
+		fe.Add("target_date", "must not be before start_date")
+	}
+}
+
+// HandlerListGoals returns the caller's goals with their progress, optionally
+// only those with ?status=.
+func (h *GoalHandler) HandlerListGoals(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	fieldErrors := utils.FieldErrors{}
+	system := readUnitSystem(r, user, fieldErrors)
+	status := r.URL.Query().Get("status")
+	if status != "" && !store.IsValidGoalStatus(status) {
+		fieldErrors.Add("status", "must be one of active, achieved, missed, abandoned")
+	}
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	goals, err := h.store.ListGoals(r.Context(), user.ID, status, userToday(user, time.Now()))
+	if err != nil {
+		h.logger.Printf("ERROR: listing goals: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not retrieve goals"})
+		return
+	}
+
+	goalsToResponseUnits(goals, system)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"goals": goals})
+}
+
+// HandlerCreateGoal sets a new goal starting today unless start_date says otherwise.
+func (h *GoalHandler) HandlerCreateGoal(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	var goal store.Goal
+	err := json.NewDecoder(r.Body).Decode(&goal)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding create goal request: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+	goal.UserID = user.ID
+	today := userToday(user, time.Now())
+	if goal.StartDate == "" {
+		goal.StartDate = today.Format(time.DateOnly)
+	}
+
+	fieldErrors := utils.FieldErrors{}
+	system := readUnitSystem(r, user, fieldErrors)
+	validateGoal(fieldErrors, &goal)
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+	goalToCanonicalUnits(&goal, system)
+
+	err = h.store.CreateGoal(r.Context(), &goal)
+	if errors.Is(err, store.ErrUnknownExercise) {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, utils.FieldErrors{"exercise_id": {"must be a known exercise"}})
+		return
+	}
+	if errors.Is(err, store.ErrNoBodyWeight) {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, utils.FieldErrors{"start_value": {"is required until a body weight was logged"}})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: creating goal: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not create goal"})
+		return
+	}
+
+	created, err := h.store.GetGoalByID(r.Context(), goal.ID, today)
+	if err != nil {
+		h.logger.Printf("ERROR: getting created goal: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not retrieve goal"})
+		return
+	}
+
+	goalsToResponseUnits([]*store.Goal{created}, system)
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"goal": created})
+}
+
+// HandlerGetGoalByID returns the goal with its progress and status history.
+func (h *GoalHandler) HandlerGetGoalByID(w http.ResponseWriter, r *http.Request) {
+	goal, ok := h.readOwnedGoal(w, r)
+	if !ok {
+		return
+	}
+
+	fieldErrors := utils.FieldErrors{}
+	system := readUnitSystem(r, middleware.GetUser(r), fieldErrors)
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	goalsToResponseUnits([]*store.Goal{goal}, system)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"goal": goal})
+}
+
+func (h *GoalHandler) HandlerUpdateGoal(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	goal, ok := h.readOwnedGoal(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateGoalRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding update goal request: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	fieldErrors := utils.FieldErrors{}
+	system := readUnitSystem(r, user, fieldErrors)
+	if req.Title != nil {
+		goal.Title = *req.Title
+	}
+	if req.TargetValue != nil {
+		goal.TargetValue = *req.TargetValue
+		if goal.IsWeightGoal() {
+			unit := req.WeightUnit
+			if unit == "" {
+				unit = units.WeightUnit(system)
+			}
+			if !units.IsValidWeightUnit(unit) {
+				fieldErrors.Add("weight_unit", "must be kg or lb")
+			}
+			goal.TargetValue = units.ToKilograms(goal.TargetValue, unit)
+		}
+	}
+	if req.TargetDate != nil {
+		goal.TargetDate = req.TargetDate
+		if *req.TargetDate == "" {
+			goal.TargetDate = nil
+		}
+	}
+	if req.Status != nil {
+		if *req.Status != store.GoalActive && *req.Status != store.GoalAbandoned {
+			fieldErrors.Add("status", "must be active or abandoned")
+		}
+		goal.Status = *req.Status
+	}
+	// everything was checked in kilograms when the goal was created
+	goal.StartValue, goal.WeightUnit = nil, ""
+	validateGoal(fieldErrors, goal)
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	err = h.store.UpdateGoal(r.Context(), goal)
+	if err != nil {
+		h.logger.Printf("ERROR: updating goal: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not update goal"})
+		return
+	}
+
+	// reactivated goals are evaluated again straight away
+	updated, err := h.store.GetGoalByID(r.Context(), goal.ID, userToday(user, time.Now()))
+	if err != nil {
+		h.logger.Printf("ERROR: getting updated goal: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not retrieve goal"})
+		return
+	}
+
+	goalsToResponseUnits([]*store.Goal{updated}, system)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"goal": updated})
+}
+
+func (h *GoalHandler) HandlerDeleteGoal(w http.ResponseWriter, r *http.Request) {
+	goal, ok := h.readOwnedGoal(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.store.DeleteGoal(r.Context(), goal.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: deleting goal: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not delete goal"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "goal deleted successfully"})
+}
+
+func (h *GoalHandler) readOwnedGoal(w http.ResponseWriter, r *http.Request) (*store.Goal, bool) {
+	goalID, err := utils.ReadIDParam(r)
+	if err != nil {
+		h.logger.Printf("ERROR: reading ID parameter: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid goal ID parameter"})
+		return nil, false
+	}
+
+	user := middleware.GetUser(r)
+	goal, err := h.store.GetGoalByID(r.Context(), goalID, userToday(user, time.Now()))
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "goal not found"})
+		return nil, false
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: getting goal by ID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not retrieve goal"})
+		return nil, false
+	}
+
+	if goal.UserID != user.ID {
+		h.logger.Printf("ERROR: user %d trying to access goal owned by user %d", user.ID, goal.UserID)
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "you do not have permission to access this goal"})
+		return nil, false
+	}
+	return goal, true
+}
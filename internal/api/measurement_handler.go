@@ -0,0 +1,339 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sachanritik1/go-lang/internal/middleware"
+	"github.com/sachanritik1/go-lang/internal/store"
+	"github.com/sachanritik1/go-lang/internal/utils"
+)
+
+const (
+	defaultMovingAverageDays = 7
+	maxMovingAverageDays     = 90
+)
+
+type MeasurementHandler struct {
+	store  store.MeasurementStore
+	logger *log.Logger
+}
+
+func NewMeasurementHandler(store store.MeasurementStore, logger *log.Logger) *MeasurementHandler {
+	return &MeasurementHandler{store: store, logger: logger}
+}
+
+// MeasurementRequest creates or partially updates a measurement. Value is in
+// Unit, or in the user's units when Unit is left out.
+type MeasurementRequest struct {
+	Kind       *string    `json:"kind"`
+	Value      *float64   `json:"value"`
+	Unit       *string    `json:"unit"`
+	MeasuredAt *time.Time `json:"measured_at"`
+	Notes      *string    `json:"notes"`
+}
+
+// apply validates req and copies it onto measurement. A value sent without a
+// unit is taken in the user's units; changing the kind requires a new value.
+func (req *MeasurementRequest) apply(fe utils.FieldErrors, measurement *store.Measurement, system string) {
+	if req.Kind != nil {
+		if !store.IsValidMeasurementKind(*req.Kind) {
+			fe.Add("kind", "must be one of "+strings.Join(store.MeasurementKinds, ", "))
+			return
+		}
+		if *req.Kind != measurement.Kind && measurement.Kind != "" && req.Value == nil {
+			fe.Add("value", "is required when changing the kind")
+		}
+		measurement.Kind = *req.Kind
+	}
+	if measurement.Kind == "" {
+		fe.Add("kind", "is required")
+		return
+	}
+
+	if req.Value != nil {
+		unit := measurementUnit(measurement.Kind, system)
+		if req.Unit != nil {
+			unit = *req.Unit
+		}
+		switch {
+		case !isValidMeasurementUnit(measurement.Kind, unit):
+			fe.Add("unit", "does not fit a "+measurement.Kind+" measurement")
+		case *req.Value <= 0:
+			fe.Add("value", "must be positive")
+		case measurement.Kind == store.MeasurementBodyFat && *req.Value > 100:
+			fe.Add("value", "must not be more than 100 percent")
+		default:
+			measurement.Value = measurementToCanonicalUnits(measurement.Kind, *req.Value, unit)
+		}
+	} else if measurement.ID == 0 {
+		fe.Add("value", "is required")
+	}
+
+	if req.MeasuredAt != nil {
+		if req.MeasuredAt.After(time.Now().Add(time.Minute)) {
+			fe.Add("measured_at", "must not be in the future")
+		}
+		measurement.MeasuredAt = *req.MeasuredAt
+	}
+	if req.Notes != nil {
+		if len(*req.Notes) > 500 {
+			fe.Add("notes", "must not be more than 500 characters")
+		}
+		measurement.Notes = *req.Notes
+	}
+}
+
+// readMeasurementFilter parses ?kind=&from=&to=; from and to are RFC 3339
+// timestamps or plain YYYY-MM-DD days, and to is exclusive.
+func readMeasurementFilter(qs url.Values, fe utils.FieldErrors) store.MeasurementFilter {
+	filter := store.MeasurementFilter{Kind: qs.Get("kind")}
+	if filter.Kind != "" && !store.IsValidMeasurementKind(filter.Kind) {
+		fe.Add("kind", "must be one of "+strings.Join(store.MeasurementKinds, ", "))
+	}
+
+	for key, t := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		v := qs.Get(key)
+		if v == "" {
+			continue
+		}
+		parsed := false
+		for _, layout := range []string{time.RFC3339, time.DateOnly} {
+			if value, err := time.Parse(layout, v); err == nil {
+				*t, parsed = &value, true
+				break
+			}
+		}
+		if !parsed {
+			fe.Add(key, "must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+		}
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		fe.Add("to", "must be after from")
+	}
+	return filter
+}
+
+// HandlerListMeasurements returns the caller's measurements, newest first.
+func (h *MeasurementHandler) HandlerListMeasurements(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	fieldErrors := utils.FieldErrors{}
+	system := readUnitSystem(r, user, fieldErrors)
+	filter := readMeasurementFilter(r.URL.Query(), fieldErrors)
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	measurements, err := h.store.ListMeasurements(r.Context(), user.ID, filter)
+	if err != nil {
+		h.logger.Printf("ERROR: listing measurements: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not retrieve measurements"})
+		return
+	}
+
+	measurementsToResponseUnits(measurements, system)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"measurements": measurements})
+}
+
+// HandlerGetLatestMeasurements returns the most recent measurement of every kind.
+func (h *MeasurementHandler) HandlerGetLatestMeasurements(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	fieldErrors := utils.FieldErrors{}
+	system := readUnitSystem(r, user, fieldErrors)
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	measurements, err := h.store.GetLatestMeasurements(r.Context(), user.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting latest measurements: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not retrieve measurements"})
+		return
+	}
+
+	measurementsToResponseUnits(measurements, system)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"measurements": measurements})
+}
+
+// HandlerGetMeasurementSeries returns the ?kind= measurements in the range,
+// oldest first, with their moving average over the preceding ?window= days.
+func (h *MeasurementHandler) HandlerGetMeasurementSeries(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	qs := r.URL.Query()
+
+	fieldErrors := utils.FieldErrors{}
+	system := readUnitSystem(r, user, fieldErrors)
+	filter := readMeasurementFilter(qs, fieldErrors)
+	if filter.Kind == "" {
+		fieldErrors.Add("kind", "is required")
+	}
+	window := defaultMovingAverageDays
+	if v := qs.Get("window"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxMovingAverageDays {
+			fieldErrors.Add("window", "must be a number of days between 1 and 90")
+		}
+		window = n
+	}
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	points, err := h.store.GetMeasurementSeries(r.Context(), user.ID, filter, window)
+	if err != nil {
+		h.logger.Printf("ERROR: getting measurement series: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not retrieve measurements"})
+		return
+	}
+
+	unit := measurementUnit(filter.Kind, system)
+	for _, point := range points {
+		point.Value = measurementFromCanonicalUnits(filter.Kind, point.Value, unit)
+		point.MovingAverage = measurementFromCanonicalUnits(filter.Kind, point.MovingAverage, unit)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{
+		"kind":        filter.Kind,
+		"unit":        unit,
+		"window_days": window,
+		"points":      points,
+	})
+}
+
+func (h *MeasurementHandler) HandlerCreateMeasurement(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	var req MeasurementRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding create measurement request: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	measurement := store.Measurement{UserID: user.ID}
+	fieldErrors := utils.FieldErrors{}
+	system := readUnitSystem(r, user, fieldErrors)
+	req.apply(fieldErrors, &measurement, system)
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	err = h.store.CreateMeasurement(r.Context(), &measurement)
+	if err != nil {
+		h.logger.Printf("ERROR: creating measurement: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not create measurement"})
+		return
+	}
+
+	measurementsToResponseUnits([]*store.Measurement{&measurement}, system)
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"measurement": measurement})
+}
+
+func (h *MeasurementHandler) HandlerGetMeasurementByID(w http.ResponseWriter, r *http.Request) {
+	measurement, ok := h.readOwnedMeasurement(w, r)
+	if !ok {
+		return
+	}
+
+	fieldErrors := utils.FieldErrors{}
+	system := readUnitSystem(r, middleware.GetUser(r), fieldErrors)
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	measurementsToResponseUnits([]*store.Measurement{measurement}, system)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"measurement": measurement})
+}
+
+// HandlerUpdateMeasurement changes the fields sent and keeps the rest.
+func (h *MeasurementHandler) HandlerUpdateMeasurement(w http.ResponseWriter, r *http.Request) {
+	measurement, ok := h.readOwnedMeasurement(w, r)
+	if !ok {
+		return
+	}
+
+	var req MeasurementRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding update measurement request: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	fieldErrors := utils.FieldErrors{}
+	system := readUnitSystem(r, middleware.GetUser(r), fieldErrors)
+	req.apply(fieldErrors, measurement, system)
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	err = h.store.UpdateMeasurement(r.Context(), measurement)
+	if err != nil {
+		h.logger.Printf("ERROR: updating measurement: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not update measurement"})
+		return
+	}
+
+	measurementsToResponseUnits([]*store.Measurement{measurement}, system)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"measurement": measurement})
+}
+
+func (h *MeasurementHandler) HandlerDeleteMeasurement(w http.ResponseWriter, r *http.Request) {
+	measurement, ok := h.readOwnedMeasurement(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.store.DeleteMeasurement(r.Context(), measurement.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: deleting measurement: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not delete measurement"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "measurement deleted successfully"})
+}
+
+func (h *MeasurementHandler) readOwnedMeasurement(w http.ResponseWriter, r *http.Request) (*store.Measurement, bool) {
+	measurementID, err := utils.ReadIDParam(r)
+	if err != nil {
+		h.logger.Printf("ERROR: reading ID parameter: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid measurement ID parameter"})
+		return nil, false
+	}
+
+	measurement, err := h.store.GetMeasurementByID(r.Context(), measurementID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "measurement not found"})
+		return nil, false
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: getting measurement by ID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not retrieve measurement"})
+		return nil, false
+	}
+
+	user := middleware.GetUser(r)
+	if measurement.UserID != user.ID {
+		h.logger.Printf("ERROR: user %d trying to access measurement owned by user %d", user.ID, measurement.UserID)
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "you do not have permission to access this measurement"})
+		return nil, false
+	}
+	return measurement, true
+}
@@ -0,0 +1,476 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sachanritik1/go-lang/internal/middleware"
+	"github.com/sachanritik1/go-lang/internal/store"
+	"github.com/sachanritik1/go-lang/internal/units"
+	"github.com/sachanritik1/go-lang/internal/utils"
+)
+
+const (
+	// scheduleDefaultDays is how far back and ahead GET /schedule looks without dates.
+	scheduleDefaultDays = 28
+	scheduleMaxRange    = 366 * 24 * time.Hour
+	maxProgramWeeks     = 52
+)
+
+type ProgramHandler struct {
+	store  store.ProgramStore
+	logger *log.Logger
+}
+
+func NewProgramHandler(store store.ProgramStore, logger *log.Logger) *ProgramHandler {
+	return &ProgramHandler{store: store, logger: logger}
+}
+
+func validateProgram(fe utils.FieldErrors, program *store.Program) {
+	program.Name = strings.TrimSpace(program.Name)
+	if program.Name == "" {
+		fe.Add("name", "is required")
+	} else if len(program.Name) > 100 {
+		fe.Add("name", "must not be more than 100 characters")
+	}
+	if program.Weeks < 1 || program.Weeks > maxProgramWeeks {
+		fe.Add("weeks", fmt.Sprintf("must be between 1 and %d", maxProgramWeeks))
+	}
+
+	slots := map[[2]int]bool{}
+	for i, day := range program.Days {
+		field := fmt.Sprintf("days[%d]", i)
+		if day.Week < 1 || day.Week > program.Weeks {
+			fe.Add(field+".week", "must be between 1 and the program's weeks")
+		}
+		if day.Day < 1 || day.Day > 7 {
+			fe.Add(field+".day", "must be between 1 and 7")
+		}
+		if day.TemplateID <= 0 {
+			fe.Add(field+".template_id", "is required")
+		}
+		slot := [2]int{day.Week, day.Day}
+		if slots[slot] {
+			fe.Add(field, "another day is scheduled on the same week and day")
+		}
+		slots[slot] = true
+	}
+
+	exercises := map[int]bool{}
+	for i, rule := range program.Rules {
+		field := fmt.Sprintf("rules[%d]", i)
+		if rule.ExerciseID <= 0 {
+			fe.Add(field+".exercise_id", "is required")
+		} else if exercises[rule.ExerciseID] {
+			fe.Add(field+".exercise_id", "already has a rule")
+		}
+		exercises[rule.ExerciseID] = true
+		if !store.IsValidRuleType(rule.RuleType) {
+			fe.Add(field+".rule_type", "must be percentage or linear")
+		}
+		if rule.RuleType == store.RuleTypePercentage && len(rule.Percentages) == 0 {
+			fe.Add(field+".percentages", "is required for percentage rules")
+		}
+		for _, p := range rule.Percentages {
+			if p <= 0 || p > 1.5 {
+				fe.Add(field+".percentages", "must be fractions between 0 and 1.5")
+				break
+			}
+		}
+		if rule.BaseWeight < 0 {
+			fe.Add(field+".base_weight", "must not be negative")
+		}
+		if rule.RoundTo < 0 {
+			fe.Add(field+".round_to", "must not be negative")
+		}
+		if rule.WeightUnit != "" && !units.IsValidWeightUnit(rule.WeightUnit) {
+			fe.Add(field+".weight_unit", "must be kg or lb")
+		}
+	}
+}
+
+// writeProgramError answers the store errors caused by what the program
+// refers to and reports whether it did.
+func writeProgramError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, store.ErrUnknownTemplate):
+		utils.WriteFieldErrors(w, http.StatusBadRequest, utils.FieldErrors{"days": {"every day needs one of your templates"}})
+	case errors.Is(err, store.ErrUnknownExercise):
+		utils.WriteFieldErrors(w, http.StatusBadRequest, utils.FieldErrors{"rules": {"every rule needs a known exercise_id"}})
+	default:
+		return false
+	}
+	return true
+}
+
+func (h *ProgramHandler) HandlerListPrograms(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	programs, err := h.store.ListPrograms(r.Context(), user.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: listing programs: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not retrieve programs"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"programs": programs})
+}
+
+func (h *ProgramHandler) HandlerCreateProgram(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	var program store.Program
+	err := json.NewDecoder(r.Body).Decode(&program)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding create program request: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+	program.UserID = user.ID
+
+	fieldErrors := utils.FieldErrors{}
+	system := readUnitSystem(r, user, fieldErrors)
+	validateProgram(fieldErrors, &program)
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+	rulesToCanonicalUnits(program.Rules, system)
+
+	created, err := h.store.CreateProgram(r.Context(), &program)
+	if writeProgramError(w, err) {
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: creating program: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not create program"})
+		return
+	}
+
+	rulesToResponseUnits(created.Rules, system)
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"program": created})
+}
+
+func (h *ProgramHandler) HandlerGetProgramByID(w http.ResponseWriter, r *http.Request) {
+	program, ok := h.readOwnedProgram(w, r)
+	if !ok {
+		return
+	}
+
+	fieldErrors := utils.FieldErrors{}
+	system := readUnitSystem(r, middleware.GetUser(r), fieldErrors)
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	rulesToResponseUnits(program.Rules, system)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"program": program})
+}
+
+func (h *ProgramHandler) HandlerUpdateProgram(w http.ResponseWriter, r *http.Request) {
+	program, ok := h.readOwnedProgram(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name        *string                 `json:"name"`
+		Description *string                 `json:"description"`
+		Weeks       *int                    `json:"weeks"`
+		Days        []store.ProgramDay      `json:"days"`
+		Rules       []store.ProgressionRule `json:"rules"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding update program request: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	if req.Name != nil {
+		program.Name = *req.Name
+	}
+	if req.Description != nil {
+		program.Description = *req.Description
+	}
+	if req.Weeks != nil {
+		program.Weeks = *req.Weeks
+	}
+	if req.Days != nil {
+		program.Days = req.Days
+	}
+
+	fieldErrors := utils.FieldErrors{}
+	system := readUnitSystem(r, middleware.GetUser(r), fieldErrors)
+	if req.Rules != nil {
+		program.Rules = req.Rules
+		validateProgram(fieldErrors, program)
+		rulesToCanonicalUnits(program.Rules, system)
+	} else {
+		// stored rules are already canonical and valid
+		rules := program.Rules
+		program.Rules = nil
+		validateProgram(fieldErrors, program)
+		program.Rules = rules
+	}
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	updated, err := h.store.UpdateProgram(r.Context(), program)
+	if writeProgramError(w, err) {
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: updating program: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not update program"})
+		return
+	}
+
+	rulesToResponseUnits(updated.Rules, system)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"program": updated})
+}
+
+// HandlerDeleteProgram removes a program with its enrollments; workouts logged
+// for it are kept but unlinked.
+func (h *ProgramHandler) HandlerDeleteProgram(w http.ResponseWriter, r *http.Request) {
+	program, ok := h.readOwnedProgram(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.store.DeleteProgram(r.Context(), program.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: deleting program: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not delete program"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "program deleted successfully"})
+}
+
+// HandlerEnroll starts the user on the {id} program from start_date, today by default.
+func (h *ProgramHandler) HandlerEnroll(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	program, ok := h.readOwnedProgram(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		StartDate string `json:"start_date"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding enroll request: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	enrollment := store.Enrollment{UserID: user.ID, ProgramID: program.ID, StartDate: req.StartDate}
+	if enrollment.StartDate == "" {
+		enrollment.StartDate = userToday(user, time.Now()).Format(time.DateOnly)
+	} else if _, err := time.Parse(time.DateOnly, enrollment.StartDate); err != nil {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, utils.FieldErrors{"start_date": {"must be a YYYY-MM-DD date"}})
+		return
+	}
+
+	err = h.store.Enroll(r.Context(), &enrollment)
+	if errors.Is(err, store.ErrAlreadyEnrolled) {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: enrolling in program: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not enroll in program"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"enrollment": enrollment})
+}
+
+func (h *ProgramHandler) HandlerListEnrollments(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	enrollments, err := h.store.ListEnrollments(r.Context(), user.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: listing enrollments: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not retrieve enrollments"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"enrollments": enrollments})
+}
+
+// HandlerUpdateEnrollment completes, cancels or reactivates an enrollment.
+func (h *ProgramHandler) HandlerUpdateEnrollment(w http.ResponseWriter, r *http.Request) {
+	enrollmentID, err := utils.ReadIDParam(r)
+	if err != nil {
+		h.logger.Printf("ERROR: reading ID parameter: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid enrollment ID parameter"})
+		return
+	}
+
+	enrollment, err := h.store.GetEnrollmentByID(r.Context(), enrollmentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "enrollment not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: getting enrollment by ID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not retrieve enrollment"})
+		return
+	}
+	user := middleware.GetUser(r)
+	if enrollment.UserID != user.ID {
+		h.logger.Printf("ERROR: user %d trying to update enrollment owned by user %d", user.ID, enrollment.UserID)
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "you do not have permission to update this enrollment"})
+		return
+	}
+
+	var req struct {
+		Status string `json:"status"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding update enrollment request: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+	if !store.IsValidEnrollmentStatus(req.Status) {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, utils.FieldErrors{"status": {"must be one of active, completed, cancelled"}})
+		return
+	}
+
+	err = h.store.UpdateEnrollmentStatus(r.Context(), enrollment.ID, req.Status)
+	if errors.Is(err, store.ErrAlreadyEnrolled) {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: updating enrollment: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not update enrollment"})
+		return
+	}
+
+	enrollment.Status = req.Status
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"enrollment": enrollment})
+}
+
+// HandlerGetSchedule returns the sessions of the user's active enrollments
+// between from and to (YYYY-MM-DD in the user's time zone, to exclusive),
+// optionally narrowed by status. It defaults to four weeks either side of today.
+func (h *ProgramHandler) HandlerGetSchedule(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	fieldErrors := utils.FieldErrors{}
+	system := readUnitSystem(r, user, fieldErrors)
+	today := userToday(user, time.Now())
+	from, to, status := readScheduleQuery(r.URL.Query(), today, fieldErrors)
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	sessions, err := h.store.GetSchedule(r.Context(), user.ID, today, from, to)
+	if err != nil {
+		h.logger.Printf("ERROR: getting schedule: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not retrieve schedule"})
+		return
+	}
+
+	if status != "" {
+		filtered := []*store.ScheduledSession{}
+		for _, session := range sessions {
+			if session.Status == status {
+				filtered = append(filtered, session)
+			}
+		}
+		sessions = filtered
+	}
+
+	sessionsToResponseUnits(sessions, system)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{
+		"today":    today.Format(time.DateOnly),
+		"from":     from.Format(time.DateOnly),
+		"to":       to.Format(time.DateOnly),
+		"sessions": sessions,
+	})
+}
+
+func readScheduleQuery(qs url.Values, today time.Time, fe utils.FieldErrors) (time.Time, time.Time, string) {
+	from := today.AddDate(0, 0, -scheduleDefaultDays)
+	to := today.AddDate(0, 0, scheduleDefaultDays+1)
+	for key, date := range map[string]*time.Time{"from": &from, "to": &to} {
+		v := qs.Get(key)
+		if v == "" {
+			continue
+		}
+		d, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			fe.Add(key, "must be a YYYY-MM-DD date")
+			continue
+		}
+		*date = d
+	}
+	if !from.Before(to) {
+		fe.Add("to", "must be after from")
+	} else if to.Sub(from) > scheduleMaxRange {
+		fe.Add("from", "range must not exceed a year")
+	}
+
+	status := qs.Get("status")
+	switch status {
+	case "", store.SessionUpcoming, store.SessionMissed, store.SessionCompleted:
+	default:
+		fe.Add("status", "must be one of upcoming, missed, completed")
+	}
+	return from, to, status
+}
+
+// userToday is the current calendar date in the user's time zone, as a UTC midnight.
+func userToday(user *store.User, now time.Time) time.Time {
+	location, err := time.LoadLocation(user.Timezone)
+	if err != nil || user.Timezone == "" {
+		location = time.UTC
+	}
+	local := now.In(location)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func (h *ProgramHandler) readOwnedProgram(w http.ResponseWriter, r *http.Request) (*store.Program, bool) {
+	programID, err := utils.ReadIDParam(r)
+	if err != nil {
+		h.logger.Printf("ERROR: reading ID parameter: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid program ID parameter"})
+		return nil, false
+	}
+
+	program, err := h.store.GetProgramByID(r.Context(), programID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "program not found"})
+		return nil, false
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: getting program by ID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not retrieve program"})
+		return nil, false
+	}
+
+	user := middleware.GetUser(r)
+	if program.UserID != user.ID {
+		h.logger.Printf("ERROR: user %d trying to access program owned by user %d", user.ID, program.UserID)
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "you do not have permission to access this program"})
+		return nil, false
+	}
+	return program, true
+}
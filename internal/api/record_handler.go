@@ -0,0 +1,88 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/sachanritik1/go-lang/internal/middleware"
+	"github.com/sachanritik1/go-lang/internal/store"
+	"github.com/sachanritik1/go-lang/internal/units"
+	"github.com/sachanritik1/go-lang/internal/utils"
+)
+
+type RecordHandler struct {
+	store  store.RecordStore
+	logger *log.Logger
+}
+
+func NewRecordHandler(store store.RecordStore, logger *log.Logger) *RecordHandler {
+	return &RecordHandler{store: store, logger: logger}
+}
+
+// HandlerListRecords returns the caller's current personal records.
+func (h *RecordHandler) HandlerListRecords(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	fieldErrors := utils.FieldErrors{}
+	system := readUnitSystem(r, user, fieldErrors)
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	records, err := h.store.ListPersonalRecords(r.Context(), user.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: listing personal records: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not retrieve personal records"})
+		return
+	}
+
+	setRelativeStrength(records, user.BodyWeight)
+	recordsToResponseUnits(records, system)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"records": records})
+}
+
+// HandlerGetRecordHistory returns every record the caller set on the {id}
+// exercise, oldest first, so clients can chart how a best progressed.
+func (h *RecordHandler) HandlerGetRecordHistory(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	exerciseID, err := utils.ReadIDParam(r)
+	if err != nil {
+		h.logger.Printf("ERROR: reading ID parameter: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid exercise ID parameter"})
+		return
+	}
+
+	fieldErrors := utils.FieldErrors{}
+	system := readUnitSystem(r, user, fieldErrors)
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	records, err := h.store.GetRecordHistory(r.Context(), user.ID, exerciseID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting personal record history: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not retrieve personal records"})
+		return
+	}
+
+	setRelativeStrength(records, user.BodyWeight)
+	recordsToResponseUnits(records, system)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"records": records})
+}
+
+// setRelativeStrength sets how many times bodyWeight each weight-based record
+// lifted. Both are kilograms, so it runs before the records are converted.
+func setRelativeStrength(records []*store.PersonalRecord, bodyWeight *float64) {
+	if bodyWeight == nil || *bodyWeight <= 0 {
+		return
+	}
+	for _, record := range records {
+		if record.IsWeightRecord() {
+			ratio := units.Round(record.Value / *bodyWeight, 2)
+			record.RelativeStrength = &ratio
+		}
+	}
+}
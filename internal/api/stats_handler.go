@@ -0,0 +1,205 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/sachanritik1/go-lang/internal/middleware"
+	"github.com/sachanritik1/go-lang/internal/store"
+	"github.com/sachanritik1/go-lang/internal/units"
+	"github.com/sachanritik1/go-lang/internal/utils"
+)
+
+// statsMaxRange bounds how many buckets a single stats request can produce.
+const statsMaxRange = 5 * 366 * 24 * time.Hour
+
+type StatsHandler struct {
+	store  store.StatsStore
+	logger *log.Logger
+}
+
+func NewStatsHandler(store store.StatsStore, logger *log.Logger) *StatsHandler {
+	return &StatsHandler{store: store, logger: logger}
+}
+
+// HandlerGetTrainingStats returns workout frequency, duration, calories and
+// volume per week or month.
+func (h *StatsHandler) HandlerGetTrainingStats(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	statsRange, system, ok := h.readStatsRequest(w, r, user)
+	if !ok {
+		return
+	}
+
+	buckets, err := h.store.TrainingStats(r.Context(), user.ID, statsRange)
+	if err != nil {
+		h.logger.Printf("ERROR: computing training stats: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not compute stats"})
+		return
+	}
+
+	weightUnit := units.WeightUnit(system)
+	for i := range buckets {
+		buckets[i].Volume = units.Round(units.FromKilograms(buckets[i].Volume, weightUnit), 2)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{
+		"range":       statsRangeEnvelope(statsRange),
+		"weight_unit": weightUnit,
+		"buckets":     buckets,
+	})
+}
+
+// HandlerGetMuscleGroupStats returns sets, reps and volume per muscle group over the range.
+func (h *StatsHandler) HandlerGetMuscleGroupStats(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	statsRange, system, ok := h.readStatsRequest(w, r, user)
+	if !ok {
+		return
+	}
+
+	groups, err := h.store.MuscleGroupStats(r.Context(), user.ID, statsRange)
+	if err != nil {
+		h.logger.Printf("ERROR: computing muscle group stats: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not compute stats"})
+		return
+	}
+
+	weightUnit := units.WeightUnit(system)
+	for i := range groups {
+		groups[i].Volume = units.Round(units.FromKilograms(groups[i].Volume, weightUnit), 2)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{
+		"range":         statsRangeEnvelope(statsRange),
+		"weight_unit":   weightUnit,
+		"muscle_groups": groups,
+	})
+}
+
+// HandlerGetExerciseProgression returns per-bucket bests and totals for the {id} exercise.
+func (h *StatsHandler) HandlerGetExerciseProgression(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	exerciseID, err := utils.ReadIDParam(r)
+	if err != nil {
+		h.logger.Printf("ERROR: reading ID parameter: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid exercise ID parameter"})
+		return
+	}
+
+	statsRange, system, ok := h.readStatsRequest(w, r, user)
+	if !ok {
+		return
+	}
+
+	points, err := h.store.ExerciseProgression(r.Context(), user.ID, exerciseID, statsRange)
+	if err != nil {
+		h.logger.Printf("ERROR: computing exercise progression: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not compute stats"})
+		return
+	}
+
+	weightUnit, distanceUnit := units.WeightUnit(system), units.DistanceUnit(system)
+	convert := func(kilograms *float64) *float64 {
+		if kilograms == nil {
+			return nil
+		}
+		v := units.Round(units.FromKilograms(*kilograms, weightUnit), 2)
+		return &v
+	}
+	for i := range points {
+		p := &points[i]
+		p.Volume = units.Round(units.FromKilograms(p.Volume, weightUnit), 2)
+		p.MaxWeight = convert(p.MaxWeight)
+		p.Estimated1RM = convert(p.Estimated1RM)
+		if p.Distance != nil {
+			distance := units.Round(units.FromMeters(*p.Distance, distanceUnit), 3)
+			p.Distance = &distance
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{
+		"range":         statsRangeEnvelope(statsRange),
+		"weight_unit":   weightUnit,
+		"distance_unit": distanceUnit,
+		"progression":   points,
+	})
+}
+
+func (h *StatsHandler) readStatsRequest(w http.ResponseWriter, r *http.Request, user *store.User) (store.StatsRange, string, bool) {
+	fieldErrors := utils.FieldErrors{}
+	system := readUnitSystem(r, user, fieldErrors)
+	statsRange := readStatsRange(r.URL.Query(), user, time.Now(), fieldErrors)
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return statsRange, system, false
+	}
+	return statsRange, system, true
+}
+
+// readStatsRange parses interval (week or month), from and to (YYYY-MM-DD in
+// the user's time zone, to exclusive). Without dates the range covers the
+// twelve weeks or months up to and including today.
+func readStatsRange(qs url.Values, user *store.User, now time.Time, fe utils.FieldErrors) store.StatsRange {
+	statsRange := store.StatsRange{Interval: store.IntervalWeek, Timezone: user.Timezone}
+	if statsRange.Timezone == "" {
+		statsRange.Timezone = "UTC"
+	}
+	location, err := time.LoadLocation(statsRange.Timezone)
+	if err != nil {
+		location, statsRange.Timezone = time.UTC, "UTC"
+	}
+
+	if interval := qs.Get("interval"); interval != "" {
+		if !store.IsValidStatsInterval(interval) {
+			fe.Add("interval", "must be week or month")
+		}
+		statsRange.Interval = interval
+	}
+
+	readDate := func(key string) (time.Time, bool) {
+		v := qs.Get(key)
+		if v == "" {
+			return time.Time{}, false
+		}
+		d, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			fe.Add(key, "must be a YYYY-MM-DD date")
+			return time.Time{}, false
+		}
+		return d, true
+	}
+
+	local := now.In(location)
+	statsRange.To = time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, time.UTC)
+	if to, ok := readDate("to"); ok {
+		statsRange.To = to
+	}
+	if statsRange.Interval == store.IntervalMonth {
+		statsRange.From = statsRange.To.AddDate(0, -12, 0)
+	} else {
+		statsRange.From = statsRange.To.AddDate(0, 0, -12*7)
+	}
+	if from, ok := readDate("from"); ok {
+		statsRange.From = from
+	}
+
+	if !statsRange.From.Before(statsRange.To) {
+		fe.Add("to", "must be after from")
+	} else if statsRange.To.Sub(statsRange.From) > statsMaxRange {
+		fe.Add("from", "range must not exceed five years")
+	}
+	return statsRange
+}
+
+func statsRangeEnvelope(r store.StatsRange) utils.Envelope {
+	return utils.Envelope{
+		"interval": r.Interval,
+		"from":     r.From.Format(time.DateOnly),
+		"to":       r.To.Format(time.DateOnly),
+		"timezone": r.Timezone,
+	}
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/sachanritik1/go-lang/internal/middleware"
+	"github.com/sachanritik1/go-lang/internal/store"
+	"github.com/sachanritik1/go-lang/internal/utils"
+)
+
+type StreakHandler struct {
+	store  store.StreakStore
+	logger *log.Logger
+}
+
+func NewStreakHandler(store store.StreakStore, logger *log.Logger) *StreakHandler {
+	return &StreakHandler{store: store, logger: logger}
+}
+
+// HandlerGetStreaks returns the caller's daily and weekly streaks and recent
+// consistency, counted in their timezone. ?rest_days= and ?rest_weeks= allow
+// gaps inside a streak; ?weekly_target= is how many training days make a week.
+func (h *StreakHandler) HandlerGetStreaks(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	qs := r.URL.Query()
+
+	fieldErrors := utils.FieldErrors{}
+	opts := store.StreakOptions{
+		RestDays:     readStreakOption(qs, "rest_days", 0, 0, 6, fieldErrors),
+		WeeklyTarget: readStreakOption(qs, "weekly_target", 1, 1, 7, fieldErrors),
+		RestWeeks:    readStreakOption(qs, "rest_weeks", 0, 0, 4, fieldErrors),
+	}
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	streaks, err := h.store.GetStreaks(r.Context(), user.ID, userToday(user, time.Now()), opts)
+	if err != nil {
+		h.logger.Printf("ERROR: getting streaks: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not retrieve streaks"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{
+		"streaks":       streaks,
+		"rest_days":     opts.RestDays,
+		"weekly_target": opts.WeeklyTarget,
+		"rest_weeks":    opts.RestWeeks,
+	})
+}
+
+func readStreakOption(qs url.Values, name string, fallback, min, max int, fe utils.FieldErrors) int {
+	v := qs.Get(name)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < min || n > max {
+		fe.Add(name, "must be a number between "+strconv.Itoa(min)+" and "+strconv.Itoa(max))
+	}
+	return n
+}
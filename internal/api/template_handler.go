@@ -0,0 +1,373 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sachanritik1/go-lang/internal/middleware"
+	"github.com/sachanritik1/go-lang/internal/store"
+	"github.com/sachanritik1/go-lang/internal/utils"
+)
+
+type TemplateHandler struct {
+	store        store.TemplateStore
+	workoutStore store.WorkoutStore
+	logger       *log.Logger
+}
+
+func NewTemplateHandler(store store.TemplateStore, workoutStore store.WorkoutStore, logger *log.Logger) *TemplateHandler {
+	return &TemplateHandler{store: store, workoutStore: workoutStore, logger: logger}
+}
+
+type InstantiateTemplateRequest struct {
+	Title       *string    `json:"title"`
+	PerformedAt *time.Time `json:"performed_at"`
+	// CarryForwardWeights replaces planned weights with the ones last logged for each exercise.
+	CarryForwardWeights bool `json:"carry_forward_weights"`
+	// EnrollmentID and ProgramDayID link the workout to the scheduled session it fulfils.
+	EnrollmentID *int `json:"enrollment_id"`
+	ProgramDayID *int `json:"program_day_id"`
+}
+
+func validateTemplate(fe utils.FieldErrors, template *store.WorkoutTemplate) {
+	template.Title = strings.TrimSpace(template.Title)
+	if template.Title == "" {
+		fe.Add("title", "is required")
+	} else if len(template.Title) > 100 {
+		fe.Add("title", "must not be more than 100 characters")
+	}
+	if template.DurationMinutes < 0 {
+		fe.Add("duration_minutes", "must not be negative")
+	}
+	for i, entry := range template.Entries {
+		validateSets(fe, fmt.Sprintf("entries[%d].sets", i), entry.Sets)
+		for j, set := range entry.Sets {
+			if set.StartedAt != nil {
+				fe.Add(fmt.Sprintf("entries[%d].sets[%d].started_at", i, j), "must not be set on a template")
+			}
+			if set.CompletedAt != nil {
+				fe.Add(fmt.Sprintf("entries[%d].sets[%d].completed_at", i, j), "must not be set on a template")
+			}
+		}
+		validateRestTarget(fe, fmt.Sprintf("entries[%d].rest_target_seconds", i), entry.RestTargetSeconds)
+	}
+}
+
+func templateToCanonicalUnits(template *store.WorkoutTemplate, system string) {
+	for i := range template.Entries {
+		setsToCanonicalUnits(template.Entries[i].Sets, system)
+	}
+}
+
+func templateToResponseUnits(template *store.WorkoutTemplate, system string) {
+	for i := range template.Entries {
+		setsToResponseUnits(template.Entries[i].Sets, system)
+	}
+}
+
+func (h *TemplateHandler) HandlerListTemplates(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	templates, err := h.store.ListTemplates(r.Context(), user.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: listing templates: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not retrieve templates"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"templates": templates})
+}
+
+func (h *TemplateHandler) HandlerCreateTemplate(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	var template store.WorkoutTemplate
+	err := json.NewDecoder(r.Body).Decode(&template)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding create template request: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+	template.UserID = user.ID
+
+	fieldErrors := utils.FieldErrors{}
+	system := readUnitSystem(r, user, fieldErrors)
+	validateTemplate(fieldErrors, &template)
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+	templateToCanonicalUnits(&template, system)
+
+	created, err := h.store.CreateTemplate(r.Context(), &template)
+	if errors.Is(err, store.ErrUnknownExercise) {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, utils.FieldErrors{"entries": {"every entry needs a known exercise_id or an exercise_name"}})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: creating template: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not create template"})
+		return
+	}
+
+	templateToResponseUnits(created, system)
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"template": created})
+}
+
+func (h *TemplateHandler) HandlerGetTemplateByID(w http.ResponseWriter, r *http.Request) {
+	template, ok := h.readOwnedTemplate(w, r)
+	if !ok {
+		return
+	}
+
+	fieldErrors := utils.FieldErrors{}
+	system := readUnitSystem(r, middleware.GetUser(r), fieldErrors)
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	templateToResponseUnits(template, system)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"template": template})
+}
+
+func (h *TemplateHandler) HandlerUpdateTemplate(w http.ResponseWriter, r *http.Request) {
+	template, ok := h.readOwnedTemplate(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Title           *string               `json:"title"`
+		Description     *string               `json:"description"`
+		DurationMinutes *int                  `json:"duration_minutes"`
+		Entries         []store.TemplateEntry `json:"entries"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding update template request: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	if req.Title != nil {
+		template.Title = *req.Title
+	}
+	if req.Description != nil {
+		template.Description = *req.Description
+	}
+	if req.DurationMinutes != nil {
+		template.DurationMinutes = *req.DurationMinutes
+	}
+
+	fieldErrors := utils.FieldErrors{}
+	system := readUnitSystem(r, middleware.GetUser(r), fieldErrors)
+	if req.Entries != nil {
+		template.Entries = req.Entries
+		validateTemplate(fieldErrors, template)
+		templateToCanonicalUnits(template, system)
+	} else {
+		// stored entries are already canonical and valid
+		entries := template.Entries
+		template.Entries = nil
+		validateTemplate(fieldErrors, template)
+		template.Entries = entries
+	}
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	updated, err := h.store.UpdateTemplate(r.Context(), template)
+	if errors.Is(err, store.ErrUnknownExercise) {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, utils.FieldErrors{"entries": {"every entry needs a known exercise_id or an exercise_name"}})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: updating template: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not update template"})
+		return
+	}
+
+	templateToResponseUnits(updated, system)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"template": updated})
+}
+
+func (h *TemplateHandler) HandlerDeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	template, ok := h.readOwnedTemplate(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.store.DeleteTemplate(r.Context(), template.ID)
+	if errors.Is(err, store.ErrTemplateInUse) {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: deleting template: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not delete template"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "template deleted successfully"})
+}
+
+// HandlerInstantiateTemplate logs a new workout prefilled from the template.
+func (h *TemplateHandler) HandlerInstantiateTemplate(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	template, ok := h.readOwnedTemplate(w, r)
+	if !ok {
+		return
+	}
+
+	var req InstantiateTemplateRequest
+	// an empty body takes every default
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		h.logger.Printf("ERROR: decoding instantiate template request: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	fieldErrors := utils.FieldErrors{}
+	system := readUnitSystem(r, user, fieldErrors)
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	workout := &store.Workout{
+		UserID:          user.ID,
+		Title:           template.Title,
+		Description:     template.Description,
+		DurationMinutes: template.DurationMinutes,
+		EnrollmentID:    req.EnrollmentID,
+		ProgramDayID:    req.ProgramDayID,
+		Entries:         make([]store.WorkoutEntry, 0, len(template.Entries)),
+	}
+	if req.Title != nil && strings.TrimSpace(*req.Title) != "" {
+		workout.Title = strings.TrimSpace(*req.Title)
+	}
+	if req.PerformedAt != nil {
+		workout.PerformedAt = *req.PerformedAt
+	}
+
+	for _, entry := range template.Entries {
+		sets := make([]store.WorkoutSet, len(entry.Sets))
+		for i, set := range entry.Sets {
+			// a planned rest is a target, not a rest taken
+			set.ID, set.EntryID = 0, 0
+			set.RestSeconds = nil
+			sets[i] = set
+		}
+		if req.CarryForwardWeights {
+			last, err := h.workoutStore.GetLatestExerciseSets(r.Context(), user.ID, entry.ExerciseID)
+			if err != nil {
+				h.logger.Printf("ERROR: getting latest sets for exercise %d: %v", entry.ExerciseID, err)
+				utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not create workout"})
+				return
+			}
+			carryForwardWeights(sets, last)
+		}
+		workout.Entries = append(workout.Entries, store.WorkoutEntry{
+			ExerciseID:        entry.ExerciseID,
+			ExerciseName:      entry.ExerciseName,
+			Sets:              sets,
+			Notes:             entry.Notes,
+			OrderIndex:        entry.OrderIndex,
+			RestTargetSeconds: restTarget(entry),
+		})
+	}
+
+	created, err := h.workoutStore.CreateWorkout(r.Context(), workout)
+	if writeSessionError(w, err) {
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: creating workout from template: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not create workout"})
+		return
+	}
+
+	toResponseUnits(created, system)
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"workout": created})
+}
+
+// restTarget is the rest a workout entry started from the template should
+// aim for: the entry's target, or else the first planned per-set rest.
+func restTarget(entry store.TemplateEntry) *int {
+	if entry.RestTargetSeconds != nil {
+		return entry.RestTargetSeconds
+	}
+	for _, set := range entry.Sets {
+		if set.RestSeconds != nil {
+			return set.RestSeconds
+		}
+	}
+	return nil
+}
+
+// carryForwardWeights copies weights from the last session onto planned sets.
+// The n-th planned set of a type takes the weight of the n-th set of that type
+// last time, or of the last such set when the plan has more of them.
+func carryForwardWeights(planned, last []store.WorkoutSet) {
+	lastByType := map[string][]store.WorkoutSet{}
+	for _, set := range last {
+		if set.Weight != nil {
+			lastByType[set.SetType] = append(lastByType[set.SetType], set)
+		}
+	}
+
+	seen := map[string]int{}
+	for i := range planned {
+		set := &planned[i]
+		setType := set.SetType
+		if setType == "" {
+			setType = store.SetTypeWorking
+		}
+		previous := lastByType[setType]
+		if len(previous) == 0 {
+			continue
+		}
+		source := previous[min(seen[setType], len(previous)-1)]
+		seen[setType]++
+		weight := *source.Weight
+		set.Weight, set.WeightUnit = &weight, source.WeightUnit
+	}
+}
+
+func (h *TemplateHandler) readOwnedTemplate(w http.ResponseWriter, r *http.Request) (*store.WorkoutTemplate, bool) {
+	templateID, err := utils.ReadIDParam(r)
+	if err != nil {
+		h.logger.Printf("ERROR: reading ID parameter: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid template ID parameter"})
+		return nil, false
+	}
+
+	template, err := h.store.GetTemplateByID(r.Context(), templateID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "template not found"})
+		return nil, false
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: getting template by ID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not retrieve template"})
+		return nil, false
+	}
+
+	user := middleware.GetUser(r)
+	if template.UserID != user.ID {
+		h.logger.Printf("ERROR: user %d trying to access template owned by user %d", user.ID, template.UserID)
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "you do not have permission to access this template"})
+		return nil, false
+	}
+	return template, true
+}
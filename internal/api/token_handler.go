@@ -1,24 +1,49 @@
 package api
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/sachanritik1/go-lang/internal/config"
+	"github.com/sachanritik1/go-lang/internal/mailer"
+	"github.com/sachanritik1/go-lang/internal/middleware"
 	"github.com/sachanritik1/go-lang/internal/store"
+	"github.com/sachanritik1/go-lang/internal/throttle"
 	"github.com/sachanritik1/go-lang/internal/tokens"
 	"github.com/sachanritik1/go-lang/internal/utils"
 )
 
+// BackgroundFunc runs work that must outlive the request, such as sending mail.
+type BackgroundFunc func(fn func(ctx context.Context))
+
+// LoginThrottle limits failed logins per account and per client IP.
+type LoginThrottle struct {
+	Users             throttle.Tracker
+	IPs               throttle.Tracker
+	TrustForwardedFor bool
+}
+
 type TokenHandler struct {
-	store     store.TokenStore
-	userStore store.UserStore
-	logger    *log.Logger
+	store      store.TokenStore
+	userStore  store.UserStore
+	mailer     mailer.Mailer
+	background BackgroundFunc
+	throttle   LoginThrottle
+	ttls       config.TokenConfig
+	logger     *log.Logger
 }
 
-func NewTokenHandler(store store.TokenStore, userStore store.UserStore, logger *log.Logger) *TokenHandler {
-	return &TokenHandler{store: store, userStore: userStore, logger: logger}
+func NewTokenHandler(store store.TokenStore, userStore store.UserStore, mailer mailer.Mailer, background BackgroundFunc, throttle LoginThrottle, ttls config.TokenConfig, logger *log.Logger) *TokenHandler {
+	return &TokenHandler{store: store, userStore: userStore, mailer: mailer, background: background, throttle: throttle, ttls: ttls, logger: logger}
 }
 
 type CreateTokenRequest struct {
@@ -26,6 +51,14 @@ type CreateTokenRequest struct {
 	Password string `json:"password"`
 }
 
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type PasswordResetTokenRequest struct {
+	Email string `json:"email"`
+}
+
 func (h *TokenHandler) HandleCreateToken(w http.ResponseWriter, r *http.Request) {
 	var req CreateTokenRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
@@ -37,32 +70,67 @@ func (h *TokenHandler) HandleCreateToken(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	user, err := h.userStore.GetUserByUsername(req.Username)
+	userKey := "user:" + strings.ToLower(req.Username)
+	ipKey := "ip:" + utils.ClientIP(r, h.throttle.TrustForwardedFor)
+
+	wait, err := h.lockoutRemaining(r, userKey, ipKey)
 	if err != nil {
-		h.logger.Printf("ERROR: getting user by username: %v", err)
+		h.logger.Printf("ERROR: checking login attempts: %v", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{
 			"error": "internal server error",
 		})
 		return
 	}
+	if wait > 0 {
+		writeTooManyAttempts(w, wait)
+		return
+	}
 
-	passwordDoMatch, err := user.PasswordHash.Matches(req.Password)
-	if err != nil {
-		h.logger.Printf("ERROR: checking password match: %v", err)
+	user, err := h.userStore.GetUserByUsername(r.Context(), req.Username)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		h.logger.Printf("ERROR: getting user by username: %v", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{
 			"error": "internal server error",
 		})
 		return
 	}
+
+	passwordDoMatch := false
+	if user != nil {
+		passwordDoMatch, err = user.PasswordHash.Matches(req.Password)
+		if err != nil {
+			h.logger.Printf("ERROR: checking password match: %v", err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{
+				"error": "internal server error",
+			})
+			return
+		}
+	} else {
+		passwordDoMatch = store.MatchesDummyPassword(req.Password)
+	}
 	if !passwordDoMatch {
-		h.logger.Printf("WARNING: invalid password for user %s", req.Username)
+		h.logger.Printf("WARNING: invalid credentials for user %s", req.Username)
+		wait, err = h.recordLoginFailure(r, userKey, ipKey)
+		if err != nil {
+			h.logger.Printf("ERROR: recording failed login: %v", err)
+		}
+		if wait > 0 {
+			writeTooManyAttempts(w, wait)
+			return
+		}
 		utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{
 			"error": "invalid credentials",
 		})
 		return
 	}
 
-	token, err := h.store.CreateNewToken(int64(user.ID), int64(24*time.Hour), tokens.ScopeAuth)
+	// only the account counter is cleared; a valid login must not wipe an IP's failures
+	err = h.throttle.Users.Reset(r.Context(), userKey)
+	if err != nil {
+		h.logger.Printf("ERROR: resetting login attempts: %v", err)
+	}
+
+	authToken, refreshToken, err := h.store.CreateTokenPair(r.Context(), int64(user.ID), h.ttls.AuthTTL, h.ttls.RefreshTTL, r.UserAgent())
 	if err != nil {
 		h.logger.Printf("ERROR: creating new token: %v", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{
@@ -72,7 +140,184 @@ func (h *TokenHandler) HandleCreateToken(w http.ResponseWriter, r *http.Request)
 	}
 
 	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{
-		"auth_token": token,
+		"auth_token":    authToken,
+		"refresh_token": refreshToken,
+	})
+
+}
+
+// lockoutRemaining returns the longest lockout currently applying to any of keys.
+func (h *TokenHandler) lockoutRemaining(r *http.Request, userKey, ipKey string) (time.Duration, error) {
+	userWait, err := h.throttle.Users.Check(r.Context(), userKey)
+	if err != nil {
+		return 0, err
+	}
+	ipWait, err := h.throttle.IPs.Check(r.Context(), ipKey)
+	if err != nil {
+		return 0, err
+	}
+	return max(userWait, ipWait), nil
+}
+
+// recordLoginFailure counts a failed attempt against both keys and audits any lockout it causes.
+func (h *TokenHandler) recordLoginFailure(r *http.Request, userKey, ipKey string) (time.Duration, error) {
+	var longest time.Duration
+	for _, t := range []struct {
+		key     string
+		tracker throttle.Tracker
+	}{{userKey, h.throttle.Users}, {ipKey, h.throttle.IPs}} {
+		lockout, err := t.tracker.RecordFailure(r.Context(), t.key)
+		if err != nil {
+			return longest, err
+		}
+		if lockout > 0 {
+			h.logger.Printf("WARNING: login lockout for %s for %s (user agent %q)", t.key, lockout, r.UserAgent())
+		}
+		longest = max(longest, lockout)
+	}
+	return longest, nil
+}
+
+func writeTooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	utils.WriteJSON(w, http.StatusTooManyRequests, utils.Envelope{
+		"error": fmt.Sprintf("too many failed login attempts, try again in %d seconds", seconds),
+	})
+}
+
+func (h *TokenHandler) HandleRefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req RefreshTokenRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.RefreshToken == "" {
+		h.logger.Printf("ERROR: decoding refresh token request: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{
+			"error": "invalid request payload",
+		})
+		return
+	}
+
+	authToken, refreshToken, err := h.store.RotateRefreshToken(r.Context(), req.RefreshToken, h.ttls.AuthTTL, h.ttls.RefreshTTL, r.UserAgent())
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrTokenReused):
+			h.logger.Printf("WARNING: refresh token reuse detected, token family revoked")
+			utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{
+				"error": "refresh token reuse detected, please log in again",
+			})
+		case errors.Is(err, store.ErrInvalidToken):
+			utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{
+				"error": "invalid or expired refresh token",
+			})
+		default:
+			h.logger.Printf("ERROR: rotating refresh token: %v", err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{
+				"error": "internal server error",
+			})
+		}
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{
+		"auth_token":    authToken,
+		"refresh_token": refreshToken,
 	})
+}
+
+func (h *TokenHandler) HandleListSessions(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	sessions, err := h.store.ListSessions(r.Context(), int64(user.ID))
+	if err != nil {
+		h.logger.Printf("ERROR: listing sessions: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"sessions": sessions})
+}
 
+// HandleRevokeToken logs out the session the request was authenticated with.
+func (h *TokenHandler) HandleRevokeToken(w http.ResponseWriter, r *http.Request) {
+	err := h.store.DeleteToken(r.Context(), middleware.GetToken(r))
+	if err != nil {
+		h.logger.Printf("ERROR: revoking token: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "logged out successfully"})
+}
+
+// HandleRevokeAllTokens logs the caller out of every session, including this one.
+func (h *TokenHandler) HandleRevokeAllTokens(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	for _, scope := range []string{tokens.ScopeAuth, tokens.ScopeRefresh} {
+		err := h.store.DeleteAllTokensForUser(r.Context(), int64(user.ID), scope)
+		if err != nil {
+			h.logger.Printf("ERROR: revoking %s tokens: %v", scope, err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{
+				"error": "internal server error",
+			})
+			return
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "all sessions revoked"})
+}
+
+// HandleCreatePasswordResetToken mails a one-time reset token to the account's address.
+// The response is identical whether or not the email is registered, and the
+// token is issued in the background so response timing does not leak it either.
+func (h *TokenHandler) HandleCreatePasswordResetToken(w http.ResponseWriter, r *http.Request) {
+	var req PasswordResetTokenRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Email == "" {
+		h.logger.Printf("ERROR: decoding password reset request: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{
+			"error": "invalid request payload",
+		})
+		return
+	}
+
+	user, err := h.userStore.GetUserByEmail(r.Context(), req.Email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		h.logger.Printf("ERROR: getting user by email: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	if user != nil {
+		h.background(func(ctx context.Context) {
+			err := h.store.DeleteAllTokensForUser(ctx, int64(user.ID), tokens.ScopePasswordReset)
+			if err != nil {
+				h.logger.Printf("ERROR: deleting old password reset tokens: %v", err)
+				return
+			}
+
+			token, err := h.store.CreateNewToken(ctx, int64(user.ID), h.ttls.PasswordResetTTL, tokens.ScopePasswordReset)
+			if err != nil {
+				h.logger.Printf("ERROR: creating password reset token: %v", err)
+				return
+			}
+
+			body := fmt.Sprintf("Hi %s,\n\nUse the following token to reset your password. It expires at %s.\n\n%s\n\nIf you did not request a password reset you can ignore this email.\n",
+				user.Username, token.Expiry.Format(time.RFC1123), token.PlainText)
+			err = h.mailer.Send(ctx, user.Email, "Reset your password", body)
+			if err != nil {
+				h.logger.Printf("ERROR: sending password reset email: %v", err)
+			}
+		})
+	}
+
+	utils.WriteJSON(w, http.StatusAccepted, utils.Envelope{
+		"message": "if an account with that email exists, password reset instructions have been sent",
+	})
 }
@@ -0,0 +1,248 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/sachanritik1/go-lang/internal/store"
+	"github.com/sachanritik1/go-lang/internal/units"
+	"github.com/sachanritik1/go-lang/internal/utils"
+)
+
+// readUnitSystem picks the unit system of a request: ?units= overrides the
+// user's preference. It applies both to values sent without an explicit unit
+// and to the values in the response.
+func readUnitSystem(r *http.Request, user *store.User, fe utils.FieldErrors) string {
+	if system := r.URL.Query().Get("units"); system != "" {
+		if !units.IsValidSystem(system) {
+			fe.Add("units", "must be metric or imperial")
+			return units.Metric
+		}
+		return system
+	}
+	if units.IsValidSystem(user.Units) {
+		return user.Units
+	}
+	return units.Metric
+}
+
+// toCanonicalUnits converts the weights and distances of entries from the
+// units they were sent in to the kilograms and meters the store keeps.
+func toCanonicalUnits(entries []store.WorkoutEntry, system string) {
+	for i := range entries {
+		setsToCanonicalUnits(entries[i].Sets, system)
+	}
+}
+
+func setsToCanonicalUnits(sets []store.WorkoutSet, system string) {
+	for i := range sets {
+		set := &sets[i]
+		if set.WeightUnit == "" {
+			set.WeightUnit = units.WeightUnit(system)
+		}
+		if set.DistanceUnit == "" {
+			set.DistanceUnit = units.DistanceUnit(system)
+		}
+		if set.Weight != nil {
+			kilograms := units.ToKilograms(*set.Weight, set.WeightUnit)
+			set.Weight = &kilograms
+		}
+		if set.Distance != nil {
+			meters := units.ToMeters(*set.Distance, set.DistanceUnit)
+			set.Distance = &meters
+		}
+	}
+}
+
+// toResponseUnits converts the stored weights and distances of workout to system.
+func toResponseUnits(workout *store.Workout, system string) {
+	recordsToResponseUnits(workout.Records, system)
+	if workout.Timing != nil && workout.Timing.Density != nil {
+		density := units.Round(units.FromKilograms(*workout.Timing.Density, units.WeightUnit(system)), 2)
+		workout.Timing.Density = &density
+	}
+	for i := range workout.Entries {
+		setsToResponseUnits(workout.Entries[i].Sets, system)
+	}
+}
+
+func setsToResponseUnits(sets []store.WorkoutSet, system string) {
+	weightUnit, distanceUnit := units.WeightUnit(system), units.DistanceUnit(system)
+	for i := range sets {
+		set := &sets[i]
+		set.WeightUnit, set.DistanceUnit = weightUnit, distanceUnit
+		if set.Weight != nil {
+			weight := units.Round(units.FromKilograms(*set.Weight, weightUnit), 2)
+			set.Weight = &weight
+		}
+		if set.Distance != nil {
+			distance := units.Round(units.FromMeters(*set.Distance, distanceUnit), 3)
+			set.Distance = &distance
+		}
+	}
+}
+
+// recordsToResponseUnits converts the weights of records to system; rep and
+// duration records keep their value.
+func recordsToResponseUnits(records []*store.PersonalRecord, system string) {
+	weightUnit := units.WeightUnit(system)
+	for _, record := range records {
+		if record.IsWeightRecord() {
+			record.Value = units.Round(units.FromKilograms(record.Value, weightUnit), 2)
+			record.WeightUnit = weightUnit
+		}
+		if record.Weight != nil {
+			weight := units.Round(units.FromKilograms(*record.Weight, weightUnit), 2)
+			record.Weight = &weight
+			record.WeightUnit = weightUnit
+		}
+	}
+}
+
+// rulesToCanonicalUnits converts the weights of rules from the unit they were
+// sent in, or the system's, to kilograms.
+func rulesToCanonicalUnits(rules []store.ProgressionRule, system string) {
+	for i := range rules {
+		rule := &rules[i]
+		if rule.WeightUnit == "" {
+			rule.WeightUnit = units.WeightUnit(system)
+		}
+		rule.BaseWeight = units.ToKilograms(rule.BaseWeight, rule.WeightUnit)
+		rule.Increment = units.ToKilograms(rule.Increment, rule.WeightUnit)
+		rule.RoundTo = units.ToKilograms(rule.RoundTo, rule.WeightUnit)
+		rule.WeightUnit = ""
+	}
+}
+
+func rulesToResponseUnits(rules []store.ProgressionRule, system string) {
+	weightUnit := units.WeightUnit(system)
+	for i := range rules {
+		rule := &rules[i]
+		rule.BaseWeight = units.Round(units.FromKilograms(rule.BaseWeight, weightUnit), 2)
+		rule.Increment = units.Round(units.FromKilograms(rule.Increment, weightUnit), 2)
+		rule.RoundTo = units.Round(units.FromKilograms(rule.RoundTo, weightUnit), 2)
+		rule.WeightUnit = weightUnit
+	}
+}
+
+func sessionsToResponseUnits(sessions []*store.ScheduledSession, system string) {
+	weightUnit := units.WeightUnit(system)
+	for _, session := range sessions {
+		for i := range session.Targets {
+			target := &session.Targets[i]
+			target.Weight = units.Round(units.FromKilograms(target.Weight, weightUnit), 2)
+			target.WeightUnit = weightUnit
+		}
+	}
+}
+
+// userToResponseUnits returns a copy of user with the body weight in the
+// user's preferred unit, leaving the request's user untouched.
+func userToResponseUnits(user *store.User) *store.User {
+	response := *user
+	if user.BodyWeight != nil {
+		weight := units.Round(units.FromKilograms(*user.BodyWeight, units.WeightUnit(user.Units)), 2)
+		response.BodyWeight = &weight
+	}
+	return &response
+}
+
+// percent is the unit body fat is measured in, whatever the unit system.
+const percent = "%"
+
+// measurementUnit is the unit a measurement of kind is shown in for system.
+func measurementUnit(kind, system string) string {
+	switch kind {
+	case store.MeasurementBodyWeight:
+		return units.WeightUnit(system)
+	case store.MeasurementBodyFat:
+		return percent
+	default:
+		return units.LengthUnit(system)
+	}
+}
+
+func isValidMeasurementUnit(kind, unit string) bool {
+	switch kind {
+	case store.MeasurementBodyWeight:
+		return units.IsValidWeightUnit(unit)
+	case store.MeasurementBodyFat:
+		return unit == percent
+	default:
+		return units.IsValidLengthUnit(unit)
+	}
+}
+
+// measurementToCanonicalUnits converts a value of kind in unit to what the
+// store keeps: kilograms, a percentage or meters.
+func measurementToCanonicalUnits(kind string, value float64, unit string) float64 {
+	switch kind {
+	case store.MeasurementBodyWeight:
+		return units.ToKilograms(value, unit)
+	case store.MeasurementBodyFat:
+		return value
+	default:
+		return units.LengthToMeters(value, unit)
+	}
+}
+
+func measurementFromCanonicalUnits(kind string, value float64, unit string) float64 {
+	switch kind {
+	case store.MeasurementBodyWeight:
+		return units.Round(units.FromKilograms(value, unit), 2)
+	case store.MeasurementBodyFat:
+		return units.Round(value, 2)
+	default:
+		return units.Round(units.LengthFromMeters(value, unit), 2)
+	}
+}
+
+func measurementsToResponseUnits(measurements []*store.Measurement, system string) {
+	for _, measurement := range measurements {
+		measurement.Unit = measurementUnit(measurement.Kind, system)
+		measurement.Value = measurementFromCanonicalUnits(measurement.Kind, measurement.Value, measurement.Unit)
+	}
+}
+
+// goalToCanonicalUnits converts the weights of a goal from the unit they were
+// sent in, or the system's, to kilograms.
+func goalToCanonicalUnits(goal *store.Goal, system string) {
+	if !goal.IsWeightGoal() {
+		goal.WeightUnit = ""
+		return
+	}
+	if goal.WeightUnit == "" {
+		goal.WeightUnit = units.WeightUnit(system)
+	}
+	goal.TargetValue = units.ToKilograms(goal.TargetValue, goal.WeightUnit)
+	if goal.StartValue != nil {
+		start := units.ToKilograms(*goal.StartValue, goal.WeightUnit)
+		goal.StartValue = &start
+	}
+	goal.WeightUnit = ""
+}
+
+func goalsToResponseUnits(goals []*store.Goal, system string) {
+	weightUnit := units.WeightUnit(system)
+	convert := func(kilograms *float64) *float64 {
+		if kilograms == nil {
+			return nil
+		}
+		v := units.Round(units.FromKilograms(*kilograms, weightUnit), 2)
+		return &v
+	}
+	for _, goal := range goals {
+		if !goal.IsWeightGoal() {
+			continue
+		}
+		goal.WeightUnit = weightUnit
+		goal.TargetValue = units.Round(units.FromKilograms(goal.TargetValue, weightUnit), 2)
+		goal.StartValue = convert(goal.StartValue)
+		if goal.Progress != nil {
+			goal.Progress.Target = units.Round(units.FromKilograms(goal.Progress.Target, weightUnit), 2)
+			goal.Progress.CurrentValue = convert(goal.Progress.CurrentValue)
+		}
+		for i := range goal.Events {
+			goal.Events[i].Value = convert(goal.Events[i].Value)
+		}
+	}
+}
@@ -1,24 +1,38 @@
 package api
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"regexp"
+	"time"
 
+	"github.com/sachanritik1/go-lang/internal/config"
+	"github.com/sachanritik1/go-lang/internal/mailer"
 	"github.com/sachanritik1/go-lang/internal/middleware"
+	"github.com/sachanritik1/go-lang/internal/passwords"
 	"github.com/sachanritik1/go-lang/internal/store"
+	"github.com/sachanritik1/go-lang/internal/tokens"
+	"github.com/sachanritik1/go-lang/internal/units"
 	"github.com/sachanritik1/go-lang/internal/utils"
 )
 
 type UserHandler struct {
-	store  store.UserStore
-	logger *log.Logger
+	store          store.UserStore
+	tokenStore     store.TokenStore
+	mailer         mailer.Mailer
+	background     BackgroundFunc
+	passwordPolicy *passwords.Policy
+	ttls           config.TokenConfig
+	logger         *log.Logger
 }
 
-func NewUserHandler(store store.UserStore, logger *log.Logger) *UserHandler {
-	return &UserHandler{store: store, logger: logger}
+func NewUserHandler(store store.UserStore, tokenStore store.TokenStore, mailer mailer.Mailer, background BackgroundFunc, passwordPolicy *passwords.Policy, ttls config.TokenConfig, logger *log.Logger) *UserHandler {
+	return &UserHandler{store: store, tokenStore: tokenStore, mailer: mailer, background: background, passwordPolicy: passwordPolicy, ttls: ttls, logger: logger}
 }
 
 type RegisterUserRequest struct {
@@ -33,38 +47,69 @@ type LoginUserRequest struct {
 	Password string `json:"password"`
 }
 
+type ActivateUserRequest struct {
+	Token string `json:"token"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
 type UpdateUserRequest struct {
 	Email    *string `json:"email,omitempty"`
-	Password *string `json:"password,omitempty"`
 	Bio      *string `json:"bio,omitempty"`
+	Units    *string `json:"units,omitempty"`
+	Timezone *string `json:"timezone,omitempty"`
+	// BodyWeight is the profile weight in the request's weight unit, used while no
+	// body_weight measurement exists; 0 clears it
+	BodyWeight *float64 `json:"body_weight,omitempty"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type DeleteUserRequest struct {
+	Password string `json:"password"`
 }
 
-func (h *UserHandler) validateRegisterUserRequest(req *RegisterUserRequest) error {
+var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+
+func (h *UserHandler) validateRegisterUserRequest(req *RegisterUserRequest) (utils.FieldErrors, error) {
+	fe := utils.FieldErrors{}
+
 	if req.Username == "" {
-		return errors.New("username is required")
-	}
-	if len(req.Username) < 3 || len(req.Username) > 10 {
-		return errors.New("username must be between 3 and 10 characters")
+		fe.Add("username", "username is required")
+	} else if len(req.Username) < 3 || len(req.Username) > 10 {
+		fe.Add("username", "username must be between 3 and 10 characters")
 	}
 
 	if req.Email == "" {
-		return errors.New("email is required")
-	}
-	emailRegex := regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
-	if !emailRegex.MatchString(req.Email) {
-		return errors.New("invalid email format")
+		fe.Add("email", "email is required")
+	} else if !emailRegex.MatchString(req.Email) {
+		fe.Add("email", "invalid email format")
 	}
 
-	if req.Password == "" {
-		return errors.New("password is required")
-	}
+	err := h.validatePassword(fe, "password", req.Password)
+	return fe, err
+}
 
-	// TODO: Uncomment and use the following regex for stronger password validation
-	// passwordRegex := regexp.MustCompile(`^(?=.*[a-z])(?=.*[A-Z])(?=.*\d)(?=.*[@$!%*?&])[A-Za-z\d@$!%*?&]{8,}$`)
-	// if !passwordRegex.MatchString(req.Password) {
-	// 	return errors.New("password must be at least 8 characters long, contain at least one uppercase letter, one lowercase letter, one number, and one special character")
-	// }
+// validatePassword adds the password policy's complaints about plainText to fe under field.
+func (h *UserHandler) validatePassword(fe utils.FieldErrors, field, plainText string) error {
+	if plainText == "" {
+		fe.Add(field, "password is required")
+		return nil
+	}
 
+	problems, err := h.passwordPolicy.Validate(plainText)
+	if err != nil {
+		return err
+	}
+	for _, problem := range problems {
+		fe.Add(field, "password "+problem)
+	}
 	return nil
 }
 
@@ -76,10 +121,14 @@ func (h *UserHandler) HandlerRegisterUser(w http.ResponseWriter, r *http.Request
 		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
 		return
 	}
-	err = h.validateRegisterUserRequest(&registerUserRequest)
+	fieldErrors, err := h.validateRegisterUserRequest(&registerUserRequest)
 	if err != nil {
 		h.logger.Printf("ERROR: validating register user request: %v", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not validate password"})
+		return
+	}
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
 		return
 	}
 
@@ -98,18 +147,331 @@ func (h *UserHandler) HandlerRegisterUser(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = h.store.CreateUser(user)
+	err = h.store.CreateUser(r.Context(), user)
 	if err != nil {
 		h.logger.Printf("ERROR: creating user: %v", err)
+		if errors.Is(err, store.ErrDuplicateEmail) || errors.Is(err, store.ErrDuplicateUsername) {
+			utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()})
+			return
+		}
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not create user"})
 		return
 	}
 
+	h.sendActivationEmail(user)
+
 	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"user": user})
 
 }
 
+// sendActivationEmail issues a fresh activation token and mails it in the background.
+func (h *UserHandler) sendActivationEmail(user *store.User) {
+	userID, username, email := user.ID, user.Username, user.Email
+	h.background(func(ctx context.Context) {
+		err := h.tokenStore.DeleteAllTokensForUser(ctx, int64(userID), tokens.ScopeActivation)
+		if err != nil {
+			h.logger.Printf("ERROR: deleting old activation tokens: %v", err)
+			return
+		}
+
+		token, err := h.tokenStore.CreateNewToken(ctx, int64(userID), h.ttls.ActivationTTL, tokens.ScopeActivation)
+		if err != nil {
+			h.logger.Printf("ERROR: creating activation token: %v", err)
+			return
+		}
+
+		body := fmt.Sprintf("Hi %s,\n\nUse the following token to activate your account. It expires at %s.\n\n%s\n",
+			username, token.Expiry.Format(time.RFC1123), token.PlainText)
+		err = h.mailer.Send(ctx, email, "Activate your account", body)
+		if err != nil {
+			h.logger.Printf("ERROR: sending activation email: %v", err)
+		}
+	})
+}
+
+func (h *UserHandler) HandlerActivateUser(w http.ResponseWriter, r *http.Request) {
+	var req ActivateUserRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Token == "" {
+		h.logger.Printf("ERROR: decoding activate user request: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	user, err := h.store.GetUserTokens(r.Context(), tokens.ScopeActivation, req.Token)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteJSON(w, http.StatusUnprocessableEntity, utils.Envelope{"error": "invalid or expired activation token"})
+			return
+		}
+		h.logger.Printf("ERROR: getting user for activation token: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not activate user"})
+		return
+	}
+
+	user.Activated = true
+	_, err = h.store.UpdateUser(r.Context(), user)
+	if err != nil {
+		h.logger.Printf("ERROR: activating user: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not activate user"})
+		return
+	}
+
+	err = h.tokenStore.DeleteAllTokensForUser(r.Context(), int64(user.ID), tokens.ScopeActivation)
+	if err != nil {
+		h.logger.Printf("ERROR: deleting activation tokens: %v", err)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"user": userToResponseUnits(user)})
+}
+
 func (h *UserHandler) HandleGetLoggedInUser(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r)
-	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"user": user})
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"user": userToResponseUnits(user)})
+}
+
+// HandlerResetPassword sets a new password using a password-reset token and
+// signs the user out everywhere, since the old password may have been compromised.
+func (h *UserHandler) HandlerResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding reset password request: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+	fieldErrors := utils.FieldErrors{}
+	if req.Token == "" {
+		fieldErrors.Add("token", "token is required")
+	}
+	err = h.validatePassword(fieldErrors, "password", req.Password)
+	if err != nil {
+		h.logger.Printf("ERROR: validating password: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not validate password"})
+		return
+	}
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	user, err := h.store.GetUserTokens(r.Context(), tokens.ScopePasswordReset, req.Token)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteJSON(w, http.StatusUnprocessableEntity, utils.Envelope{"error": "invalid or expired password reset token"})
+			return
+		}
+		h.logger.Printf("ERROR: getting user for password reset token: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not reset password"})
+		return
+	}
+
+	err = user.PasswordHash.Set(req.Password)
+	if err != nil {
+		h.logger.Printf("ERROR: setting password hash: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not process password"})
+		return
+	}
+
+	_, err = h.store.UpdateUser(r.Context(), user)
+	if err != nil {
+		h.logger.Printf("ERROR: updating user password: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not reset password"})
+		return
+	}
+
+	for _, scope := range []string{tokens.ScopePasswordReset, tokens.ScopeAuth, tokens.ScopeRefresh} {
+		err = h.tokenStore.DeleteAllTokensForUser(r.Context(), int64(user.ID), scope)
+		if err != nil {
+			h.logger.Printf("ERROR: revoking %s tokens after password reset: %v", scope, err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not reset password"})
+			return
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "password reset successfully"})
+}
+
+// HandlerUpdateUser updates the caller's email and bio. A new email address has
+// to be verified again, so changing it deactivates the account until then.
+func (h *UserHandler) HandlerUpdateUser(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	var req UpdateUserRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding update user request: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	emailChanged := false
+	if req.Email != nil && *req.Email != user.Email {
+		if !emailRegex.MatchString(*req.Email) {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid email format"})
+			return
+		}
+		user.Email = *req.Email
+		user.Activated = false
+		emailChanged = true
+	}
+	if req.Bio != nil {
+		user.Bio = *req.Bio
+	}
+	if req.Units != nil {
+		if !units.IsValidSystem(*req.Units) {
+			utils.WriteFieldErrors(w, http.StatusBadRequest, utils.FieldErrors{"units": {"must be metric or imperial"}})
+			return
+		}
+		user.Units = *req.Units
+	}
+	if req.Timezone != nil {
+		if _, err := time.LoadLocation(*req.Timezone); err != nil || *req.Timezone == "" || *req.Timezone == "Local" {
+			utils.WriteFieldErrors(w, http.StatusBadRequest, utils.FieldErrors{"timezone": {"must be an IANA time zone such as Europe/Berlin"}})
+			return
+		}
+		user.Timezone = *req.Timezone
+	}
+	if req.BodyWeight != nil {
+		// sent in the ?units= system when given, like every other weight
+		fieldErrors := utils.FieldErrors{}
+		system := readUnitSystem(r, user, fieldErrors)
+		if len(fieldErrors) > 0 {
+			utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+			return
+		}
+		switch {
+		case *req.BodyWeight < 0 || *req.BodyWeight > 1000:
+			utils.WriteFieldErrors(w, http.StatusBadRequest, utils.FieldErrors{"body_weight": {"must be between 0 and 1000"}})
+			return
+		case *req.BodyWeight == 0:
+			user.ProfileBodyWeight = nil
+		default:
+			kilograms := units.Round(units.ToKilograms(*req.BodyWeight, units.WeightUnit(system)), 2)
+			user.ProfileBodyWeight = &kilograms
+		}
+	}
+
+	_, err = h.store.UpdateUser(r.Context(), user)
+	if errors.Is(err, store.ErrUnknownTimezone) {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, utils.FieldErrors{"timezone": {"must be an IANA time zone such as Europe/Berlin"}})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: updating user: %v", err)
+		if errors.Is(err, store.ErrDuplicateEmail) {
+			utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()})
+			return
+		}
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not update user"})
+		return
+	}
+
+	if emailChanged {
+		h.sendActivationEmail(user)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"user": userToResponseUnits(user)})
+}
+
+// HandlerChangePassword replaces the caller's password and signs out every
+// other session; the session making the request stays logged in.
+func (h *UserHandler) HandlerChangePassword(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	var req ChangePasswordRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding change password request: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+	if !h.checkPassword(w, user, req.CurrentPassword) {
+		return
+	}
+
+	fieldErrors := utils.FieldErrors{}
+	err = h.validatePassword(fieldErrors, "new_password", req.NewPassword)
+	if err != nil {
+		h.logger.Printf("ERROR: validating password: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not validate password"})
+		return
+	}
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	err = user.PasswordHash.Set(req.NewPassword)
+	if err != nil {
+		h.logger.Printf("ERROR: setting password hash: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not process password"})
+		return
+	}
+
+	_, err = h.store.UpdateUser(r.Context(), user)
+	if err != nil {
+		h.logger.Printf("ERROR: updating user password: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not change password"})
+		return
+	}
+
+	err = h.tokenStore.DeleteOtherSessions(r.Context(), int64(user.ID), middleware.GetToken(r))
+	if err == nil {
+		err = h.tokenStore.DeleteAllTokensForUser(r.Context(), int64(user.ID), tokens.ScopePasswordReset)
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: revoking sessions after password change: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not revoke other sessions"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "password changed successfully"})
+}
+
+func (h *UserHandler) HandlerDeleteUser(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	var req DeleteUserRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding delete user request: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	if !h.checkPassword(w, user, req.Password) {
+		return
+	}
+
+	// tokens and workouts go with the user through ON DELETE CASCADE
+	err = h.store.DeleteUser(r.Context(), user.ID)
+	if err != nil {
+		h.logger.Printf("ERROR: deleting user: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not delete user"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "user deleted successfully"})
+}
+
+// checkPassword writes the error response and returns false unless plainText is the user's password.
+func (h *UserHandler) checkPassword(w http.ResponseWriter, user *store.User, plainText string) bool {
+	if plainText == "" {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "password is required"})
+		return false
+	}
+
+	matches, err := user.PasswordHash.Matches(plainText)
+	if err != nil {
+		h.logger.Printf("ERROR: checking password match: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return false
+	}
+	if !matches {
+		h.logger.Printf("WARNING: invalid password confirmation for user %s", user.Username)
+		utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid credentials"})
+		return false
+	}
+	return true
 }
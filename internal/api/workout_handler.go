@@ -3,11 +3,19 @@ package api
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/sachanritik1/go-lang/internal/middleware"
 	"github.com/sachanritik1/go-lang/internal/store"
+	"github.com/sachanritik1/go-lang/internal/units"
 	"github.com/sachanritik1/go-lang/internal/utils"
 )
 
@@ -37,17 +45,116 @@ func (h *WorkoutHandler) HandlerCreateWorkout(w http.ResponseWriter, r *http.Req
 	}
 
 	workout.UserID = currentUser.ID
+	// calories left out are estimated by the store, which sets the flag
+	workout.CaloriesEstimated = false
 
-	createdWorkout, err := h.store.CreateWorkout(&workout)
+	fieldErrors := utils.FieldErrors{}
+	system := readUnitSystem(r, currentUser, fieldErrors)
+	validateWorkoutEntries(fieldErrors, "entries", workout.Entries)
+	if workout.Status != "" && !store.IsValidWorkoutStatus(workout.Status) {
+		fieldErrors.Add("status", "must be in_progress or completed")
+	}
+	if workout.Status == store.WorkoutInProgress && workout.FinishedAt != nil {
+		fieldErrors.Add("finished_at", "must not be set for a workout in progress")
+	}
+	if workout.StartedAt != nil && workout.FinishedAt != nil && workout.FinishedAt.Before(*workout.StartedAt) {
+		fieldErrors.Add("finished_at", "must not be before started_at")
+	}
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+	toCanonicalUnits(workout.Entries, system)
+
+	createdWorkout, err := h.store.CreateWorkout(r.Context(), &workout)
+	if errors.Is(err, store.ErrUnknownExercise) {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, utils.FieldErrors{"entries": {"every entry needs a known exercise_id or an exercise_name"}})
+		return
+	}
+	if writeSessionError(w, err) {
+		return
+	}
 	if err != nil {
 		h.logger.Printf("ERROR: creating workout: %v", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not create workout"})
 		return
 	}
 
+	toResponseUnits(createdWorkout, system)
 	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"workout": createdWorkout})
 }
 
+// writeSessionError answers errors about the scheduled session a workout links
+// to and reports whether it did.
+func writeSessionError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, store.ErrUnknownSession):
+		utils.WriteFieldErrors(w, http.StatusBadRequest, utils.FieldErrors{"program_day_id": {"must be a day of the program of one of your enrollments, given with enrollment_id"}})
+	case errors.Is(err, store.ErrSessionAlreadyLogged):
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()})
+	default:
+		return false
+	}
+	return true
+}
+
+// validateWorkoutEntries checks the nested sets of each entry, keyed like
+// "entries[0].sets[2]" so clients can point at the offending set.
+func validateWorkoutEntries(fe utils.FieldErrors, field string, entries []store.WorkoutEntry) {
+	for i, entry := range entries {
+		validateSets(fe, fmt.Sprintf("%s[%d].sets", field, i), entry.Sets)
+		validateRestTarget(fe, fmt.Sprintf("%s[%d].rest_target_seconds", field, i), entry.RestTargetSeconds)
+	}
+}
+
+func validateRestTarget(fe utils.FieldErrors, field string, seconds *int) {
+	if seconds != nil && *seconds < 0 {
+		fe.Add(field, "must not be negative")
+	}
+}
+
+func validateSets(fe utils.FieldErrors, field string, sets []store.WorkoutSet) {
+	if len(sets) == 0 {
+		fe.Add(field, "must contain at least one set")
+	}
+	for j, set := range sets {
+		setField := fmt.Sprintf("%s[%d]", field, j)
+		if (set.Reps == nil) == (set.DurationSeconds == nil) {
+			fe.Add(setField, "must have either reps or duration_seconds")
+		}
+		if set.Reps != nil && *set.Reps < 0 {
+			fe.Add(setField+".reps", "must not be negative")
+		}
+		if set.DurationSeconds != nil && *set.DurationSeconds <= 0 {
+			fe.Add(setField+".duration_seconds", "must be positive")
+		}
+		if set.Weight != nil && *set.Weight < 0 {
+			fe.Add(setField+".weight", "must not be negative")
+		}
+		if set.WeightUnit != "" && !units.IsValidWeightUnit(set.WeightUnit) {
+			fe.Add(setField+".weight_unit", "must be kg or lb")
+		}
+		if set.Distance != nil && *set.Distance < 0 {
+			fe.Add(setField+".distance", "must not be negative")
+		}
+		if set.DistanceUnit != "" && !units.IsValidDistanceUnit(set.DistanceUnit) {
+			fe.Add(setField+".distance_unit", "must be one of m, km, mi")
+		}
+		if set.RPE != nil && (*set.RPE < 1 || *set.RPE > 10) {
+			fe.Add(setField+".rpe", "must be between 1 and 10")
+		}
+		if set.SetType != "" && !store.IsValidSetType(set.SetType) {
+			fe.Add(setField+".set_type", "must be one of warmup, working, drop, failure")
+		}
+		if set.StartedAt != nil && set.CompletedAt != nil && set.CompletedAt.Before(*set.StartedAt) {
+			fe.Add(setField+".completed_at", "must not be before started_at")
+		}
+		if set.RestSeconds != nil && *set.RestSeconds < 0 {
+			fe.Add(setField+".rest_seconds", "must not be negative")
+		}
+	}
+}
+
 func (h *WorkoutHandler) HandlerGetWorkoutByID(w http.ResponseWriter, r *http.Request) {
 	workoutID, err := utils.ReadIDParam(r)
 	if err != nil {
@@ -56,7 +163,14 @@ func (h *WorkoutHandler) HandlerGetWorkoutByID(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	workout, err := h.store.GetWorkoutByID(int(workoutID))
+	fieldErrors := utils.FieldErrors{}
+	system := readUnitSystem(r, middleware.GetUser(r), fieldErrors)
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	workout, err := h.store.GetWorkoutByID(r.Context(), int(workoutID))
 	if err != nil {
 		h.logger.Printf("ERROR: getting workout by ID: %v", err)
 		if err == sql.ErrNoRows {
@@ -67,6 +181,7 @@ func (h *WorkoutHandler) HandlerGetWorkoutByID(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	toResponseUnits(workout, system)
 	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": workout})
 
 }
@@ -79,14 +194,103 @@ func (h *WorkoutHandler) HandlerGetAllWorkouts(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	workouts, err := h.store.ListWorkouts(user.ID)
+	filter, fieldErrors := readWorkoutFilter(r.URL.Query())
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	page, err := h.store.ListWorkouts(r.Context(), user.ID, filter)
 	if err != nil {
+		if errors.Is(err, store.ErrInvalidCursor) {
+			utils.WriteFieldErrors(w, http.StatusBadRequest, utils.FieldErrors{"cursor": {err.Error()}})
+			return
+		}
 		h.logger.Printf("ERROR: listing workouts: %v", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not retrieve workouts"})
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workouts": workouts})
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{
+		"workouts": page.Workouts,
+		"pagination": utils.Envelope{
+			"limit":       filter.Limit,
+			"next_cursor": page.NextCursor,
+			"has_more":    page.NextCursor != "",
+		},
+	})
+}
+
+// readWorkoutFilter parses the GET /workouts query string. Dates accept
+// RFC 3339 timestamps or plain YYYY-MM-DD days; "to" is exclusive.
+func readWorkoutFilter(qs url.Values) (store.WorkoutFilter, utils.FieldErrors) {
+	fe := utils.FieldErrors{}
+	filter := store.WorkoutFilter{
+		Search:     strings.TrimSpace(qs.Get("q")),
+		Exercise:   strings.TrimSpace(qs.Get("exercise")),
+		Sort:       store.SortByDate,
+		Descending: true,
+		Limit:      store.DefaultWorkoutPageSize,
+		Cursor:     qs.Get("cursor"),
+	}
+
+	readTime := func(key string) *time.Time {
+		v := qs.Get(key)
+		if v == "" {
+			return nil
+		}
+		for _, layout := range []string{time.RFC3339, time.DateOnly} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return &t
+			}
+		}
+		fe.Add(key, "must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+		return nil
+	}
+	readInt := func(key string, minValue int) *int {
+		v := qs.Get(key)
+		if v == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < minValue {
+			fe.Add(key, fmt.Sprintf("must be an integer of at least %d", minValue))
+			return nil
+		}
+		return &n
+	}
+
+	filter.From = readTime("from")
+	filter.To = readTime("to")
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		fe.Add("to", "must be after from")
+	}
+	filter.ExerciseID = readInt("exercise_id", 1)
+	filter.MinDuration = readInt("min_duration", 0)
+	filter.MinCalories = readInt("min_calories", 0)
+
+	if limit := readInt("limit", 1); limit != nil {
+		if *limit > store.MaxWorkoutPageSize {
+			fe.Add("limit", fmt.Sprintf("must not exceed %d", store.MaxWorkoutPageSize))
+		}
+		filter.Limit = *limit
+	}
+
+	if sort := qs.Get("sort"); sort != "" {
+		if !store.IsValidWorkoutSort(sort) {
+			fe.Add("sort", "must be one of date, duration, calories")
+		}
+		filter.Sort = sort
+	}
+	switch qs.Get("order") {
+	case "", "desc":
+	case "asc":
+		filter.Descending = false
+	default:
+		fe.Add("order", "must be asc or desc")
+	}
+
+	return filter, fe
 }
 
 func (h *WorkoutHandler) HandlerDeleteWorkout(w http.ResponseWriter, r *http.Request) {
@@ -104,7 +308,7 @@ func (h *WorkoutHandler) HandlerDeleteWorkout(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	ownerID, err := h.store.GetWorkoutOwner(workoutID)
+	ownerID, err := h.store.GetWorkoutOwner(r.Context(), workoutID)
 	if err != nil {
 		h.logger.Printf("ERROR: getting workout owner: %v", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not verify workout ownership"})
@@ -116,7 +320,7 @@ func (h *WorkoutHandler) HandlerDeleteWorkout(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	err = h.store.DeleteWorkout(int(workoutID))
+	err = h.store.DeleteWorkout(r.Context(), int(workoutID))
 	if err != nil {
 		h.logger.Printf("ERROR: deleting workout: %v", err)
 		if err == sql.ErrNoRows {
@@ -146,7 +350,7 @@ func (h *WorkoutHandler) HandlerUpdateWorkout(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	ownerID, err := h.store.GetWorkoutOwner(workoutID)
+	ownerID, err := h.store.GetWorkoutOwner(r.Context(), workoutID)
 	if err != nil {
 		h.logger.Printf("ERROR: getting workout owner: %v", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not verify workout ownership"})
@@ -159,7 +363,7 @@ func (h *WorkoutHandler) HandlerUpdateWorkout(w http.ResponseWriter, r *http.Req
 	}
 
 	// find workout by id
-	workout, err := h.store.GetWorkoutByID(int(workoutID))
+	workout, err := h.store.GetWorkoutByID(r.Context(), int(workoutID))
 	if err != nil {
 		h.logger.Printf("ERROR: getting workout by ID: %v", err)
 		if err == sql.ErrNoRows {
@@ -176,6 +380,9 @@ func (h *WorkoutHandler) HandlerUpdateWorkout(w http.ResponseWriter, r *http.Req
 		Description     *string              `json:"description"`
 		DurationMinutes *int                 `json:"duration_minutes"`
 		CaloriesBurned  *int                 `json:"calories_burned"`
+		PerformedAt     *time.Time           `json:"performed_at"`
+		EnrollmentID    *int                 `json:"enrollment_id"` // 0 unlinks the workout from its session
+		ProgramDayID    *int                 `json:"program_day_id"`
 		Entries         []store.WorkoutEntry `json:"workout_entries"`
 	}
 
@@ -197,18 +404,295 @@ func (h *WorkoutHandler) HandlerUpdateWorkout(w http.ResponseWriter, r *http.Req
 	}
 	if UpdateWorkoutRequest.CaloriesBurned != nil {
 		workout.CaloriesBurned = *UpdateWorkoutRequest.CaloriesBurned
+		workout.CaloriesEstimated = false
+	}
+	if UpdateWorkoutRequest.PerformedAt != nil {
+		workout.PerformedAt = *UpdateWorkoutRequest.PerformedAt
 	}
+	if UpdateWorkoutRequest.EnrollmentID != nil {
+		workout.EnrollmentID, workout.ProgramDayID = UpdateWorkoutRequest.EnrollmentID, UpdateWorkoutRequest.ProgramDayID
+		if *UpdateWorkoutRequest.EnrollmentID == 0 {
+			workout.EnrollmentID, workout.ProgramDayID = nil, nil
+		}
+	}
+	fieldErrors := utils.FieldErrors{}
+	system := readUnitSystem(r, currentUser, fieldErrors)
 	if UpdateWorkoutRequest.Entries != nil {
+		validateWorkoutEntries(fieldErrors, "workout_entries", UpdateWorkoutRequest.Entries)
+		toCanonicalUnits(UpdateWorkoutRequest.Entries, system)
 		workout.Entries = UpdateWorkoutRequest.Entries
 	}
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
 
 	// update workout
-	updatedWorkout, err := h.store.UpdateWorkout(workout)
+	updatedWorkout, err := h.store.UpdateWorkout(r.Context(), workout)
+	if errors.Is(err, store.ErrUnknownExercise) {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, utils.FieldErrors{"workout_entries": {"every entry needs a known exercise_id or an exercise_name"}})
+		return
+	}
+	if writeSessionError(w, err) {
+		return
+	}
 	if err != nil {
 		h.logger.Printf("ERROR: updating workout: %v", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not update workout"})
 		return
 	}
 
+	toResponseUnits(updatedWorkout, system)
 	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": updatedWorkout})
 }
+
+// authorizeWorkout reads the {id} workout parameter and checks that the
+// current user owns that workout. It answers the request itself when not.
+func (h *WorkoutHandler) authorizeWorkout(w http.ResponseWriter, r *http.Request) (int, bool) {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
+		h.logger.Printf("ERROR: reading ID parameter: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout ID parameter"})
+		return 0, false
+	}
+
+	currentUser := middleware.GetUser(r)
+	ownerID, err := h.store.GetWorkoutOwner(r.Context(), workoutID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "workout not found"})
+		return 0, false
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: getting workout owner: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not verify workout ownership"})
+		return 0, false
+	}
+	if ownerID != currentUser.ID {
+		h.logger.Printf("ERROR: user %d trying to change workout owned by user %d", currentUser.ID, ownerID)
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "you do not have permission to update this workout"})
+		return 0, false
+	}
+	return workoutID, true
+}
+
+// HandlerAddWorkoutEntry logs one more entry to a workout, typically a live
+// session, without resending the others.
+func (h *WorkoutHandler) HandlerAddWorkoutEntry(w http.ResponseWriter, r *http.Request) {
+	workoutID, ok := h.authorizeWorkout(w, r)
+	if !ok {
+		return
+	}
+
+	var entry store.WorkoutEntry
+	err := json.NewDecoder(r.Body).Decode(&entry)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding add workout entry request: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	fieldErrors := utils.FieldErrors{}
+	system := readUnitSystem(r, middleware.GetUser(r), fieldErrors)
+	validateSets(fieldErrors, "sets", entry.Sets)
+	validateRestTarget(fieldErrors, "rest_target_seconds", entry.RestTargetSeconds)
+	if entry.OrderIndex < 0 {
+		fieldErrors.Add("order_index", "must not be negative")
+	}
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+	setsToCanonicalUnits(entry.Sets, system)
+
+	err = h.store.AddWorkoutEntry(r.Context(), workoutID, &entry)
+	if errors.Is(err, store.ErrUnknownExercise) {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, utils.FieldErrors{"exercise_id": {"must be a known exercise, or give an exercise_name"}})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: adding workout entry: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not add workout entry"})
+		return
+	}
+
+	setsToResponseUnits(entry.Sets, system)
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"entry": entry})
+}
+
+// HandlerUpdateWorkoutEntry changes the fields of one entry that are present
+// in the body; sets, when given, replace the entry's sets.
+func (h *WorkoutHandler) HandlerUpdateWorkoutEntry(w http.ResponseWriter, r *http.Request) {
+	workoutID, ok := h.authorizeWorkout(w, r)
+	if !ok {
+		return
+	}
+	entryID, err := utils.ReadIntParam(r, "entryID")
+	if err != nil {
+		h.logger.Printf("ERROR: reading entry ID parameter: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid entry ID parameter"})
+		return
+	}
+
+	entry, err := h.store.GetWorkoutEntry(r.Context(), workoutID, entryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "workout entry not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: getting workout entry: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not retrieve workout entry"})
+		return
+	}
+
+	var req struct {
+		ExerciseID   *int    `json:"exercise_id"`
+		ExerciseName *string `json:"exercise_name"`
+		Notes        *string `json:"notes"`
+		OrderIndex   *int    `json:"order_index"`
+		// RestTargetSeconds of 0 clears the target
+		RestTargetSeconds *int               `json:"rest_target_seconds"`
+		Sets              []store.WorkoutSet `json:"sets"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		h.logger.Printf("ERROR: decoding update workout entry request: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	if req.ExerciseName != nil {
+		// a new name is matched against the catalog again
+		entry.ExerciseID, entry.ExerciseName = 0, *req.ExerciseName
+	}
+	if req.ExerciseID != nil {
+		entry.ExerciseID = *req.ExerciseID
+	}
+	if req.Notes != nil {
+		entry.Notes = *req.Notes
+	}
+	if req.OrderIndex != nil {
+		entry.OrderIndex = *req.OrderIndex
+	}
+	if req.RestTargetSeconds != nil {
+		entry.RestTargetSeconds = req.RestTargetSeconds
+		if *req.RestTargetSeconds == 0 {
+			entry.RestTargetSeconds = nil
+		}
+	}
+
+	fieldErrors := utils.FieldErrors{}
+	system := readUnitSystem(r, middleware.GetUser(r), fieldErrors)
+	if req.Sets != nil {
+		validateSets(fieldErrors, "sets", req.Sets)
+		setsToCanonicalUnits(req.Sets, system)
+		entry.Sets = req.Sets
+	}
+	validateRestTarget(fieldErrors, "rest_target_seconds", entry.RestTargetSeconds)
+	if entry.OrderIndex < 0 {
+		fieldErrors.Add("order_index", "must not be negative")
+	}
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	err = h.store.UpdateWorkoutEntry(r.Context(), workoutID, entry)
+	if errors.Is(err, store.ErrUnknownExercise) {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, utils.FieldErrors{"exercise_id": {"must be a known exercise, or give an exercise_name"}})
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "workout entry not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: updating workout entry: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not update workout entry"})
+		return
+	}
+
+	setsToResponseUnits(entry.Sets, system)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"entry": entry})
+}
+
+func (h *WorkoutHandler) HandlerDeleteWorkoutEntry(w http.ResponseWriter, r *http.Request) {
+	workoutID, ok := h.authorizeWorkout(w, r)
+	if !ok {
+		return
+	}
+	entryID, err := utils.ReadIntParam(r, "entryID")
+	if err != nil {
+		h.logger.Printf("ERROR: reading entry ID parameter: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid entry ID parameter"})
+		return
+	}
+
+	err = h.store.DeleteWorkoutEntry(r.Context(), workoutID, entryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "workout entry not found"})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: deleting workout entry: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not delete workout entry"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "workout entry deleted successfully"})
+}
+
+// HandlerFinishWorkout completes a live session at finished_at, now by
+// default, deriving its duration from when it started.
+func (h *WorkoutHandler) HandlerFinishWorkout(w http.ResponseWriter, r *http.Request) {
+	workoutID, ok := h.authorizeWorkout(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		FinishedAt *time.Time `json:"finished_at"`
+	}
+	// an empty body finishes the session now
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		h.logger.Printf("ERROR: decoding finish workout request: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	fieldErrors := utils.FieldErrors{}
+	system := readUnitSystem(r, middleware.GetUser(r), fieldErrors)
+	if len(fieldErrors) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	workout, err := h.store.GetWorkoutByID(r.Context(), workoutID)
+	if err != nil {
+		h.logger.Printf("ERROR: getting workout by ID: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not retrieve workout"})
+		return
+	}
+	finishedAt := time.Now()
+	if req.FinishedAt != nil {
+		finishedAt = *req.FinishedAt
+	}
+	if workout.StartedAt != nil && finishedAt.Before(*workout.StartedAt) {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, utils.FieldErrors{"finished_at": {"must not be before the workout started"}})
+		return
+	}
+
+	finished, err := h.store.FinishWorkout(r.Context(), workoutID, finishedAt)
+	if errors.Is(err, store.ErrWorkoutNotInProgress) {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Printf("ERROR: finishing workout: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "could not finish workout"})
+		return
+	}
+
+	toResponseUnits(finished, system)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": finished})
+}
@@ -1,29 +1,55 @@
 package app
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/sachanritik1/go-lang/internal/api"
+	"github.com/sachanritik1/go-lang/internal/config"
+	"github.com/sachanritik1/go-lang/internal/mailer"
 	"github.com/sachanritik1/go-lang/internal/middleware"
+	"github.com/sachanritik1/go-lang/internal/passwords"
 	"github.com/sachanritik1/go-lang/internal/store"
+	"github.com/sachanritik1/go-lang/internal/throttle"
 	"github.com/sachanritik1/go-lang/migrations"
 )
 
 type App struct {
-	Logger         *log.Logger
-	WorkoutHandler *api.WorkoutHandler
-	UserHandler    *api.UserHandler
-	TokenHandler   *api.TokenHandler
-	Middleware     middleware.UserMiddleware
-	DB             *sql.DB
+	Config             *config.Config
+	Logger             *log.Logger
+	WorkoutHandler     *api.WorkoutHandler
+	UserHandler        *api.UserHandler
+	TokenHandler       *api.TokenHandler
+	ExerciseHandler    *api.ExerciseHandler
+	RecordHandler      *api.RecordHandler
+	StatsHandler       *api.StatsHandler
+	TemplateHandler    *api.TemplateHandler
+	ProgramHandler     *api.ProgramHandler
+	MeasurementHandler *api.MeasurementHandler
+	GoalHandler        *api.GoalHandler
+	StreakHandler      *api.StreakHandler
+	Middleware         middleware.UserMiddleware
+	DB                 *sql.DB
+
+	// ctx is the root context handed to background jobs; cancel stops them on shutdown.
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	handlers sync.WaitGroup
+	inFlight atomic.Int64
 }
 
-func NewApp() (*App, error) {
-	pgDB, err := store.Open()
+func NewApp(cfg *config.Config) (*App, error) {
+	pgDB, err := store.Open(cfg.DB)
 	if err != nil {
 		return nil, err
 	}
@@ -33,32 +59,174 @@ func NewApp() (*App, error) {
 		panic(err)
 	}
 
-	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
+	logger := log.New(newLevelWriter(os.Stdout, cfg.LogLevel), "", log.Ldate|log.Ltime)
+
+	app := &App{
+		Config: cfg,
+		Logger: logger,
+		DB:     pgDB,
+	}
+	app.ctx, app.cancel = context.WithCancel(context.Background())
+
+	appMailer, err := newMailer(cfg.Mailer)
+	if err != nil {
+		return nil, err
+	}
+
+	passwordPolicy, err := newPasswordPolicy(cfg.Password)
+	if err != nil {
+		return nil, err
+	}
 
 	//stores
-	workoutStore := store.NewPostgresWorkoutStore(pgDB)
-	userStore := store.NewPostgresUserStore(pgDB)
-	tokenStore := store.NewPostgresTokenStore(pgDB)
+	workoutStore := store.NewPostgresWorkoutStore(pgDB, cfg.DB.QueryTimeout)
+	userStore := store.NewPostgresUserStore(pgDB, cfg.DB.QueryTimeout)
+	tokenStore := store.NewPostgresTokenStore(pgDB, cfg.DB.QueryTimeout)
+	exerciseStore := store.NewPostgresExerciseStore(pgDB, cfg.DB.QueryTimeout)
+	recordStore := store.NewPostgresRecordStore(pgDB, cfg.DB.QueryTimeout)
+	statsStore := store.NewPostgresStatsStore(pgDB, cfg.DB.QueryTimeout)
+	templateStore := store.NewPostgresTemplateStore(pgDB, cfg.DB.QueryTimeout)
+	programStore := store.NewPostgresProgramStore(pgDB, cfg.DB.QueryTimeout)
+	measurementStore := store.NewPostgresMeasurementStore(pgDB, cfg.DB.QueryTimeout)
+	goalStore := store.NewPostgresGoalStore(pgDB, cfg.DB.QueryTimeout)
+	streakStore := store.NewPostgresStreakStore(pgDB, cfg.DB.QueryTimeout)
 
 	//handlers
 	workoutHandler := api.NewWorkoutHandler(workoutStore, logger)
-	userHandler := api.NewUserHandler(userStore, logger)
-	tokenHandler := api.NewTokenHandler(tokenStore, userStore, logger)
+	userHandler := api.NewUserHandler(userStore, tokenStore, appMailer, app.Background, passwordPolicy, cfg.Tokens, logger)
+	loginThrottle := newLoginThrottle(cfg.Login, pgDB, cfg.DB.QueryTimeout)
+	tokenHandler := api.NewTokenHandler(tokenStore, userStore, appMailer, app.Background, loginThrottle, cfg.Tokens, logger)
+	exerciseHandler := api.NewExerciseHandler(exerciseStore, logger)
+	recordHandler := api.NewRecordHandler(recordStore, logger)
+	statsHandler := api.NewStatsHandler(statsStore, logger)
+	templateHandler := api.NewTemplateHandler(templateStore, workoutStore, logger)
+	programHandler := api.NewProgramHandler(programStore, logger)
+	measurementHandler := api.NewMeasurementHandler(measurementStore, logger)
+	goalHandler := api.NewGoalHandler(goalStore, logger)
+	streakHandler := api.NewStreakHandler(streakStore, logger)
 
 	//middleware
-	userMiddleware := middleware.UserMiddleware{UserStore: userStore}
+	userMiddleware := middleware.UserMiddleware{UserStore: userStore, TokenStore: tokenStore, Logger: logger}
+
+	app.WorkoutHandler = workoutHandler
+	app.UserHandler = userHandler
+	app.TokenHandler = tokenHandler
+	app.ExerciseHandler = exerciseHandler
+	app.RecordHandler = recordHandler
+	app.StatsHandler = statsHandler
+	app.TemplateHandler = templateHandler
+	app.ProgramHandler = programHandler
+	app.MeasurementHandler = measurementHandler
+	app.GoalHandler = goalHandler
+	app.StreakHandler = streakHandler
+	app.Middleware = userMiddleware
+
+	app.Background(func(ctx context.Context) {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				for _, tracker := range []throttle.Tracker{loginThrottle.Users, loginThrottle.IPs} {
+					err := tracker.Prune(ctx)
+					if err != nil && ctx.Err() == nil {
+						logger.Printf("ERROR: pruning login attempts: %v", err)
+					}
+				}
+			}
+		}
+	})
 
-	app := &App{
-		Logger:         logger,
-		WorkoutHandler: workoutHandler,
-		UserHandler:    userHandler,
-		TokenHandler:   tokenHandler,
-		Middleware:     userMiddleware,
-		DB:             pgDB,
-	}
 	return app, nil
 }
 
+func newPasswordPolicy(cfg config.PasswordConfig) (*passwords.Policy, error) {
+	policy := &passwords.Policy{
+		MinLength:     cfg.MinLength,
+		RequireUpper:  cfg.RequireUpper,
+		RequireLower:  cfg.RequireLower,
+		RequireDigit:  cfg.RequireDigit,
+		RequireSymbol: cfg.RequireSymbol,
+		Breached:      passwords.NewBundledList(),
+	}
+	if cfg.BreachedDir != "" {
+		list, err := passwords.NewPrefixDirList(cfg.BreachedDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open breached password list: %w", err)
+		}
+		policy.Breached = list
+	}
+	return policy, nil
+}
+
+func newLoginThrottle(cfg config.LoginConfig, db *sql.DB, queryTimeout time.Duration) api.LoginThrottle {
+	policy := throttle.Policy{BaseLockout: cfg.BaseLockout, MaxLockout: cfg.MaxLockout, Window: cfg.Window}
+	userPolicy, ipPolicy := policy, policy
+	userPolicy.MaxAttempts = cfg.MaxAttemptsPerUser
+	ipPolicy.MaxAttempts = cfg.MaxAttemptsPerIP
+
+	if cfg.Tracker == config.TrackerPostgres {
+		return api.LoginThrottle{
+			Users:             store.NewPostgresLoginAttemptStore(db, userPolicy, queryTimeout),
+			IPs:               store.NewPostgresLoginAttemptStore(db, ipPolicy, queryTimeout),
+			TrustForwardedFor: cfg.TrustForwardedFor,
+		}
+	}
+	return api.LoginThrottle{
+		Users:             throttle.NewMemoryTracker(userPolicy),
+		IPs:               throttle.NewMemoryTracker(ipPolicy),
+		TrustForwardedFor: cfg.TrustForwardedFor,
+	}
+}
+
+func newMailer(cfg config.MailerConfig) (mailer.Mailer, error) {
+	switch cfg.Driver {
+	case config.MailerSMTP:
+		return mailer.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.Sender), nil
+	case config.MailerFile:
+		return mailer.NewFileMailer(cfg.Dir)
+	default:
+		return mailer.NewMemoryMailer(), nil
+	}
+}
+
 func (app *App) HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "Status is available")
 }
+
+// levelWriter drops log lines tagged below the configured level. Handlers tag
+// their messages with "DEBUG:", "WARNING:" or "ERROR:"; untagged lines count as info.
+type levelWriter struct {
+	out      io.Writer
+	minLevel int
+}
+
+var logLevels = map[string]int{
+	config.LogLevelDebug: 0,
+	config.LogLevelInfo:  1,
+	config.LogLevelWarn:  2,
+	config.LogLevelError: 3,
+}
+
+func newLevelWriter(out io.Writer, level string) *levelWriter {
+	return &levelWriter{out: out, minLevel: logLevels[level]}
+}
+
+func (lw *levelWriter) Write(p []byte) (int, error) {
+	line := string(p)
+	level := logLevels[config.LogLevelInfo]
+	switch {
+	case strings.Contains(line, "DEBUG:"):
+		level = logLevels[config.LogLevelDebug]
+	case strings.Contains(line, "WARNING:"):
+		level = logLevels[config.LogLevelWarn]
+	case strings.Contains(line, "ERROR:"):
+		level = logLevels[config.LogLevelError]
+	}
+	if level < lw.minLevel {
+		return len(p), nil
+	}
+	return lw.out.Write(p)
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Background runs fn in its own goroutine with the application's root context.
+// The context is cancelled during shutdown and Serve waits for fn to return.
+func (app *App) Background(fn func(ctx context.Context)) {
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		defer func() {
+			if err := recover(); err != nil {
+				app.Logger.Printf("ERROR: background job panicked: %v", err)
+			}
+		}()
+		fn(app.ctx)
+	}()
+}
+
+// trackRequests counts in-flight requests so shutdown can report how many it
+// drained, and wait for handlers that outlive the shutdown deadline.
+func (app *App) trackRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.handlers.Add(1)
+		app.inFlight.Add(1)
+		defer app.handlers.Done()
+		defer app.inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Serve runs the HTTP server until SIGINT or SIGTERM, then drains in-flight
+// requests within the configured shutdown timeout and stops background jobs.
+// Serve only returns once every handler has, so the caller can close the
+// database then; background jobs still running at the deadline are reported.
+func (app *App) Serve(handler http.Handler) error {
+	server := &http.Server{
+		Addr:         fmt.Sprintf(":%d", app.Config.Port),
+		Handler:      app.trackRequests(handler),
+		IdleTimeout:  app.Config.Server.IdleTimeout,
+		ReadTimeout:  app.Config.Server.ReadTimeout,
+		WriteTimeout: app.Config.Server.WriteTimeout,
+	}
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		s := <-quit
+		signal.Stop(quit)
+
+		pending := app.inFlight.Load()
+		app.Logger.Printf("Received %s, shutting down with %d in-flight requests", s, pending)
+
+		ctx, cancel := context.WithTimeout(context.Background(), app.Config.Server.ShutdownTimeout)
+		defer cancel()
+
+		err := server.Shutdown(ctx)
+		remaining := app.inFlight.Load()
+		app.Logger.Printf("Drained %d of %d in-flight requests", pending-remaining, pending)
+		if err != nil {
+			// dropping the connections cancels the remaining requests' contexts,
+			// and the store's query timeouts bound whatever they are waiting on
+			app.Logger.Printf("WARNING: closing %d requests still running at the shutdown deadline", remaining)
+			server.Close()
+			app.handlers.Wait()
+		}
+
+		// stop background workers only after handlers are done with them
+		app.cancel()
+		jobsDone := make(chan struct{})
+		go func() {
+			app.wg.Wait()
+			close(jobsDone)
+		}()
+		select {
+		case <-jobsDone:
+		case <-ctx.Done():
+			err = errors.Join(err, errors.New("background jobs did not stop before the shutdown deadline"))
+		}
+
+		shutdownErr <- err
+	}()
+
+	app.Logger.Printf("Server is running on port %d", app.Config.Port)
+	err := server.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		app.cancel()
+		return err
+	}
+
+	err = <-shutdownErr
+	if err != nil {
+		return fmt.Errorf("graceful shutdown did not complete: %w", err)
+	}
+
+	app.Logger.Println("Server stopped")
+	return nil
+}
@@ -0,0 +1,155 @@
+// Package calories estimates the energy a workout burned from the MET
+// (metabolic equivalent) of its exercises, the time spent on them and the
+// body weight of whoever performed them.
+package calories
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// met.csv holds MET values for the exercises of the seeded catalog, taken from
+// the Compendium of Physical Activities.
+//
+//go:embed met.csv
+var metCSV string
+
+var metTable = mustParseMETTable(metCSV)
+
+const (
+	// DefaultBodyWeight is assumed, in kilograms, for users who never gave theirs.
+	DefaultBodyWeight = 70.0
+
+	// Fallback METs for exercises missing from the table: general resistance
+	// training, general calisthenics for timed work, and a general session for
+	// workouts logged without entries.
+	strengthMET = 5.0
+	timedMET    = 3.8
+	sessionMET  = 5.0
+
+	secondsPerRep      = 4
+	minSetSeconds      = 20
+	defaultRestSeconds = 90
+)
+
+func mustParseMETTable(data string) map[string]float64 {
+	records, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+	if err != nil {
+		panic(fmt.Sprintf("calories: parsing MET table: %v", err))
+	}
+	table := make(map[string]float64, len(records))
+	for _, record := range records[1:] {
+		met, err := strconv.ParseFloat(record[1], 64)
+		if err != nil {
+			panic(fmt.Sprintf("calories: MET of %q: %v", record[0], err))
+		}
+		table[normalize(record[0])] = met
+	}
+	return table
+}
+
+func normalize(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// Set is the part of a logged set the estimate needs.
+type Set struct {
+	Reps            *int
+	DurationSeconds *int
+	StartedAt       *time.Time
+	CompletedAt     *time.Time
+	RestSeconds     *int
+}
+
+// Activity is one exercise of a workout with its sets. RestTargetSeconds is
+// the rest assumed between sets whose rest was not recorded.
+type Activity struct {
+	Exercise          string
+	Sets              []Set
+	RestTargetSeconds *int
+}
+
+// MET returns the MET of the named exercise. Exercises missing from the table
+// fall back to a general value depending on whether they are timed.
+func MET(exercise string, timed bool) float64 {
+	if met, ok := metTable[normalize(exercise)]; ok {
+		return met
+	}
+	if timed {
+		return timedMET
+	}
+	return strengthMET
+}
+
+// PerMinute is the energy in kilocalories burned per minute at met by
+// someone weighing bodyWeight kilograms.
+func PerMinute(met, bodyWeight float64) float64 {
+	return met * 3.5 * bodyWeight / 200
+}
+
+// Estimate returns the kilocalories burned by someone of bodyWeight kilograms
+// during a workout of durationMinutes made up of activities. The time of each
+// activity comes from its sets: their recorded timing, else their duration,
+// else a few seconds per rep, plus the rest between them. When the workout
+// has a duration the activities share it in proportion to their time; a
+// workout with a duration but nothing logged counts as a general session.
+func Estimate(bodyWeight float64, durationMinutes int, activities []Activity) int {
+	if bodyWeight <= 0 {
+		bodyWeight = DefaultBodyWeight
+	}
+
+	var total, weighted float64
+	for _, activity := range activities {
+		seconds, timed := activitySeconds(activity)
+		total += seconds
+		weighted += seconds * MET(activity.Exercise, timed)
+	}
+
+	var kcal float64
+	switch {
+	case total > 0 && durationMinutes > 0:
+		kcal = PerMinute(weighted/total, bodyWeight) * float64(durationMinutes)
+	case total > 0:
+		kcal = PerMinute(weighted/total, bodyWeight) * total / 60
+	default:
+		kcal = PerMinute(sessionMET, bodyWeight) * float64(durationMinutes)
+	}
+	return int(math.Round(kcal))
+}
+
+// activitySeconds returns the seconds spent on activity and whether its sets
+// are timed rather than counted in reps.
+func activitySeconds(activity Activity) (float64, bool) {
+	var seconds float64
+	timed := false
+	for i, set := range activity.Sets {
+		if set.DurationSeconds != nil {
+			timed = true
+		}
+		switch {
+		case set.StartedAt != nil && set.CompletedAt != nil:
+			seconds += set.CompletedAt.Sub(*set.StartedAt).Seconds()
+		case set.DurationSeconds != nil:
+			seconds += float64(*set.DurationSeconds)
+		case set.Reps != nil:
+			seconds += math.Max(float64(*set.Reps*secondsPerRep), minSetSeconds)
+		}
+
+		// the rest after the last set belongs to whatever comes next
+		switch {
+		case set.RestSeconds != nil:
+			seconds += float64(*set.RestSeconds)
+		case i == len(activity.Sets)-1:
+		case activity.RestTargetSeconds != nil:
+			seconds += float64(*activity.RestTargetSeconds)
+		default:
+			seconds += defaultRestSeconds
+		}
+	}
+	return seconds, timed
+}
@@ -0,0 +1,45 @@
+package calories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func intPtr(i int) *int { return &i }
+
+func TestMET(t *testing.T) {
+	assert.Equal(t, 9.8, MET("Running", true))
+	assert.Equal(t, 6.0, MET("  back   SQUAT ", false))
+	assert.Equal(t, strengthMET, MET("Zercher Squat", false))
+	assert.Equal(t, timedMET, MET("Wall Sit", true))
+}
+
+func TestEstimate(t *testing.T) {
+	// 30 minutes of running at 80 kg: 9.8 * 3.5 * 80 / 200 * 30
+	running := Activity{Exercise: "Running", Sets: []Set{{DurationSeconds: intPtr(1800)}}}
+	assert.Equal(t, 412, Estimate(80, 0, []Activity{running}))
+	assert.Equal(t, 360, Estimate(0, 0, []Activity{running}), "falls back to the default body weight")
+
+	// three sets of ten with the default rest between them: 3*40s + 2*90s = 5 minutes
+	squats := Activity{Exercise: "Back Squat", Sets: []Set{{Reps: intPtr(10)}, {Reps: intPtr(10)}, {Reps: intPtr(10)}}}
+	assert.Equal(t, 42, Estimate(80, 0, []Activity{squats}))
+	squats.RestTargetSeconds = intPtr(30)
+	assert.Equal(t, 25, Estimate(80, 0, []Activity{squats}))
+
+	// recorded timing wins over reps
+	start := time.Date(2025, 3, 3, 18, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Minute)
+	timedSquat := Activity{Exercise: "Back Squat", Sets: []Set{{Reps: intPtr(5), StartedAt: &start, CompletedAt: &end, RestSeconds: intPtr(180)}}}
+	assert.Equal(t, 42, Estimate(80, 0, []Activity{timedSquat}))
+
+	// a logged duration is shared by the activities in proportion to their time
+	assert.Equal(t, 412, Estimate(80, 30, []Activity{running}))
+	mixed := Estimate(80, 60, []Activity{running, squats})
+	assert.Greater(t, mixed, 412)
+	assert.Less(t, mixed, 824)
+
+	assert.Equal(t, 420, Estimate(80, 60, nil), "a bare duration counts as a general session")
+	assert.Equal(t, 0, Estimate(80, 0, nil))
+}
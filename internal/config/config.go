@@ -0,0 +1,417 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every setting the application needs at startup.
+// Values are resolved in the following order, later sources winning:
+// built-in defaults, the optional config file, environment variables, flags.
+type Config struct {
+	// Env is production or development; development allows the file and
+	// memory mailers, which keep tokens where anyone on the host can read them.
+	Env      string         `yaml:"env" toml:"env"`
+	Port     int            `yaml:"port" toml:"port"`
+	LogLevel string         `yaml:"log_level" toml:"log_level"`
+	DB       DBConfig       `yaml:"db" toml:"db"`
+	Server   ServerConfig   `yaml:"server" toml:"server"`
+	Tokens   TokenConfig    `yaml:"tokens" toml:"tokens"`
+	Mailer   MailerConfig   `yaml:"mailer" toml:"mailer"`
+	Login    LoginConfig    `yaml:"login" toml:"login"`
+	Password PasswordConfig `yaml:"password" toml:"password"`
+}
+
+type DBConfig struct {
+	DSN             string        `yaml:"dsn" toml:"dsn"`
+	MaxOpenConns    int           `yaml:"max_open_conns" toml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" toml:"max_idle_conns"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" toml:"conn_max_idle_time"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime"`
+	// QueryTimeout caps every store call so a slow query cannot hold a pooled connection indefinitely.
+	QueryTimeout time.Duration `yaml:"query_timeout" toml:"query_timeout"`
+}
+
+type ServerConfig struct {
+	ReadTimeout  time.Duration `yaml:"read_timeout" toml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout" toml:"idle_timeout"`
+	// ShutdownTimeout bounds how long in-flight requests may drain on SIGINT/SIGTERM.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+}
+
+type TokenConfig struct {
+	AuthTTL    time.Duration `yaml:"auth_ttl" toml:"auth_ttl"`
+	RefreshTTL time.Duration `yaml:"refresh_ttl" toml:"refresh_ttl"`
+
+	PasswordResetTTL time.Duration `yaml:"password_reset_ttl" toml:"password_reset_ttl"`
+	ActivationTTL    time.Duration `yaml:"activation_ttl" toml:"activation_ttl"`
+}
+
+type MailerConfig struct {
+	// Driver selects the delivery backend: smtp, file or memory. Left empty it
+	// is file in development and smtp otherwise.
+	Driver       string `yaml:"driver" toml:"driver"`
+	Sender       string `yaml:"sender" toml:"sender"`
+	Dir          string `yaml:"dir" toml:"dir"`
+	SMTPHost     string `yaml:"smtp_host" toml:"smtp_host"`
+	SMTPPort     int    `yaml:"smtp_port" toml:"smtp_port"`
+	SMTPUsername string `yaml:"smtp_username" toml:"smtp_username"`
+	SMTPPassword string `yaml:"smtp_password" toml:"smtp_password"`
+}
+
+type LoginConfig struct {
+	// Tracker selects where failed attempts are kept: memory or postgres.
+	Tracker            string        `yaml:"tracker" toml:"tracker"`
+	MaxAttemptsPerUser int           `yaml:"max_attempts_per_user" toml:"max_attempts_per_user"`
+	MaxAttemptsPerIP   int           `yaml:"max_attempts_per_ip" toml:"max_attempts_per_ip"`
+	BaseLockout        time.Duration `yaml:"base_lockout" toml:"base_lockout"`
+	MaxLockout         time.Duration `yaml:"max_lockout" toml:"max_lockout"`
+	Window             time.Duration `yaml:"window" toml:"window"`
+	// TrustForwardedFor takes the client IP from X-Forwarded-For; enable only behind a trusted proxy.
+	TrustForwardedFor bool `yaml:"trust_forwarded_for" toml:"trust_forwarded_for"`
+}
+
+type PasswordConfig struct {
+	MinLength     int  `yaml:"min_length" toml:"min_length"`
+	RequireUpper  bool `yaml:"require_upper" toml:"require_upper"`
+	RequireLower  bool `yaml:"require_lower" toml:"require_lower"`
+	RequireDigit  bool `yaml:"require_digit" toml:"require_digit"`
+	RequireSymbol bool `yaml:"require_symbol" toml:"require_symbol"`
+	// BreachedDir points at a Pwned Passwords style hash prefix directory;
+	// when empty the small list bundled with the binary is used.
+	BreachedDir string `yaml:"breached_dir" toml:"breached_dir"`
+}
+
+const (
+	TrackerMemory   = "memory"
+	TrackerPostgres = "postgres"
+)
+
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+const (
+	MailerSMTP   = "smtp"
+	MailerFile   = "file"
+	MailerMemory = "memory"
+)
+
+const (
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warn"
+	LogLevelError = "error"
+)
+
+// Default returns the built-in configuration. It needs either an SMTP host or
+// Env set to development before it validates.
+func Default() *Config {
+	return &Config{
+		Env:      EnvProduction,
+		Port:     8080,
+		LogLevel: LogLevelInfo,
+		DB: DBConfig{
+			DSN:             "host=localhost user=postgres password=postgres dbname=postgres sslmode=disable",
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxIdleTime: 15 * time.Minute,
+			ConnMaxLifetime: time.Hour,
+			QueryTimeout:    5 * time.Second,
+		},
+		Server: ServerConfig{
+			ReadTimeout:     10 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			IdleTimeout:     time.Minute,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Tokens: TokenConfig{
+			AuthTTL:    24 * time.Hour,
+			RefreshTTL: 30 * 24 * time.Hour,
+
+			PasswordResetTTL: 45 * time.Minute,
+			ActivationTTL:    3 * 24 * time.Hour,
+		},
+		Mailer: MailerConfig{
+			Sender:   "no-reply@localhost",
+			Dir:      "tmp/mail",
+			SMTPPort: 587,
+		},
+		Login: LoginConfig{
+			Tracker:            TrackerMemory,
+			MaxAttemptsPerUser: 5,
+			MaxAttemptsPerIP:   20,
+			BaseLockout:        30 * time.Second,
+			MaxLockout:         15 * time.Minute,
+			Window:             15 * time.Minute,
+		},
+		Password: PasswordConfig{
+			MinLength:     8,
+			RequireUpper:  true,
+			RequireLower:  true,
+			RequireDigit:  true,
+			RequireSymbol: true,
+		},
+	}
+}
+
+// Load builds the configuration from defaults, the config file named by the
+// -config flag or CONFIG_FILE variable, the environment, and args.
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "Path to a YAML or TOML config file")
+	env := fs.String("env", "", "Environment (production, development)")
+	port := fs.Int("port", 0, "Port to run the server on")
+	dsn := fs.String("db-dsn", "", "PostgreSQL connection string")
+	logLevel := fs.String("log-level", "", "Log level (debug, info, warn, error)")
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		err = loadFile(cfg, *configFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = loadEnv(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// only flags that were explicitly set override the other sources
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "env":
+			cfg.Env = *env
+		case "port":
+			cfg.Port = *port
+		case "db-dsn":
+			cfg.DB.DSN = *dsn
+		case "log-level":
+			cfg.LogLevel = *logLevel
+		}
+	})
+
+	if cfg.Mailer.Driver == "" {
+		cfg.Mailer.Driver = MailerSMTP
+		if cfg.Env == EnvDevelopment {
+			cfg.Mailer.Driver = MailerFile
+		}
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("unsupported config file extension %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+	return nil
+}
+
+func loadEnv(cfg *Config) error {
+	var errs []error
+
+	setString := func(key string, dst *string) {
+		if v, ok := os.LookupEnv(key); ok {
+			*dst = v
+		}
+	}
+	setInt := func(key string, dst *int) {
+		if v, ok := os.LookupEnv(key); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid integer %q", key, v))
+				return
+			}
+			*dst = n
+		}
+	}
+	setBool := func(key string, dst *bool) {
+		if v, ok := os.LookupEnv(key); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid boolean %q", key, v))
+				return
+			}
+			*dst = b
+		}
+	}
+	setDuration := func(key string, dst *time.Duration) {
+		if v, ok := os.LookupEnv(key); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid duration %q", key, v))
+				return
+			}
+			*dst = d
+		}
+	}
+
+	setString("APP_ENV", &cfg.Env)
+	setInt("PORT", &cfg.Port)
+	setString("LOG_LEVEL", &cfg.LogLevel)
+
+	setString("DB_DSN", &cfg.DB.DSN)
+	setInt("DB_MAX_OPEN_CONNS", &cfg.DB.MaxOpenConns)
+	setInt("DB_MAX_IDLE_CONNS", &cfg.DB.MaxIdleConns)
+	setDuration("DB_CONN_MAX_IDLE_TIME", &cfg.DB.ConnMaxIdleTime)
+	setDuration("DB_CONN_MAX_LIFETIME", &cfg.DB.ConnMaxLifetime)
+	setDuration("DB_QUERY_TIMEOUT", &cfg.DB.QueryTimeout)
+
+	setDuration("SERVER_READ_TIMEOUT", &cfg.Server.ReadTimeout)
+	setDuration("SERVER_WRITE_TIMEOUT", &cfg.Server.WriteTimeout)
+	setDuration("SERVER_IDLE_TIMEOUT", &cfg.Server.IdleTimeout)
+	setDuration("SERVER_SHUTDOWN_TIMEOUT", &cfg.Server.ShutdownTimeout)
+
+	setDuration("TOKEN_AUTH_TTL", &cfg.Tokens.AuthTTL)
+	setDuration("TOKEN_REFRESH_TTL", &cfg.Tokens.RefreshTTL)
+	setDuration("TOKEN_PASSWORD_RESET_TTL", &cfg.Tokens.PasswordResetTTL)
+	setDuration("TOKEN_ACTIVATION_TTL", &cfg.Tokens.ActivationTTL)
+
+	setString("MAILER_DRIVER", &cfg.Mailer.Driver)
+	setString("MAILER_SENDER", &cfg.Mailer.Sender)
+	setString("MAILER_DIR", &cfg.Mailer.Dir)
+	setString("SMTP_HOST", &cfg.Mailer.SMTPHost)
+	setInt("SMTP_PORT", &cfg.Mailer.SMTPPort)
+	setString("SMTP_USERNAME", &cfg.Mailer.SMTPUsername)
+	setString("SMTP_PASSWORD", &cfg.Mailer.SMTPPassword)
+
+	setString("LOGIN_TRACKER", &cfg.Login.Tracker)
+	setInt("LOGIN_MAX_ATTEMPTS_PER_USER", &cfg.Login.MaxAttemptsPerUser)
+	setInt("LOGIN_MAX_ATTEMPTS_PER_IP", &cfg.Login.MaxAttemptsPerIP)
+	setDuration("LOGIN_BASE_LOCKOUT", &cfg.Login.BaseLockout)
+	setDuration("LOGIN_MAX_LOCKOUT", &cfg.Login.MaxLockout)
+	setDuration("LOGIN_WINDOW", &cfg.Login.Window)
+	setBool("LOGIN_TRUST_FORWARDED_FOR", &cfg.Login.TrustForwardedFor)
+
+	setInt("PASSWORD_MIN_LENGTH", &cfg.Password.MinLength)
+	setBool("PASSWORD_REQUIRE_UPPER", &cfg.Password.RequireUpper)
+	setBool("PASSWORD_REQUIRE_LOWER", &cfg.Password.RequireLower)
+	setBool("PASSWORD_REQUIRE_DIGIT", &cfg.Password.RequireDigit)
+	setBool("PASSWORD_REQUIRE_SYMBOL", &cfg.Password.RequireSymbol)
+	setString("PASSWORD_BREACHED_DIR", &cfg.Password.BreachedDir)
+
+	return errors.Join(errs...)
+}
+
+// Validate reports every invalid setting at once so a bad deploy fails fast.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Env != EnvProduction && c.Env != EnvDevelopment {
+		errs = append(errs, fmt.Errorf("env must be production or development, got %q", c.Env))
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port must be between 1 and 65535, got %d", c.Port))
+	}
+	switch c.LogLevel {
+	case LogLevelDebug, LogLevelInfo, LogLevelWarn, LogLevelError:
+	default:
+		errs = append(errs, fmt.Errorf("log level must be one of debug, info, warn, error, got %q", c.LogLevel))
+	}
+
+	if c.DB.DSN == "" {
+		errs = append(errs, errors.New("db dsn is required"))
+	}
+	if c.DB.MaxOpenConns < 0 {
+		errs = append(errs, errors.New("db max open conns must not be negative"))
+	}
+	if c.DB.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("db max idle conns must not be negative"))
+	}
+	if c.DB.MaxOpenConns > 0 && c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+		errs = append(errs, errors.New("db max idle conns must not exceed max open conns"))
+	}
+	if c.DB.ConnMaxIdleTime < 0 || c.DB.ConnMaxLifetime < 0 {
+		errs = append(errs, errors.New("db connection lifetimes must not be negative"))
+	}
+	if c.DB.QueryTimeout < 0 {
+		errs = append(errs, errors.New("db query timeout must not be negative"))
+	}
+
+	if c.Server.ReadTimeout <= 0 || c.Server.WriteTimeout <= 0 || c.Server.IdleTimeout <= 0 || c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server timeouts must be positive"))
+	}
+
+	if c.Tokens.AuthTTL < time.Minute {
+		errs = append(errs, errors.New("token auth ttl must be at least one minute"))
+	}
+	if c.Tokens.RefreshTTL <= c.Tokens.AuthTTL {
+		errs = append(errs, errors.New("token refresh ttl must be longer than the auth ttl"))
+	}
+	if c.Tokens.PasswordResetTTL < time.Minute || c.Tokens.ActivationTTL < time.Minute {
+		errs = append(errs, errors.New("token password reset and activation ttls must be at least one minute"))
+	}
+
+	switch c.Mailer.Driver {
+	case MailerSMTP:
+		if c.Mailer.SMTPHost == "" || c.Mailer.SMTPPort < 1 {
+			errs = append(errs, errors.New("smtp mailer requires a host and port"))
+		}
+	case MailerFile, MailerMemory:
+		if c.Env != EnvDevelopment {
+			errs = append(errs, fmt.Errorf("%s mailer is only allowed in development", c.Mailer.Driver))
+		}
+		if c.Mailer.Driver == MailerFile && c.Mailer.Dir == "" {
+			errs = append(errs, errors.New("file mailer requires a directory"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("mailer driver must be one of smtp, file, memory, got %q", c.Mailer.Driver))
+	}
+	if c.Mailer.Sender == "" {
+		errs = append(errs, errors.New("mailer sender is required"))
+	}
+
+	if c.Login.Tracker != TrackerMemory && c.Login.Tracker != TrackerPostgres {
+		errs = append(errs, fmt.Errorf("login tracker must be memory or postgres, got %q", c.Login.Tracker))
+	}
+	if c.Login.MaxAttemptsPerUser < 1 || c.Login.MaxAttemptsPerIP < 1 {
+		errs = append(errs, errors.New("login max attempts must be at least 1"))
+	}
+	if c.Login.BaseLockout <= 0 || c.Login.MaxLockout < c.Login.BaseLockout || c.Login.Window <= 0 {
+		errs = append(errs, errors.New("login lockouts and window must be positive, with max lockout at least the base lockout"))
+	}
+
+	if c.Password.MinLength < 1 || c.Password.MinLength > 72 {
+		errs = append(errs, errors.New("password min length must be between 1 and 72"))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
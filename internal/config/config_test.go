@@ -0,0 +1,129 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	yamlFile := filepath.Join(dir, "config.yaml")
+	err := os.WriteFile(yamlFile, []byte("port: 9000\nlog_level: debug\ndb:\n  dsn: from-file\n  max_open_conns: 10\n  max_idle_conns: 5\ntokens:\n  auth_ttl: 2h\n"), 0o600)
+	require.NoError(t, err)
+
+	tomlFile := filepath.Join(dir, "config.toml")
+	err = os.WriteFile(tomlFile, []byte("port = 9100\n[server]\nread_timeout = \"5s\"\n"), 0o600)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		env   map[string]string
+		args  []string
+		check func(t *testing.T, cfg *Config)
+	}{
+		{
+			name: "defaults",
+			check: func(t *testing.T, cfg *Config) {
+				want := Default()
+				want.Env = EnvDevelopment
+				want.Mailer.Driver = MailerFile
+				assert.Equal(t, want, cfg)
+			},
+		},
+		{
+			name: "smtp outside development",
+			env:  map[string]string{"APP_ENV": EnvProduction, "SMTP_HOST": "mail.example.com"},
+			check: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, MailerSMTP, cfg.Mailer.Driver)
+			},
+		},
+		{
+			name: "yaml file overrides defaults",
+			args: []string{"-config", yamlFile},
+			check: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, 9000, cfg.Port)
+				assert.Equal(t, LogLevelDebug, cfg.LogLevel)
+				assert.Equal(t, "from-file", cfg.DB.DSN)
+				assert.Equal(t, 10, cfg.DB.MaxOpenConns)
+				assert.Equal(t, 2*time.Hour, cfg.Tokens.AuthTTL)
+				assert.Equal(t, 30*time.Second, cfg.Server.WriteTimeout)
+			},
+		},
+		{
+			name: "toml file",
+			args: []string{"-config", tomlFile},
+			check: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, 9100, cfg.Port)
+				assert.Equal(t, 5*time.Second, cfg.Server.ReadTimeout)
+			},
+		},
+		{
+			name: "env overrides file",
+			env:  map[string]string{"CONFIG_FILE": yamlFile, "PORT": "9001", "DB_DSN": "from-env"},
+			check: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, 9001, cfg.Port)
+				assert.Equal(t, "from-env", cfg.DB.DSN)
+				assert.Equal(t, LogLevelDebug, cfg.LogLevel)
+			},
+		},
+		{
+			name: "flags override env",
+			env:  map[string]string{"PORT": "9001", "LOG_LEVEL": "error"},
+			args: []string{"-port", "9002"},
+			check: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, 9002, cfg.Port)
+				assert.Equal(t, LogLevelError, cfg.LogLevel)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the file mailer the other cases fall back to needs development
+			t.Setenv("APP_ENV", EnvDevelopment)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg, err := Load(tt.args)
+			require.NoError(t, err)
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestLoadRejectsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{name: "bad port", args: []string{"-port", "70000"}, want: "port must be between 1 and 65535"},
+		{name: "bad log level", env: map[string]string{"LOG_LEVEL": "verbose"}, want: "log level must be one of"},
+		{name: "unparsable duration", env: map[string]string{"SERVER_READ_TIMEOUT": "soon"}, want: "SERVER_READ_TIMEOUT: invalid duration"},
+		{name: "idle above open", env: map[string]string{"DB_MAX_OPEN_CONNS": "2", "DB_MAX_IDLE_CONNS": "5"}, want: "db max idle conns must not exceed max open conns"},
+		{name: "missing file", args: []string{"-config", "does-not-exist.yaml"}, want: "failed to read config file"},
+		{name: "unknown env", args: []string{"-env", "staging"}, want: "env must be production or development"},
+		{name: "smtp default without host", env: map[string]string{"APP_ENV": EnvProduction, "SMTP_HOST": ""}, want: "smtp mailer requires a host and port"},
+		{name: "file mailer outside development", env: map[string]string{"APP_ENV": EnvProduction, "MAILER_DRIVER": "file"}, want: "file mailer is only allowed in development"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// an SMTP host keeps the mailer valid in every environment, so each
+			// case fails only on what it is about
+			t.Setenv("APP_ENV", EnvDevelopment)
+			t.Setenv("SMTP_HOST", "mail.example.com")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			_, err := Load(tt.args)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package mailer
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryMailer(t *testing.T) {
+	m := NewMemoryMailer()
+	require.NoError(t, m.Send(context.Background(), "a@example.com", "hello", "body"))
+
+	messages := m.Messages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, "a@example.com", messages[0].To)
+	assert.Equal(t, "hello", messages[0].Subject)
+	assert.Equal(t, "body", messages[0].Body)
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m, err := NewFileMailer(dir)
+	require.NoError(t, err)
+	require.NoError(t, m.Send(context.Background(), "a/b@example.com", "reset", "token: abc"))
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	content, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "Subject: reset")
+	assert.Contains(t, string(content), "token: abc")
+}
+
+func TestSMTPMailerGivesUpOnStalledRelay(t *testing.T) {
+	// accepts connections but never sends a greeting
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	m := NewSMTPMailer("127.0.0.1", addr.Port, "", "", "no-reply@example.com")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	err = m.Send(ctx, "a@example.com", "hello", "body")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
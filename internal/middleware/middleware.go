@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"context"
+	"log"
 	"net/http"
 	"strings"
 
@@ -11,12 +12,17 @@ import (
 )
 
 type UserMiddleware struct {
-	UserStore store.UserStore
+	UserStore  store.UserStore
+	TokenStore store.TokenStore
+	Logger     *log.Logger
 }
 
 type contextKey string
 
-const userContextKey = contextKey("user")
+const (
+	userContextKey  = contextKey("user")
+	tokenContextKey = contextKey("token")
+)
 
 func SetUser(r *http.Request, user *store.User) *http.Request {
 	ctx := context.WithValue(r.Context(), userContextKey, user)
@@ -31,6 +37,17 @@ func GetUser(r *http.Request) *store.User {
 	return user
 }
 
+func setToken(r *http.Request, token string) *http.Request {
+	ctx := context.WithValue(r.Context(), tokenContextKey, token)
+	return r.WithContext(ctx)
+}
+
+// GetToken returns the bearer token the request was authenticated with, if any.
+func GetToken(r *http.Request) string {
+	token, _ := r.Context().Value(tokenContextKey).(string)
+	return token
+}
+
 func (um *UserMiddleware) Authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Vary", "Authorization")
@@ -50,7 +67,7 @@ func (um *UserMiddleware) Authenticate(next http.Handler) http.Handler {
 		}
 
 		token := headerParts[1]
-		user, err := um.UserStore.GetUserTokens(tokens.ScopeAuth, token)
+		user, err := um.UserStore.GetUserTokens(r.Context(), tokens.ScopeAuth, token)
 		if err != nil {
 			utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{
 				"error": "invalid token",
@@ -64,7 +81,13 @@ func (um *UserMiddleware) Authenticate(next http.Handler) http.Handler {
 			return
 		}
 
+		err = um.TokenStore.TouchToken(r.Context(), token)
+		if err != nil {
+			um.Logger.Printf("WARNING: recording token usage: %v", err)
+		}
+
 		r = SetUser(r, user)
+		r = setToken(r, token)
 		next.ServeHTTP(w, r)
 	})
 }
@@ -81,3 +104,17 @@ func (um *UserMiddleware) RequireUser(next http.HandlerFunc) http.HandlerFunc {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// RequireActivatedUser is RequireUser for routes that also need a verified email address.
+func (um *UserMiddleware) RequireActivatedUser(next http.HandlerFunc) http.HandlerFunc {
+	return um.RequireUser(func(w http.ResponseWriter, r *http.Request) {
+		user := GetUser(r)
+		if !user.Activated {
+			utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{
+				"error": "your account must be activated to access this resource",
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,162 @@
+package passwords
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// bcrypt ignores everything past 72 bytes, so longer passwords would be silently truncated.
+const bcryptMaxBytes = 72
+
+// BreachedList reports whether a password is known from public breaches.
+type BreachedList interface {
+	Contains(plainText string) (bool, error)
+}
+
+type Policy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// Breached is consulted last; nil disables the check.
+	Breached BreachedList
+}
+
+// Validate returns every rule the password breaks, in a form fit to show the user.
+// The error is only set when the breached list could not be consulted.
+func (p *Policy) Validate(plainText string) ([]string, error) {
+	var problems []string
+
+	if len([]rune(plainText)) < p.MinLength {
+		problems = append(problems, fmt.Sprintf("must be at least %d characters long", p.MinLength))
+	}
+	if len(plainText) > bcryptMaxBytes {
+		problems = append(problems, fmt.Sprintf("must not be longer than %d bytes", bcryptMaxBytes))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range plainText {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSymbol = true
+		}
+	}
+	if p.RequireUpper && !hasUpper {
+		problems = append(problems, "must contain an uppercase letter")
+	}
+	if p.RequireLower && !hasLower {
+		problems = append(problems, "must contain a lowercase letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		problems = append(problems, "must contain a digit")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		problems = append(problems, "must contain a symbol")
+	}
+
+	if p.Breached != nil {
+		breached, err := p.Breached.Contains(plainText)
+		if err != nil {
+			return problems, err
+		}
+		if breached {
+			problems = append(problems, "has appeared in a data breach, please choose another one")
+		}
+	}
+
+	return problems, nil
+}
+
+func sha1Hex(plainText string) string {
+	sum := sha1.Sum([]byte(plainText))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+//go:embed breached.txt
+var bundledBreached []byte
+
+// HashList is an in-memory set of SHA-1 digests.
+type HashList struct {
+	hashes map[string]struct{}
+}
+
+// NewBundledList returns the small list of common passwords shipped with the binary.
+func NewBundledList() *HashList {
+	list := &HashList{hashes: make(map[string]struct{})}
+	scanner := bufio.NewScanner(bytes.NewReader(bundledBreached))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		list.hashes[strings.ToUpper(line)] = struct{}{}
+	}
+	return list
+}
+
+func (l *HashList) Contains(plainText string) (bool, error) {
+	_, ok := l.hashes[sha1Hex(plainText)]
+	return ok, nil
+}
+
+// PrefixDirList looks passwords up in a directory laid out like the Pwned
+// Passwords range API: one file per 5-character SHA-1 prefix, each line holding
+// the remaining 35 characters and a count ("SUFFIX:COUNT"). Only the one file
+// for the prefix is ever read, so the full corpus never has to fit in memory.
+type PrefixDirList struct {
+	dir string
+}
+
+func NewPrefixDirList(dir string) (*PrefixDirList, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("breached password list %s is not a directory", dir)
+	}
+	return &PrefixDirList{dir: dir}, nil
+}
+
+func (l *PrefixDirList) Contains(plainText string) (bool, error) {
+	hash := sha1Hex(plainText)
+	prefix, suffix := hash[:5], hash[5:]
+
+	f, err := os.Open(filepath.Join(l.dir, prefix))
+	if errors.Is(err, fs.ErrNotExist) {
+		f, err = os.Open(filepath.Join(l.dir, prefix+".txt"))
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		candidate, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(candidate, suffix) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
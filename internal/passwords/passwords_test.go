@@ -0,0 +1,64 @@
+package passwords
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyValidate(t *testing.T) {
+	policy := &Policy{
+		MinLength:     8,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+		Breached:      NewBundledList(),
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{name: "strong", password: "Tr1cky-Squat", want: nil},
+		{name: "too short", password: "Ab1!", want: []string{"must be at least 8 characters long"}},
+		{name: "missing classes", password: "alllowercase", want: []string{
+			"must contain an uppercase letter",
+			"must contain a digit",
+			"must contain a symbol",
+		}},
+		{name: "breached", password: "P@ssw0rd", want: []string{"has appeared in a data breach, please choose another one"}},
+		{name: "over bcrypt limit", password: "Aa1!" + strings.Repeat("x", 80), want: []string{"must not be longer than 72 bytes"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems, err := policy.Validate(tt.password)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, problems)
+		})
+	}
+}
+
+func TestPrefixDirList(t *testing.T) {
+	dir := t.TempDir()
+	hash := sha1Hex("hunter2")
+	err := os.WriteFile(filepath.Join(dir, hash[:5]), []byte("0000000000000000000000000000000000A:1\n"+hash[5:]+":42\n"), 0o600)
+	require.NoError(t, err)
+
+	list, err := NewPrefixDirList(dir)
+	require.NoError(t, err)
+
+	found, err := list.Contains("hunter2")
+	require.NoError(t, err)
+	assert.True(t, found)
+
+	found, err = list.Contains("correct horse battery staple")
+	require.NoError(t, err)
+	assert.False(t, found)
+}
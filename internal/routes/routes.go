@@ -19,13 +19,66 @@ func SetupRoutes(app *app.App) *chi.Mux {
 		r.Get("/workouts", app.Middleware.RequireUser(app.WorkoutHandler.HandlerGetAllWorkouts))
 		r.Get("/workouts/{id}", app.Middleware.RequireUser(app.WorkoutHandler.HandlerGetWorkoutByID))
 
-		r.Post("/workouts", app.Middleware.RequireUser(app.WorkoutHandler.HandlerCreateWorkout))
-		r.Put("/workouts/{id}", app.Middleware.RequireUser(app.WorkoutHandler.HandlerUpdateWorkout))
-		r.Delete("/workouts/{id}", app.Middleware.RequireUser(app.WorkoutHandler.HandlerDeleteWorkout))
+		// Writes additionally require an activated account
+		r.Post("/workouts", app.Middleware.RequireActivatedUser(app.WorkoutHandler.HandlerCreateWorkout))
+		r.Put("/workouts/{id}", app.Middleware.RequireActivatedUser(app.WorkoutHandler.HandlerUpdateWorkout))
+		r.Delete("/workouts/{id}", app.Middleware.RequireActivatedUser(app.WorkoutHandler.HandlerDeleteWorkout))
+		r.Post("/workouts/{id}/entries", app.Middleware.RequireActivatedUser(app.WorkoutHandler.HandlerAddWorkoutEntry))
+		r.Patch("/workouts/{id}/entries/{entryID}", app.Middleware.RequireActivatedUser(app.WorkoutHandler.HandlerUpdateWorkoutEntry))
+		r.Delete("/workouts/{id}/entries/{entryID}", app.Middleware.RequireActivatedUser(app.WorkoutHandler.HandlerDeleteWorkoutEntry))
+		r.Post("/workouts/{id}/finish", app.Middleware.RequireActivatedUser(app.WorkoutHandler.HandlerFinishWorkout))
+
+		r.Get("/exercises", app.Middleware.RequireUser(app.ExerciseHandler.HandlerListExercises))
+		r.Get("/exercises/{id}", app.Middleware.RequireUser(app.ExerciseHandler.HandlerGetExerciseByID))
+		r.Post("/exercises", app.Middleware.RequireActivatedUser(app.ExerciseHandler.HandlerCreateExercise))
+		r.Put("/exercises/{id}", app.Middleware.RequireActivatedUser(app.ExerciseHandler.HandlerUpdateExercise))
+		r.Delete("/exercises/{id}", app.Middleware.RequireActivatedUser(app.ExerciseHandler.HandlerDeleteExercise))
+
+		r.Get("/templates", app.Middleware.RequireUser(app.TemplateHandler.HandlerListTemplates))
+		r.Get("/templates/{id}", app.Middleware.RequireUser(app.TemplateHandler.HandlerGetTemplateByID))
+		r.Post("/templates", app.Middleware.RequireActivatedUser(app.TemplateHandler.HandlerCreateTemplate))
+		r.Put("/templates/{id}", app.Middleware.RequireActivatedUser(app.TemplateHandler.HandlerUpdateTemplate))
+		r.Delete("/templates/{id}", app.Middleware.RequireActivatedUser(app.TemplateHandler.HandlerDeleteTemplate))
+		r.Post("/templates/{id}/instantiate", app.Middleware.RequireActivatedUser(app.TemplateHandler.HandlerInstantiateTemplate))
+
+		r.Get("/programs", app.Middleware.RequireUser(app.ProgramHandler.HandlerListPrograms))
+		r.Get("/programs/{id}", app.Middleware.RequireUser(app.ProgramHandler.HandlerGetProgramByID))
+		r.Post("/programs", app.Middleware.RequireActivatedUser(app.ProgramHandler.HandlerCreateProgram))
+		r.Put("/programs/{id}", app.Middleware.RequireActivatedUser(app.ProgramHandler.HandlerUpdateProgram))
+		r.Delete("/programs/{id}", app.Middleware.RequireActivatedUser(app.ProgramHandler.HandlerDeleteProgram))
+		r.Post("/programs/{id}/enrollments", app.Middleware.RequireActivatedUser(app.ProgramHandler.HandlerEnroll))
+		r.Get("/enrollments", app.Middleware.RequireUser(app.ProgramHandler.HandlerListEnrollments))
+		r.Patch("/enrollments/{id}", app.Middleware.RequireActivatedUser(app.ProgramHandler.HandlerUpdateEnrollment))
+		r.Get("/schedule", app.Middleware.RequireUser(app.ProgramHandler.HandlerGetSchedule))
+
+		r.Get("/goals", app.Middleware.RequireUser(app.GoalHandler.HandlerListGoals))
+		r.Get("/goals/{id}", app.Middleware.RequireUser(app.GoalHandler.HandlerGetGoalByID))
+		r.Post("/goals", app.Middleware.RequireActivatedUser(app.GoalHandler.HandlerCreateGoal))
+		r.Patch("/goals/{id}", app.Middleware.RequireActivatedUser(app.GoalHandler.HandlerUpdateGoal))
+		r.Delete("/goals/{id}", app.Middleware.RequireActivatedUser(app.GoalHandler.HandlerDeleteGoal))
+
+		r.Get("/stats", app.Middleware.RequireUser(app.StatsHandler.HandlerGetTrainingStats))
+		r.Get("/stats/muscle-groups", app.Middleware.RequireUser(app.StatsHandler.HandlerGetMuscleGroupStats))
+		r.Get("/stats/exercises/{id}", app.Middleware.RequireUser(app.StatsHandler.HandlerGetExerciseProgression))
 
 		r.Get("/users/self", app.Middleware.RequireUser(app.UserHandler.HandleGetLoggedInUser))
-		// r.Put("/users/{id}", app.Middleware.RequireUser(app.UserHandler.HandlerUpdateUser))
-		// r.Delete("/users/{id}", app.Middleware.RequireUser(app.UserHandler.HandlerDeleteUser))
+		r.Patch("/users/self", app.Middleware.RequireUser(app.UserHandler.HandlerUpdateUser))
+		r.Put("/users/self/password", app.Middleware.RequireUser(app.UserHandler.HandlerChangePassword))
+		r.Delete("/users/self", app.Middleware.RequireUser(app.UserHandler.HandlerDeleteUser))
+		r.Get("/users/self/records", app.Middleware.RequireUser(app.RecordHandler.HandlerListRecords))
+		r.Get("/users/self/records/{id}", app.Middleware.RequireUser(app.RecordHandler.HandlerGetRecordHistory))
+		r.Get("/users/self/measurements", app.Middleware.RequireUser(app.MeasurementHandler.HandlerListMeasurements))
+		r.Get("/users/self/measurements/latest", app.Middleware.RequireUser(app.MeasurementHandler.HandlerGetLatestMeasurements))
+		r.Get("/users/self/measurements/series", app.Middleware.RequireUser(app.MeasurementHandler.HandlerGetMeasurementSeries))
+		r.Get("/users/self/measurements/{id}", app.Middleware.RequireUser(app.MeasurementHandler.HandlerGetMeasurementByID))
+		r.Post("/users/self/measurements", app.Middleware.RequireActivatedUser(app.MeasurementHandler.HandlerCreateMeasurement))
+		r.Patch("/users/self/measurements/{id}", app.Middleware.RequireActivatedUser(app.MeasurementHandler.HandlerUpdateMeasurement))
+		r.Delete("/users/self/measurements/{id}", app.Middleware.RequireActivatedUser(app.MeasurementHandler.HandlerDeleteMeasurement))
+		r.Get("/users/self/streaks", app.Middleware.RequireUser(app.StreakHandler.HandlerGetStreaks))
+
+		r.Get("/tokens", app.Middleware.RequireUser(app.TokenHandler.HandleListSessions))
+		r.Delete("/tokens", app.Middleware.RequireUser(app.TokenHandler.HandleRevokeAllTokens))
+		r.Delete("/tokens/authentication", app.Middleware.RequireUser(app.TokenHandler.HandleRevokeToken))
 
 	})
 
@@ -34,6 +87,10 @@ func SetupRoutes(app *app.App) *chi.Mux {
 
 	r.Post("/users", app.UserHandler.HandlerRegisterUser)
 	r.Post("/tokens/authentication", app.TokenHandler.HandleCreateToken)
+	r.Post("/tokens/refresh", app.TokenHandler.HandleRefreshToken)
+	r.Post("/tokens/password-reset", app.TokenHandler.HandleCreatePasswordResetToken)
+	r.Put("/users/password", app.UserHandler.HandlerResetPassword)
+	r.Put("/users/activated", app.UserHandler.HandlerActivateUser)
 
 	return r
 }
@@ -0,0 +1,337 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgtype"
+)
+
+const (
+	MovementReps  = "reps"
+	MovementTimed = "timed"
+)
+
+var (
+	ErrDuplicateExercise = errors.New("an exercise with this name already exists")
+	ErrExerciseInUse     = errors.New("exercise is used by workouts or templates")
+	ErrUnknownExercise   = errors.New("unknown exercise")
+)
+
+// Exercise is an entry of the catalog. Built-in exercises have no owner;
+// custom ones belong to the user who created them and are only visible to them.
+type Exercise struct {
+	ID           int       `json:"id"`
+	UserID       *int      `json:"user_id"`
+	Name         string    `json:"name"`
+	MuscleGroups []string  `json:"muscle_groups"`
+	Equipment    string    `json:"equipment"`
+	MovementType string    `json:"movement_type"`
+	Aliases      []string  `json:"aliases"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (e *Exercise) IsCustom() bool {
+	return e.UserID != nil
+}
+
+func IsValidMovementType(movementType string) bool {
+	return movementType == MovementReps || movementType == MovementTimed
+}
+
+type PostgresExerciseStore struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func NewPostgresExerciseStore(db *sql.DB, queryTimeout time.Duration) *PostgresExerciseStore {
+	return &PostgresExerciseStore{db: db, queryTimeout: queryTimeout}
+}
+
+type ExerciseStore interface {
+	ListExercises(ctx context.Context, userID int, search string) ([]*Exercise, error)
+	GetExerciseByID(ctx context.Context, id int) (*Exercise, error)
+	CreateExercise(ctx context.Context, exercise *Exercise) error
+	UpdateExercise(ctx context.Context, exercise *Exercise) error
+	DeleteExercise(ctx context.Context, id int) error
+}
+
+// normalizeExerciseName is the Go twin of the normalize_exercise_name SQL
+// function: lower-cased with runs of whitespace collapsed to one space.
+func normalizeExerciseName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+func normalizeAliases(aliases []string) []string {
+	normalized := make([]string, 0, len(aliases))
+	for _, alias := range aliases {
+		if alias = normalizeExerciseName(alias); alias != "" {
+			normalized = append(normalized, alias)
+		}
+	}
+	return normalized
+}
+
+// stringArray scans and writes TEXT[] columns, mapping NULL to an empty slice.
+type stringArray []string
+
+func (a *stringArray) Scan(src any) error {
+	var arr pgtype.TextArray
+	if err := arr.Scan(src); err != nil {
+		return err
+	}
+	var values []string
+	if err := arr.AssignTo(&values); err != nil {
+		return err
+	}
+	if values == nil {
+		values = []string{}
+	}
+	*a = values
+	return nil
+}
+
+func (a stringArray) Value() (driver.Value, error) {
+	var arr pgtype.TextArray
+	values := []string(a)
+	if values == nil {
+		values = []string{}
+	}
+	if err := arr.Set(values); err != nil {
+		return nil, err
+	}
+	return arr.Value()
+}
+
+const exerciseColumns = `id, user_id, name, muscle_groups, equipment, movement_type, aliases, created_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanExercise(row rowScanner) (*Exercise, error) {
+	var exercise Exercise
+	var userID sql.NullInt64
+	err := row.Scan(
+		&exercise.ID,
+		&userID,
+		&exercise.Name,
+		(*stringArray)(&exercise.MuscleGroups),
+		&exercise.Equipment,
+		&exercise.MovementType,
+		(*stringArray)(&exercise.Aliases),
+		&exercise.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if userID.Valid {
+		id := int(userID.Int64)
+		exercise.UserID = &id
+	}
+	return &exercise, nil
+}
+
+// ListExercises returns the built-in catalog plus the user's custom exercises,
+// optionally narrowed to names or aliases containing search.
+func (store *PostgresExerciseStore) ListExercises(ctx context.Context, userID int, search string) ([]*Exercise, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	query := `SELECT ` + exerciseColumns + `
+		FROM exercises
+		WHERE (user_id IS NULL OR user_id = $1)
+			AND ($2 = '' OR normalized_name LIKE $3 OR EXISTS (SELECT 1 FROM unnest(aliases) a WHERE a LIKE $3))
+		ORDER BY name, id`
+	search = normalizeExerciseName(search)
+	rows, err := store.db.QueryContext(ctx, query, userID, search, "%"+escapeLike(search)+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exercises := []*Exercise{}
+	for rows.Next() {
+		exercise, err := scanExercise(rows)
+		if err != nil {
+			return nil, err
+		}
+		exercises = append(exercises, exercise)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return exercises, nil
+}
+
+func (store *PostgresExerciseStore) GetExerciseByID(ctx context.Context, id int) (*Exercise, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	query := `SELECT ` + exerciseColumns + ` FROM exercises WHERE id = $1`
+	return scanExercise(store.db.QueryRowContext(ctx, query, id))
+}
+
+// CreateExercise adds a custom exercise for exercise.UserID. Its name may not
+// clash with a built-in exercise or alias, nor with the user's other exercises.
+func (store *PostgresExerciseStore) CreateExercise(ctx context.Context, exercise *Exercise) error {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	normalized := normalizeExerciseName(exercise.Name)
+	err = checkBuiltinName(ctx, tx, normalized)
+	if err != nil {
+		return err
+	}
+
+	exercise.Aliases = normalizeAliases(exercise.Aliases)
+	query := `INSERT INTO exercises (user_id, name, normalized_name, muscle_groups, equipment, movement_type, aliases)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at`
+	err = tx.QueryRowContext(ctx, query, exercise.UserID, exercise.Name, normalized, stringArray(exercise.MuscleGroups), exercise.Equipment, exercise.MovementType, stringArray(exercise.Aliases)).
+		Scan(&exercise.ID, &exercise.CreatedAt)
+	if isUniqueViolation(err, "exercises_owner_name_key") {
+		return ErrDuplicateExercise
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateExercise saves changes to a custom exercise. Entries already logged
+// against it keep pointing at it and pick up the new name.
+func (store *PostgresExerciseStore) UpdateExercise(ctx context.Context, exercise *Exercise) error {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	normalized := normalizeExerciseName(exercise.Name)
+	err = checkBuiltinName(ctx, tx, normalized)
+	if err != nil {
+		return err
+	}
+
+	exercise.Aliases = normalizeAliases(exercise.Aliases)
+	query := `UPDATE exercises
+		SET name = $1, normalized_name = $2, muscle_groups = $3, equipment = $4, movement_type = $5, aliases = $6
+		WHERE id = $7 AND user_id IS NOT NULL`
+	result, err := tx.ExecContext(ctx, query, exercise.Name, normalized, stringArray(exercise.MuscleGroups), exercise.Equipment, exercise.MovementType, stringArray(exercise.Aliases), exercise.ID)
+	if isUniqueViolation(err, "exercises_owner_name_key") {
+		return ErrDuplicateExercise
+	}
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	// entries keep a copy of the name for display
+	_, err = tx.ExecContext(ctx, `UPDATE workout_entries SET exercise_name = $1 WHERE exercise_id = $2`, exercise.Name, exercise.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteExercise removes a custom exercise. It fails with ErrExerciseInUse
+// while any workout entry still refers to it.
+func (store *PostgresExerciseStore) DeleteExercise(ctx context.Context, id int) error {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	result, err := store.db.ExecContext(ctx, `DELETE FROM exercises WHERE id = $1 AND user_id IS NOT NULL`, id)
+	if isForeignKeyViolation(err) {
+		return ErrExerciseInUse
+	}
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func checkBuiltinName(ctx context.Context, tx *sql.Tx, normalized string) error {
+	var exists bool
+	query := `SELECT EXISTS (
+		SELECT 1 FROM exercises
+		WHERE user_id IS NULL AND (normalized_name = $1 OR $1 = ANY(aliases))
+	)`
+	err := tx.QueryRowContext(ctx, query, normalized).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrDuplicateExercise
+	}
+	return nil
+}
+
+// resolveExercise points an entry at a catalog exercise visible to userID and
+// copies the canonical name onto it. An explicit exerciseID wins; otherwise the
+// free-text name is matched against names and aliases, preferring the built-in
+// catalog, and a custom exercise is created for names nothing matches. The
+// entry's sets decide whether such a new exercise is timed.
+func resolveExercise(ctx context.Context, tx *sql.Tx, userID int, exerciseID *int, exerciseName *string, sets []WorkoutSet) error {
+	if *exerciseID != 0 {
+		query := `SELECT name FROM exercises WHERE id = $1 AND (user_id IS NULL OR user_id = $2)`
+		err := tx.QueryRowContext(ctx, query, *exerciseID, userID).Scan(exerciseName)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUnknownExercise
+		}
+		return err
+	}
+
+	normalized := normalizeExerciseName(*exerciseName)
+	if normalized == "" {
+		return ErrUnknownExercise
+	}
+
+	query := `SELECT id, name FROM exercises
+		WHERE (user_id IS NULL OR user_id = $2) AND (normalized_name = $1 OR $1 = ANY(aliases))
+		ORDER BY user_id NULLS FIRST, normalized_name = $1 DESC
+		LIMIT 1`
+	err := tx.QueryRowContext(ctx, query, normalized, userID).Scan(exerciseID, exerciseName)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	movementType := MovementReps
+	if len(sets) > 0 && sets[0].DurationSeconds != nil {
+		movementType = MovementTimed
+	}
+	insert := `INSERT INTO exercises (user_id, name, normalized_name, movement_type)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT ((COALESCE(user_id, 0)), normalized_name) DO UPDATE SET name = exercises.name
+		RETURNING id, name`
+	return tx.QueryRowContext(ctx, insert, userID, strings.Join(strings.Fields(*exerciseName), " "), normalized, movementType).
+		Scan(exerciseID, exerciseName)
+}
@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeExerciseName(t *testing.T) {
+	assert.Equal(t, "bench press", normalizeExerciseName("  Bench \t PRESS "))
+	assert.Equal(t, "", normalizeExerciseName("   "))
+}
+
+func TestWorkoutEntriesResolveExercises(t *testing.T) {
+	db := SetupTestDB(t)
+	defer db.Close()
+
+	workoutStore := NewPostgresWorkoutStore(db, 5*time.Second)
+	exerciseStore := NewPostgresExerciseStore(db, 5*time.Second)
+	user := createTestUser(t, db)
+
+	workout, err := workoutStore.CreateWorkout(context.Background(), &Workout{
+		UserID:          user.ID,
+		Title:           "push day",
+		DurationMinutes: 45,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "bench  Press", Sets: []WorkoutSet{{Reps: IntPtr(8)}}, OrderIndex: 1},
+			{ExerciseName: "OHP", Sets: []WorkoutSet{{Reps: IntPtr(5)}}, OrderIndex: 2},
+			{ExerciseName: "Landmine Press", Sets: []WorkoutSet{{Reps: IntPtr(10)}}, OrderIndex: 3},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Bench Press", workout.Entries[0].ExerciseName)
+	assert.Equal(t, "Overhead Press", workout.Entries[1].ExerciseName)
+
+	custom, err := exerciseStore.GetExerciseByID(context.Background(), workout.Entries[2].ExerciseID)
+	require.NoError(t, err)
+	require.True(t, custom.IsCustom())
+	assert.Equal(t, user.ID, *custom.UserID)
+
+	err = exerciseStore.CreateExercise(context.Background(), &Exercise{UserID: &user.ID, Name: "Flat Bench", MovementType: MovementReps})
+	assert.ErrorIs(t, err, ErrDuplicateExercise)
+
+	err = exerciseStore.DeleteExercise(context.Background(), custom.ID)
+	assert.ErrorIs(t, err, ErrExerciseInUse)
+
+	page, err := workoutStore.ListWorkouts(context.Background(), user.ID, WorkoutFilter{Exercise: "military press"})
+	require.NoError(t, err)
+	assert.Len(t, page.Workouts, 1)
+}
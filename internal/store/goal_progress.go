@@ -0,0 +1,95 @@
+package store
+
+import (
+	"math"
+	"time"
+)
+
+// maxProjectionDays bounds how far ahead a projected completion date may lie;
+// trends that need longer are reported as having no projection.
+const maxProjectionDays = 5 * 365
+
+// GoalProgress is how far a goal is from its target. Target is what
+// CurrentValue is compared against: the total number of workouts for a
+// frequency goal, TargetValue for every other type. ProjectedDate
+// (YYYY-MM-DD) extrapolates the recent trend and is nil when the trend does
+// not lead to the target.
+type GoalProgress struct {
+	CurrentValue  *float64 `json:"current_value"`
+	Target        float64  `json:"target"`
+	Percent       float64  `json:"percent"`
+	ProjectedDate *string  `json:"projected_date"`
+}
+
+// trend is a least squares line through dated values, with x in days since
+// the Unix epoch. It is valid once at least two points were seen.
+type trend struct {
+	slope, intercept *float64
+}
+
+// epochDays is t as fractional days since the Unix epoch, the x axis of trends.
+func epochDays(t time.Time) float64 {
+	return float64(t.Unix()) / 86400
+}
+
+// dateFromEpochDays is the first day starting at or after days; the slack
+// keeps floating point noise from pushing an exact day to the next one.
+func dateFromEpochDays(days float64) time.Time {
+	return time.Unix(int64(math.Ceil(days-1e-6))*86400, 0).UTC()
+}
+
+// reached reports whether current has got to target coming from start:
+// downwards when the target lies below the start, upwards otherwise.
+func reached(current, start, target float64) bool {
+	if target < start {
+		return current <= target
+	}
+	return current >= target
+}
+
+// progressPercent is how much of the way from start to target current
+// covers, between 0 and 100 and rounded to one decimal.
+func progressPercent(current, start, target float64) float64 {
+	if target == start {
+		return 100
+	}
+	percent := (current - start) / (target - start) * 100
+	return math.Round(math.Min(math.Max(percent, 0), 100)*10) / 10
+}
+
+// projectTrend returns the first day on or after today on which t reaches
+// target coming from current, or nil if it moves away from the target or
+// would take too long.
+func projectTrend(t trend, current, target float64, today time.Time) *string {
+	if t.slope == nil || t.intercept == nil || *t.slope == 0 {
+		return nil
+	}
+	if (target > current) != (*t.slope > 0) {
+		return nil
+	}
+	x := (target - *t.intercept) / *t.slope
+	return projectedDate(x, today)
+}
+
+// projectRate returns the day a cumulative total reaches target when it keeps
+// growing at the average rate since start, or nil without any progress yet.
+func projectRate(current, target float64, start, today time.Time) *string {
+	elapsed := today.Sub(start).Hours()/24 + 1
+	if current <= 0 || elapsed <= 0 {
+		return nil
+	}
+	perDay := current / elapsed
+	return projectedDate(epochDays(today)+(target-current)/perDay, today)
+}
+
+func projectedDate(x float64, today time.Time) *string {
+	first := epochDays(today)
+	if x < first {
+		x = first
+	}
+	if x-first > maxProjectionDays {
+		return nil
+	}
+	date := dateFromEpochDays(x).Format(time.DateOnly)
+	return &date
+}
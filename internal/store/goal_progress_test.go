@@ -0,0 +1,41 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoalProgressPercent(t *testing.T) {
+	assert.Equal(t, 80.0, progressPercent(80, 0, 100))
+	assert.Equal(t, 100.0, progressPercent(120, 0, 100))
+	// losing weight from 90 towards 80
+	assert.Equal(t, 25.0, progressPercent(87.5, 90, 80))
+	assert.Equal(t, 0.0, progressPercent(92, 90, 80))
+	assert.True(t, reached(79.9, 90, 80))
+	assert.False(t, reached(85, 90, 80))
+	assert.True(t, reached(100, 80, 100))
+}
+
+func TestGoalProjection(t *testing.T) {
+	today := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	// gaining half a kilogram a day from 80 kg today
+	slope := 0.5
+	intercept := 80 - slope*epochDays(today)
+	projected := projectTrend(trend{slope: &slope, intercept: &intercept}, 80, 100, today)
+	require.NotNil(t, projected)
+	assert.Equal(t, "2025-04-10", *projected)
+
+	// a trend moving away from the target projects nothing
+	assert.Nil(t, projectTrend(trend{slope: &slope, intercept: &intercept}, 80, 70, today))
+	assert.Nil(t, projectTrend(trend{}, 80, 100, today))
+
+	// 10 workouts in the first 10 days reach 20 ten days later
+	start := today.AddDate(0, 0, -9)
+	projected = projectRate(10, 20, start, today)
+	require.NotNil(t, projected)
+	assert.Equal(t, "2025-03-11", *projected)
+	assert.Nil(t, projectRate(0, 20, start, today))
+}
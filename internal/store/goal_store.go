@@ -0,0 +1,522 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"time"
+)
+
+const (
+	GoalStrength   = "strength"
+	GoalFrequency  = "frequency"
+	GoalVolume     = "volume"
+	GoalBodyWeight = "body_weight"
+)
+
+const (
+	GoalActive    = "active"
+	GoalAchieved  = "achieved"
+	GoalMissed    = "missed"
+	GoalAbandoned = "abandoned"
+)
+
+var ErrNoBodyWeight = errors.New("no body weight to start from")
+
+func IsValidGoalType(goalType string) bool {
+	switch goalType {
+	case GoalStrength, GoalFrequency, GoalVolume, GoalBodyWeight:
+		return true
+	}
+	return false
+}
+
+func IsValidGoalStatus(status string) bool {
+	switch status {
+	case GoalActive, GoalAchieved, GoalMissed, GoalAbandoned:
+		return true
+	}
+	return false
+}
+
+// Goal is a target the user works towards:
+//   - strength: lift TargetValue kilograms on ExerciseID in a working set
+//   - frequency: train TargetValue times per week from StartDate to TargetDate
+//   - volume: move TargetValue kilograms in total from StartDate, on
+//     ExerciseID or on every exercise
+//   - body_weight: get from StartValue to TargetValue kilograms
+//
+// Inside the store weights are kilograms; the api layer converts and sets
+// WeightUnit. Dates are YYYY-MM-DD in the user's time zone.
+type Goal struct {
+	ID           int        `json:"id"`
+	UserID       int        `json:"user_id"`
+	GoalType     string     `json:"goal_type"`
+	Title        string     `json:"title"`
+	ExerciseID   *int       `json:"exercise_id"`
+	ExerciseName string     `json:"exercise_name,omitempty"`
+	TargetValue  float64    `json:"target_value"`
+	StartValue   *float64   `json:"start_value"`
+	WeightUnit   string     `json:"weight_unit,omitempty"`
+	StartDate    string     `json:"start_date"`
+	TargetDate   *string    `json:"target_date"`
+	Status       string     `json:"status"`
+	AchievedAt   *time.Time `json:"achieved_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	// Progress is evaluated against the user's workouts whenever goals are read.
+	Progress *GoalProgress `json:"progress,omitempty"`
+	// Events are the statuses the goal went through, oldest first; only
+	// GetGoalByID loads them.
+	Events []GoalEvent `json:"events,omitempty"`
+}
+
+// GoalEvent records a goal entering Status, with the value that got it there.
+type GoalEvent struct {
+	Status     string    `json:"status"`
+	Value      *float64  `json:"value"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// IsWeightGoal reports whether the target and progress values are weights.
+func (g *Goal) IsWeightGoal() bool {
+	return g.GoalType != GoalFrequency
+}
+
+type PostgresGoalStore struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func NewPostgresGoalStore(db *sql.DB, queryTimeout time.Duration) *PostgresGoalStore {
+	return &PostgresGoalStore{db: db, queryTimeout: queryTimeout}
+}
+
+// GoalStore evaluates goals as it reads them: active goals whose target was
+// reached become achieved, and those past their target date missed.
+type GoalStore interface {
+	CreateGoal(ctx context.Context, goal *Goal) error
+	GetGoalByID(ctx context.Context, id int, today time.Time) (*Goal, error)
+	ListGoals(ctx context.Context, userID int, status string, today time.Time) ([]*Goal, error)
+	UpdateGoal(ctx context.Context, goal *Goal) error
+	DeleteGoal(ctx context.Context, id int) error
+}
+
+const goalColumns = `g.id, g.user_id, g.goal_type, g.title, g.exercise_id, COALESCE(e.name, ''), g.target_value, g.start_value,
+	to_char(g.start_date, 'YYYY-MM-DD'), to_char(g.target_date, 'YYYY-MM-DD'), g.status, g.achieved_at, g.created_at, g.updated_at`
+
+func scanGoal(row rowScanner) (*Goal, error) {
+	var g Goal
+	err := row.Scan(&g.ID, &g.UserID, &g.GoalType, &g.Title, &g.ExerciseID, &g.ExerciseName, &g.TargetValue, &g.StartValue,
+		&g.StartDate, &g.TargetDate, &g.Status, &g.AchievedAt, &g.CreatedAt, &g.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// CreateGoal saves an active goal. Without a StartValue it starts from the
+// current best lift or body weight; a body weight goal fails with
+// ErrNoBodyWeight when there is none.
+func (store *PostgresGoalStore) CreateGoal(ctx context.Context, goal *Goal) error {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if goal.StartValue == nil {
+		switch goal.GoalType {
+		case GoalStrength:
+			goal.StartValue, err = bestLift(ctx, tx, goal.UserID, *goal.ExerciseID)
+		case GoalBodyWeight:
+			goal.StartValue, err = latestBodyWeight(ctx, tx, goal.UserID)
+			if err == nil && goal.StartValue == nil {
+				err = ErrNoBodyWeight
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	goal.Status = GoalActive
+	query := `INSERT INTO goals (user_id, goal_type, title, exercise_id, target_value, start_value, start_date, target_date, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at, updated_at`
+	err = tx.QueryRowContext(ctx, query, goal.UserID, goal.GoalType, goal.Title, goal.ExerciseID, goal.TargetValue, goal.StartValue, goal.StartDate, goal.TargetDate, goal.Status).
+		Scan(&goal.ID, &goal.CreatedAt, &goal.UpdatedAt)
+	if isForeignKeyViolation(err) {
+		return ErrUnknownExercise
+	}
+	if err != nil {
+		return err
+	}
+
+	err = recordGoalEvent(ctx, tx, goal.ID, goal.Status, goal.StartValue)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetGoalByID returns the goal evaluated as of today, with its events.
+func (store *PostgresGoalStore) GetGoalByID(ctx context.Context, id int, today time.Time) (*Goal, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `SELECT ` + goalColumns + ` FROM goals g LEFT JOIN exercises e ON e.id = g.exercise_id WHERE g.id = $1`
+	goal, err := scanGoal(tx.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, err
+	}
+
+	err = evaluateGoal(ctx, tx, goal, today)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT status, value, occurred_at FROM goal_events WHERE goal_id = $1 ORDER BY occurred_at, id`, goal.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	goal.Events = []GoalEvent{}
+	for rows.Next() {
+		var event GoalEvent
+		err := rows.Scan(&event.Status, &event.Value, &event.OccurredAt)
+		if err != nil {
+			return nil, err
+		}
+		goal.Events = append(goal.Events, event)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return goal, nil
+}
+
+// ListGoals returns the user's goals with the given status, or all of them,
+// evaluated as of today: active ones first, then the most recent.
+func (store *PostgresGoalStore) ListGoals(ctx context.Context, userID int, status string, today time.Time) ([]*Goal, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `SELECT ` + goalColumns + `
+		FROM goals g
+		LEFT JOIN exercises e ON e.id = g.exercise_id
+		WHERE g.user_id = $1
+		ORDER BY g.status = 'active' DESC, g.created_at DESC, g.id DESC`
+	rows, err := tx.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var goals []*Goal
+	for rows.Next() {
+		goal, err := scanGoal(rows)
+		if err != nil {
+			return nil, err
+		}
+		goals = append(goals, goal)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// evaluation can change the status, so filter afterwards
+	filtered := []*Goal{}
+	for _, goal := range goals {
+		err = evaluateGoal(ctx, tx, goal, today)
+		if err != nil {
+			return nil, err
+		}
+		if status == "" || goal.Status == status {
+			filtered = append(filtered, goal)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return filtered, nil
+}
+
+// UpdateGoal saves the title, target, target date and status of a goal,
+// recording an event when the status changes.
+func (store *PostgresGoalStore) UpdateGoal(ctx context.Context, goal *Goal) error {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var previousStatus string
+	err = tx.QueryRowContext(ctx, `SELECT status FROM goals WHERE id = $1 FOR UPDATE`, goal.ID).Scan(&previousStatus)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE goals
+		SET title = $1, target_value = $2, target_date = $3, status = $4,
+			achieved_at = CASE WHEN $4 = 'achieved' THEN COALESCE(achieved_at, NOW()) END,
+			updated_at = NOW()
+		WHERE id = $5
+		RETURNING achieved_at, updated_at`
+	err = tx.QueryRowContext(ctx, query, goal.Title, goal.TargetValue, goal.TargetDate, goal.Status, goal.ID).Scan(&goal.AchievedAt, &goal.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if goal.Status != previousStatus {
+		err = recordGoalEvent(ctx, tx, goal.ID, goal.Status, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (store *PostgresGoalStore) DeleteGoal(ctx context.Context, id int) error {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	result, err := store.db.ExecContext(ctx, `DELETE FROM goals WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func recordGoalEvent(ctx context.Context, tx *sql.Tx, goalID int, status string, value *float64) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO goal_events (goal_id, status, value) VALUES ($1, $2, $3)`, goalID, status, value)
+	return err
+}
+
+// evaluateGoal sets the progress of goal as of today and moves an active goal
+// to achieved or missed when it got there.
+func evaluateGoal(ctx context.Context, tx *sql.Tx, goal *Goal, today time.Time) error {
+	progress, done, err := goalProgress(ctx, tx, goal, today)
+	if err != nil {
+		return err
+	}
+	goal.Progress = progress
+	if goal.Status != GoalActive {
+		return nil
+	}
+
+	switch {
+	case done:
+		goal.Status = GoalAchieved
+	case goal.TargetDate != nil && *goal.TargetDate < today.Format(time.DateOnly):
+		goal.Status = GoalMissed
+	default:
+		return nil
+	}
+	progress.ProjectedDate = nil
+
+	// concurrent reads evaluate the same goal; only the first moves it and
+	// records the event, the others pick up what it wrote
+	query := `UPDATE goals
+		SET status = $1, achieved_at = CASE WHEN $1 = 'achieved' THEN NOW() END, updated_at = NOW()
+		WHERE id = $2 AND status = 'active'
+		RETURNING achieved_at, updated_at`
+	err = tx.QueryRowContext(ctx, query, goal.Status, goal.ID).Scan(&goal.AchievedAt, &goal.UpdatedAt)
+	if err == sql.ErrNoRows {
+		query = `SELECT status, achieved_at, updated_at FROM goals WHERE id = $1`
+		return tx.QueryRowContext(ctx, query, goal.ID).Scan(&goal.Status, &goal.AchievedAt, &goal.UpdatedAt)
+	}
+	if err != nil {
+		return err
+	}
+	return recordGoalEvent(ctx, tx, goal.ID, goal.Status, progress.CurrentValue)
+}
+
+// goalProgress measures goal against the user's workouts and body weight and
+// reports whether its target was reached.
+func goalProgress(ctx context.Context, tx *sql.Tx, goal *Goal, today time.Time) (*GoalProgress, bool, error) {
+	start, err := time.Parse(time.DateOnly, goal.StartDate)
+	if err != nil {
+		return nil, false, err
+	}
+	progress := &GoalProgress{Target: goal.TargetValue}
+	var current float64
+	var done bool
+
+	switch goal.GoalType {
+	case GoalStrength:
+		progress.CurrentValue, err = bestLift(ctx, tx, goal.UserID, *goal.ExerciseID)
+		if err != nil {
+			return nil, false, err
+		}
+		if progress.CurrentValue != nil {
+			current = *progress.CurrentValue
+		}
+		t, err := queryTrend(ctx, tx, strengthTrendQuery, goal.UserID, *goal.ExerciseID, goal.StartDate)
+		if err != nil {
+			return nil, false, err
+		}
+		done = current >= goal.TargetValue
+		// measured from where the lifter stood when setting the goal
+		var startValue float64
+		if goal.StartValue != nil && *goal.StartValue < goal.TargetValue {
+			startValue = *goal.StartValue
+		}
+		progress.Percent = progressPercent(current, startValue, goal.TargetValue)
+		progress.ProjectedDate = projectTrend(t, current, goal.TargetValue, today)
+
+	case GoalBodyWeight:
+		progress.CurrentValue, err = latestBodyWeight(ctx, tx, goal.UserID)
+		if err != nil {
+			return nil, false, err
+		}
+		startValue := goal.TargetValue
+		if goal.StartValue != nil {
+			startValue = *goal.StartValue
+		}
+		current = startValue
+		if progress.CurrentValue != nil {
+			current = *progress.CurrentValue
+		}
+		t, err := queryTrend(ctx, tx, bodyWeightTrendQuery, goal.UserID, goal.StartDate)
+		if err != nil {
+			return nil, false, err
+		}
+		done = progress.CurrentValue != nil && reached(current, startValue, goal.TargetValue)
+		progress.Percent = progressPercent(current, startValue, goal.TargetValue)
+		progress.ProjectedDate = projectTrend(t, current, goal.TargetValue, today)
+
+	case GoalVolume:
+		err = tx.QueryRowContext(ctx, volumeQuery, goal.UserID, goal.ExerciseID, goal.StartDate).Scan(&current)
+		if err != nil {
+			return nil, false, err
+		}
+		progress.CurrentValue = &current
+		done = current >= goal.TargetValue
+		progress.Percent = progressPercent(current, 0, goal.TargetValue)
+		progress.ProjectedDate = projectRate(current, goal.TargetValue, start, today)
+
+	case GoalFrequency:
+		end, err := time.Parse(time.DateOnly, *goal.TargetDate)
+		if err != nil {
+			return nil, false, err
+		}
+		err = tx.QueryRowContext(ctx, frequencyQuery, goal.UserID, goal.StartDate, *goal.TargetDate).Scan(&current)
+		if err != nil {
+			return nil, false, err
+		}
+		// TargetValue per week over the whole period
+		weeks := (end.Sub(start).Hours()/24 + 1) / 7
+		progress.Target = math.Ceil(goal.TargetValue * weeks)
+		progress.CurrentValue = &current
+		done = current >= progress.Target
+		progress.Percent = progressPercent(current, 0, progress.Target)
+		if projected := projectRate(current, progress.Target, start, today); projected != nil && *projected <= *goal.TargetDate {
+			progress.ProjectedDate = projected
+		}
+	}
+
+	if done {
+		progress.ProjectedDate = nil
+	}
+	return progress, done, nil
+}
+
+// bestLift is the heaviest working set the user lifted on the exercise, or
+// nil if they never did.
+func bestLift(ctx context.Context, tx *sql.Tx, userID, exerciseID int) (*float64, error) {
+	var best *float64
+	query := `SELECT MAX(value) FROM personal_records WHERE user_id = $1 AND exercise_id = $2 AND record_type = 'max_weight'`
+	err := tx.QueryRowContext(ctx, query, userID, exerciseID).Scan(&best)
+	return best, err
+}
+
+// latestBodyWeight is the user's most recent body weight measurement, else
+// the one in their profile.
+func latestBodyWeight(ctx context.Context, tx *sql.Tx, userID int) (*float64, error) {
+	var weight *float64
+	query := `SELECT COALESCE((
+			SELECT value FROM measurements
+			WHERE user_id = u.id AND kind = 'body_weight'
+			ORDER BY measured_at DESC, id DESC
+			LIMIT 1
+		), u.body_weight)
+		FROM users u WHERE u.id = $1`
+	err := tx.QueryRowContext(ctx, query, userID).Scan(&weight)
+	return weight, err
+}
+
+// goalSets selects the working sets with a load that user $1 logged; queries
+// add their own date and exercise conditions.
+const goalSets = `
+	FROM workout_sets ws
+	JOIN workout_entries we ON we.id = ws.entry_id
+	JOIN workouts w ON w.id = we.workout_id
+	JOIN users u ON u.id = w.user_id
+	WHERE w.user_id = $1 AND ws.set_type <> 'warmup' AND ws.reps > 0 AND ws.weight > 0`
+
+// strengthTrendQuery fits a line through the heaviest set of each workout.
+const strengthTrendQuery = `WITH points AS (
+		SELECT w.performed_at, MAX(ws.weight) AS value` + goalSets + `
+			AND we.exercise_id = $2 AND (w.performed_at AT TIME ZONE u.timezone)::date >= $3::date
+		GROUP BY w.id, w.performed_at
+	)
+	SELECT regr_slope(value, EXTRACT(EPOCH FROM performed_at) / 86400), regr_intercept(value, EXTRACT(EPOCH FROM performed_at) / 86400)
+	FROM points`
+
+const bodyWeightTrendQuery = `SELECT regr_slope(m.value, EXTRACT(EPOCH FROM m.measured_at) / 86400), regr_intercept(m.value, EXTRACT(EPOCH FROM m.measured_at) / 86400)
+	FROM measurements m
+	JOIN users u ON u.id = m.user_id
+	WHERE m.user_id = $1 AND m.kind = 'body_weight' AND (m.measured_at AT TIME ZONE u.timezone)::date >= $2::date`
+
+// volumeQuery totals the load moved on exercise $2, or on every exercise when it is NULL.
+const volumeQuery = `SELECT COALESCE(SUM(ws.reps * ws.weight), 0)` + goalSets + `
+		AND ($2::bigint IS NULL OR we.exercise_id = $2) AND (w.performed_at AT TIME ZONE u.timezone)::date >= $3::date`
+
+const frequencyQuery = `SELECT COUNT(*)
+	FROM workouts w
+	JOIN users u ON u.id = w.user_id
+	WHERE w.user_id = $1 AND w.status = 'completed'
+		AND (w.performed_at AT TIME ZONE u.timezone)::date BETWEEN $2::date AND $3::date`
+
+func queryTrend(ctx context.Context, tx *sql.Tx, query string, args ...any) (trend, error) {
+	var t trend
+	err := tx.QueryRowContext(ctx, query, args...).Scan(&t.slope, &t.intercept)
+	return t, err
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoalsAreEvaluatedAgainstWorkouts(t *testing.T) {
+	db := SetupTestDB(t)
+	defer db.Close()
+
+	goalStore := NewPostgresGoalStore(db, 5*time.Second)
+	workoutStore := NewPostgresWorkoutStore(db, 5*time.Second)
+	user := createTestUser(t, db)
+	today := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	logBench := func(weight float64, performedAt time.Time) int {
+		workout, err := workoutStore.CreateWorkout(context.Background(), &Workout{
+			UserID:      user.ID,
+			Title:       "bench",
+			PerformedAt: performedAt,
+			Entries:     []WorkoutEntry{{ExerciseName: "Bench Press", Sets: []WorkoutSet{{Reps: IntPtr(5), Weight: FloatPtr(weight)}}}},
+		})
+		require.NoError(t, err)
+		return workout.Entries[0].ExerciseID
+	}
+	benchID := logBench(90, today.AddDate(0, 0, -7))
+
+	strength := Goal{UserID: user.ID, GoalType: GoalStrength, ExerciseID: &benchID, TargetValue: 100, StartDate: "2025-03-01"}
+	require.NoError(t, goalStore.CreateGoal(context.Background(), &strength))
+	require.NotNil(t, strength.StartValue)
+	assert.InDelta(t, 90, *strength.StartValue, 0.001)
+
+	targetDate := "2025-03-14"
+	frequency := Goal{UserID: user.ID, GoalType: GoalFrequency, TargetValue: 1, StartDate: "2025-03-01", TargetDate: &targetDate}
+	require.NoError(t, goalStore.CreateGoal(context.Background(), &frequency))
+
+	logBench(95, today.AddDate(0, 0, -3))
+	goal, err := goalStore.GetGoalByID(context.Background(), strength.ID, today)
+	require.NoError(t, err)
+	assert.Equal(t, GoalActive, goal.Status)
+	// halfway from the 90 kg the goal started at
+	assert.Equal(t, 50.0, goal.Progress.Percent)
+	// a kilogram and a quarter a day from 95 kg
+	require.NotNil(t, goal.Progress.ProjectedDate)
+	assert.Equal(t, "2025-03-11", *goal.Progress.ProjectedDate)
+
+	logBench(100, today)
+	goals, err := goalStore.ListGoals(context.Background(), user.ID, GoalAchieved, today)
+	require.NoError(t, err)
+	require.Len(t, goals, 2)
+
+	goal, err = goalStore.GetGoalByID(context.Background(), strength.ID, today)
+	require.NoError(t, err)
+	assert.NotNil(t, goal.AchievedAt)
+	require.Len(t, goal.Events, 2)
+	assert.Equal(t, GoalAchieved, goal.Events[1].Status)
+
+	bodyWeight := Goal{UserID: user.ID, GoalType: GoalBodyWeight, TargetValue: 75, StartDate: "2025-03-01"}
+	assert.ErrorIs(t, goalStore.CreateGoal(context.Background(), &bodyWeight), ErrNoBodyWeight)
+}
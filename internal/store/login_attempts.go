@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/sachanritik1/go-lang/internal/throttle"
+)
+
+// PostgresLoginAttemptStore implements throttle.Tracker on a shared table so
+// lockouts hold across instances. Every lockout is also written to login_lockouts for auditing.
+type PostgresLoginAttemptStore struct {
+	db           *sql.DB
+	policy       throttle.Policy
+	queryTimeout time.Duration
+}
+
+func NewPostgresLoginAttemptStore(db *sql.DB, policy throttle.Policy, queryTimeout time.Duration) *PostgresLoginAttemptStore {
+	return &PostgresLoginAttemptStore{db: db, policy: policy, queryTimeout: queryTimeout}
+}
+
+func (s *PostgresLoginAttemptStore) Check(ctx context.Context, key string) (time.Duration, error) {
+	ctx, cancel := withQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	query := `SELECT GREATEST(EXTRACT(EPOCH FROM locked_until - NOW()), 0) FROM login_attempts WHERE key = $1 AND locked_until > NOW()`
+	var seconds float64
+	err := s.db.QueryRowContext(ctx, query, key).Scan(&seconds)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+func (s *PostgresLoginAttemptStore) RecordFailure(ctx context.Context, key string) (time.Duration, error) {
+	ctx, cancel := withQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// a failure outside the window restarts the count
+	query := `
+		INSERT INTO login_attempts (key, failures, last_failure_at)
+		VALUES ($1, 1, NOW())
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE
+				WHEN login_attempts.last_failure_at < NOW() - make_interval(secs => $2) THEN 1
+				ELSE login_attempts.failures + 1
+			END,
+			last_failure_at = NOW()
+		RETURNING failures
+	`
+	var failures int
+	err = tx.QueryRowContext(ctx, query, key, s.policy.Window.Seconds()).Scan(&failures)
+	if err != nil {
+		return 0, err
+	}
+
+	lockout := s.policy.Lockout(failures)
+	if lockout > 0 {
+		lockedUntil := time.Now().Add(lockout)
+		_, err = tx.ExecContext(ctx, `UPDATE login_attempts SET locked_until = $1 WHERE key = $2`, lockedUntil, key)
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO login_lockouts (key, failures, locked_until) VALUES ($1, $2, $3)`, key, failures, lockedUntil)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	return lockout, nil
+}
+
+func (s *PostgresLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	ctx, cancel := withQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `DELETE FROM login_attempts WHERE key = $1`, key)
+	return err
+}
+
+func (s *PostgresLoginAttemptStore) Prune(ctx context.Context) error {
+	ctx, cancel := withQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	query := `
+		DELETE FROM login_attempts
+		WHERE last_failure_at < NOW() - make_interval(secs => $1)
+			AND (locked_until IS NULL OR locked_until < NOW())
+	`
+	_, err := s.db.ExecContext(ctx, query, s.policy.Window.Seconds())
+	return err
+}
@@ -0,0 +1,296 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+)
+
+// Kinds of body measurement. Circumferences are every kind that is neither
+// body weight nor body fat.
+const (
+	MeasurementBodyWeight = "body_weight"
+	MeasurementBodyFat    = "body_fat"
+	MeasurementNeck       = "neck"
+	MeasurementChest      = "chest"
+	MeasurementWaist      = "waist"
+	MeasurementHips       = "hips"
+	MeasurementArm        = "arm"
+	MeasurementForearm    = "forearm"
+	MeasurementThigh      = "thigh"
+	MeasurementCalf       = "calf"
+)
+
+var MeasurementKinds = []string{
+	MeasurementBodyWeight, MeasurementBodyFat,
+	MeasurementNeck, MeasurementChest, MeasurementWaist, MeasurementHips,
+	MeasurementArm, MeasurementForearm, MeasurementThigh, MeasurementCalf,
+}
+
+func IsValidMeasurementKind(kind string) bool {
+	return slices.Contains(MeasurementKinds, kind)
+}
+
+// Measurement is one reading of one kind. Inside the store Value is kilograms
+// for body weight, a percentage for body fat and meters for circumferences;
+// the api layer converts and sets Unit.
+type Measurement struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"user_id"`
+	Kind       string    `json:"kind"`
+	Value      float64   `json:"value"`
+	Unit       string    `json:"unit,omitempty"`
+	MeasuredAt time.Time `json:"measured_at"`
+	Notes      string    `json:"notes"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// MeasurementFilter narrows ListMeasurements; zero fields match everything.
+type MeasurementFilter struct {
+	Kind string
+	From *time.Time
+	To   *time.Time // exclusive
+}
+
+// MeasurementPoint is a reading in a time series with the average of the
+// readings in the window ending at it.
+type MeasurementPoint struct {
+	MeasuredAt    time.Time `json:"measured_at"`
+	Value         float64   `json:"value"`
+	MovingAverage float64   `json:"moving_average"`
+}
+
+type PostgresMeasurementStore struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func NewPostgresMeasurementStore(db *sql.DB, queryTimeout time.Duration) *PostgresMeasurementStore {
+	return &PostgresMeasurementStore{db: db, queryTimeout: queryTimeout}
+}
+
+type MeasurementStore interface {
+	CreateMeasurement(ctx context.Context, measurement *Measurement) error
+	GetMeasurementByID(ctx context.Context, id int) (*Measurement, error)
+	ListMeasurements(ctx context.Context, userID int, filter MeasurementFilter) ([]*Measurement, error)
+	GetLatestMeasurements(ctx context.Context, userID int) ([]*Measurement, error)
+	GetMeasurementSeries(ctx context.Context, userID int, filter MeasurementFilter, windowDays int) ([]*MeasurementPoint, error)
+	UpdateMeasurement(ctx context.Context, measurement *Measurement) error
+	DeleteMeasurement(ctx context.Context, id int) error
+}
+
+const measurementColumns = `id, user_id, kind, value, measured_at, notes, created_at, updated_at`
+
+func scanMeasurement(row rowScanner) (*Measurement, error) {
+	var m Measurement
+	err := row.Scan(&m.ID, &m.UserID, &m.Kind, &m.Value, &m.MeasuredAt, &m.Notes, &m.CreatedAt, &m.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// syncBodyWeight copies the user's most recent body weight reading to the
+// users table, where calorie estimates and strength ratios read it. Without
+// any reading it goes back to the weight the user entered in their profile.
+func syncBodyWeight(ctx context.Context, tx *sql.Tx, userID int) error {
+	query := `UPDATE users SET body_weight = COALESCE((
+			SELECT value FROM measurements
+			WHERE user_id = $1 AND kind = 'body_weight'
+			ORDER BY measured_at DESC, id DESC
+			LIMIT 1
+		), profile_body_weight)
+		WHERE id = $1`
+	_, err := tx.ExecContext(ctx, query, userID)
+	return err
+}
+
+func (store *PostgresMeasurementStore) CreateMeasurement(ctx context.Context, measurement *Measurement) error {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO measurements (user_id, kind, value, measured_at, notes)
+		VALUES ($1, $2, $3, COALESCE($4, NOW()), $5)
+		RETURNING id, measured_at, created_at, updated_at`
+	err = tx.QueryRowContext(ctx, query, measurement.UserID, measurement.Kind, measurement.Value, nullTime(measurement.MeasuredAt), measurement.Notes).
+		Scan(&measurement.ID, &measurement.MeasuredAt, &measurement.CreatedAt, &measurement.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if measurement.Kind == MeasurementBodyWeight {
+		err = syncBodyWeight(ctx, tx, measurement.UserID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (store *PostgresMeasurementStore) GetMeasurementByID(ctx context.Context, id int) (*Measurement, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	query := `SELECT ` + measurementColumns + ` FROM measurements WHERE id = $1`
+	return scanMeasurement(store.db.QueryRowContext(ctx, query, id))
+}
+
+// ListMeasurements returns the user's readings matching filter, newest first.
+func (store *PostgresMeasurementStore) ListMeasurements(ctx context.Context, userID int, filter MeasurementFilter) ([]*Measurement, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	query := `SELECT ` + measurementColumns + `
+		FROM measurements
+		WHERE user_id = $1
+			AND ($2 = '' OR kind = $2)
+			AND ($3::timestamptz IS NULL OR measured_at >= $3)
+			AND ($4::timestamptz IS NULL OR measured_at < $4)
+		ORDER BY measured_at DESC, id DESC`
+	return queryMeasurements(ctx, store.db, query, userID, filter.Kind, filter.From, filter.To)
+}
+
+// GetLatestMeasurements returns the most recent reading of every kind the
+// user measured.
+func (store *PostgresMeasurementStore) GetLatestMeasurements(ctx context.Context, userID int) ([]*Measurement, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	query := `SELECT DISTINCT ON (kind) ` + measurementColumns + `
+		FROM measurements
+		WHERE user_id = $1
+		ORDER BY kind, measured_at DESC, id DESC`
+	return queryMeasurements(ctx, store.db, query, userID)
+}
+
+func queryMeasurements(ctx context.Context, db queryer, query string, args ...any) ([]*Measurement, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	measurements := []*Measurement{}
+	for rows.Next() {
+		measurement, err := scanMeasurement(rows)
+		if err != nil {
+			return nil, err
+		}
+		measurements = append(measurements, measurement)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return measurements, nil
+}
+
+// GetMeasurementSeries returns the user's readings of filter.Kind in its
+// range, oldest first, each with the average of the readings taken in the
+// windowDays before it. Readings before the range still count towards the
+// averages at its start.
+func (store *PostgresMeasurementStore) GetMeasurementSeries(ctx context.Context, userID int, filter MeasurementFilter, windowDays int) ([]*MeasurementPoint, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	query := `SELECT measured_at, value, moving_average
+		FROM (
+			SELECT measured_at, value,
+				AVG(value) OVER (ORDER BY measured_at RANGE BETWEEN make_interval(days => $3::int) PRECEDING AND CURRENT ROW) AS moving_average
+			FROM measurements
+			WHERE user_id = $1 AND kind = $2 AND ($5::timestamptz IS NULL OR measured_at < $5)
+		) series
+		WHERE $4::timestamptz IS NULL OR measured_at >= $4
+		ORDER BY measured_at`
+	rows, err := store.db.QueryContext(ctx, query, userID, filter.Kind, windowDays, filter.From, filter.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []*MeasurementPoint{}
+	for rows.Next() {
+		var point MeasurementPoint
+		err := rows.Scan(&point.MeasuredAt, &point.Value, &point.MovingAverage)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, &point)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return points, nil
+}
+
+func (store *PostgresMeasurementStore) UpdateMeasurement(ctx context.Context, measurement *Measurement) error {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var previousKind string
+	err = tx.QueryRowContext(ctx, `SELECT kind FROM measurements WHERE id = $1`, measurement.ID).Scan(&previousKind)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE measurements
+		SET kind = $1, value = $2, measured_at = COALESCE($3, measured_at), notes = $4, updated_at = NOW()
+		WHERE id = $5
+		RETURNING user_id, measured_at, updated_at`
+	err = tx.QueryRowContext(ctx, query, measurement.Kind, measurement.Value, nullTime(measurement.MeasuredAt), measurement.Notes, measurement.ID).
+		Scan(&measurement.UserID, &measurement.MeasuredAt, &measurement.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if previousKind == MeasurementBodyWeight || measurement.Kind == MeasurementBodyWeight {
+		err = syncBodyWeight(ctx, tx, measurement.UserID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (store *PostgresMeasurementStore) DeleteMeasurement(ctx context.Context, id int) error {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	var kind string
+	query := `DELETE FROM measurements WHERE id = $1 RETURNING user_id, kind`
+	err = tx.QueryRowContext(ctx, query, id).Scan(&userID, &kind)
+	if err != nil {
+		return err
+	}
+
+	if kind == MeasurementBodyWeight {
+		err = syncBodyWeight(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,84 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMeasurementSeriesAndBodyWeight(t *testing.T) {
+	db := SetupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresMeasurementStore(db, 5*time.Second)
+	userStore := NewPostgresUserStore(db, 5*time.Second)
+	user := createTestUser(t, db)
+	day := time.Date(2025, 3, 1, 7, 0, 0, 0, time.UTC)
+
+	profileWeight := 75.0
+	user.ProfileBodyWeight = &profileWeight
+	_, err := userStore.UpdateUser(context.Background(), user)
+	require.NoError(t, err)
+	require.NotNil(t, user.BodyWeight)
+	assert.InDelta(t, 75, *user.BodyWeight, 0.001)
+
+	for i, weight := range []float64{82, 81, 80, 79} {
+		require.NoError(t, store.CreateMeasurement(context.Background(), &Measurement{
+			UserID:     user.ID,
+			Kind:       MeasurementBodyWeight,
+			Value:      weight,
+			MeasuredAt: day.AddDate(0, 0, 2*i),
+		}))
+	}
+	waist := Measurement{UserID: user.ID, Kind: MeasurementWaist, Value: 0.85, MeasuredAt: day}
+	require.NoError(t, store.CreateMeasurement(context.Background(), &waist))
+
+	from := day.AddDate(0, 0, 4)
+	points, err := store.GetMeasurementSeries(context.Background(), user.ID, MeasurementFilter{Kind: MeasurementBodyWeight, From: &from}, 3)
+	require.NoError(t, err)
+	require.Len(t, points, 2)
+	// the window reaches back to the reading before the range
+	assert.InDelta(t, 80.5, points[0].MovingAverage, 0.001)
+	assert.InDelta(t, 79.5, points[1].MovingAverage, 0.001)
+
+	latest, err := store.GetLatestMeasurements(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Len(t, latest, 2)
+
+	fetched, err := userStore.GetUserByID(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.NotNil(t, fetched.BodyWeight)
+	assert.InDelta(t, 79, *fetched.BodyWeight, 0.001)
+
+	// a profile change, even a stale one, leaves the latest reading in charge
+	_, err = userStore.UpdateUser(context.Background(), user)
+	require.NoError(t, err)
+	assert.InDelta(t, 79, *user.BodyWeight, 0.001)
+
+	// a backdated reading does not replace the latest one
+	old := Measurement{UserID: user.ID, Kind: MeasurementBodyWeight, Value: 90, MeasuredAt: day.AddDate(0, 0, -30)}
+	require.NoError(t, store.CreateMeasurement(context.Background(), &old))
+	fetched, err = userStore.GetUserByID(context.Background(), user.ID)
+	require.NoError(t, err)
+	assert.InDelta(t, 79, *fetched.BodyWeight, 0.001)
+
+	measurements, err := store.ListMeasurements(context.Background(), user.ID, MeasurementFilter{Kind: MeasurementBodyWeight})
+	require.NoError(t, err)
+	require.Len(t, measurements, 5)
+	require.NoError(t, store.DeleteMeasurement(context.Background(), measurements[0].ID))
+	fetched, err = userStore.GetUserByID(context.Background(), user.ID)
+	require.NoError(t, err)
+	assert.InDelta(t, 80, *fetched.BodyWeight, 0.001)
+
+	// without any reading left the weight from the profile applies again
+	for _, measurement := range measurements[1:] {
+		require.NoError(t, store.DeleteMeasurement(context.Background(), measurement.ID))
+	}
+	fetched, err = userStore.GetUserByID(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.NotNil(t, fetched.BodyWeight)
+	assert.InDelta(t, 75, *fetched.BodyWeight, 0.001)
+}
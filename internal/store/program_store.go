@@ -0,0 +1,680 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/jackc/pgtype"
+)
+
+const (
+	RuleTypePercentage = "percentage"
+	RuleTypeLinear     = "linear"
+)
+
+const (
+	EnrollmentActive    = "active"
+	EnrollmentCompleted = "completed"
+	EnrollmentCancelled = "cancelled"
+)
+
+const (
+	SessionUpcoming  = "upcoming"
+	SessionMissed    = "missed"
+	SessionCompleted = "completed"
+)
+
+var (
+	ErrUnknownTemplate      = errors.New("unknown template")
+	ErrTemplateInUse        = errors.New("template is used by a program")
+	ErrAlreadyEnrolled      = errors.New("already enrolled in this program")
+	ErrUnknownSession       = errors.New("unknown scheduled session")
+	ErrSessionAlreadyLogged = errors.New("scheduled session already has a workout")
+)
+
+func IsValidRuleType(ruleType string) bool {
+	return ruleType == RuleTypePercentage || ruleType == RuleTypeLinear
+}
+
+func IsValidEnrollmentStatus(status string) bool {
+	switch status {
+	case EnrollmentActive, EnrollmentCompleted, EnrollmentCancelled:
+		return true
+	}
+	return false
+}
+
+// Program is a multi-week plan: each day runs a template, and progression
+// rules set the working weight of an exercise for every week.
+type Program struct {
+	ID          int               `json:"id"`
+	UserID      int               `json:"user_id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Weeks       int               `json:"weeks"`
+	Days        []ProgramDay      `json:"days"`
+	Rules       []ProgressionRule `json:"rules"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// ProgramDay schedules a template on day 1-7 of a week, counted from the
+// enrollment's start date.
+type ProgramDay struct {
+	ID            int    `json:"id"`
+	Week          int    `json:"week"`
+	Day           int    `json:"day"`
+	TemplateID    int    `json:"template_id"`
+	TemplateTitle string `json:"template_title"`
+}
+
+// ProgressionRule prescribes the weight of an exercise per week, in kilograms.
+// Percentage rules cycle through Percentages of the training max BaseWeight,
+// adding Increment to it after every full cycle (5/3/1 style); linear rules
+// start at BaseWeight and add Increment every week.
+type ProgressionRule struct {
+	ID           int       `json:"id"`
+	ExerciseID   int       `json:"exercise_id"`
+	ExerciseName string    `json:"exercise_name"`
+	RuleType     string    `json:"rule_type"`
+	BaseWeight   float64   `json:"base_weight"`
+	Percentages  []float64 `json:"percentages"` // fractions of the training max, e.g. 0.85
+	Increment    float64   `json:"increment"`
+	RoundTo      float64   `json:"round_to"`              // plate increment targets are rounded to; 0 disables rounding
+	WeightUnit   string    `json:"weight_unit,omitempty"` // unit of the weights as sent or returned by the api layer
+}
+
+// TargetWeight returns the prescribed weight for the 1-based week.
+func (rule ProgressionRule) TargetWeight(week int) float64 {
+	var target float64
+	switch rule.RuleType {
+	case RuleTypePercentage:
+		if len(rule.Percentages) == 0 {
+			return 0
+		}
+		cycle, index := (week-1)/len(rule.Percentages), (week-1)%len(rule.Percentages)
+		trainingMax := rule.BaseWeight + float64(cycle)*rule.Increment
+		target = trainingMax * rule.Percentages[index]
+	default:
+		target = rule.BaseWeight + float64(week-1)*rule.Increment
+	}
+	if rule.RoundTo > 0 {
+		target = math.Round(target/rule.RoundTo) * rule.RoundTo
+	}
+	return target
+}
+
+// Enrollment is a user following a program from StartDate (YYYY-MM-DD).
+type Enrollment struct {
+	ID          int       `json:"id"`
+	UserID      int       `json:"user_id"`
+	ProgramID   int       `json:"program_id"`
+	ProgramName string    `json:"program_name"`
+	StartDate   string    `json:"start_date"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ScheduledSession is one program day of an active enrollment placed on the
+// calendar. WorkoutID is the workout that fulfilled it, if any.
+type ScheduledSession struct {
+	EnrollmentID  int             `json:"enrollment_id"`
+	ProgramID     int             `json:"program_id"`
+	ProgramName   string          `json:"program_name"`
+	ProgramDayID  int             `json:"program_day_id"`
+	Week          int             `json:"week"`
+	Day           int             `json:"day"`
+	Date          string          `json:"date"`
+	TemplateID    int             `json:"template_id"`
+	TemplateTitle string          `json:"template_title"`
+	Status        string          `json:"status"`
+	WorkoutID     *int            `json:"workout_id"`
+	Targets       []SessionTarget `json:"targets"`
+}
+
+// SessionTarget is the weight a progression rule prescribes for the session, in kilograms.
+type SessionTarget struct {
+	ExerciseID   int     `json:"exercise_id"`
+	ExerciseName string  `json:"exercise_name"`
+	Weight       float64 `json:"weight"`
+	WeightUnit   string  `json:"weight_unit,omitempty"` // set by the api layer
+}
+
+type PostgresProgramStore struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func NewPostgresProgramStore(db *sql.DB, queryTimeout time.Duration) *PostgresProgramStore {
+	return &PostgresProgramStore{db: db, queryTimeout: queryTimeout}
+}
+
+type ProgramStore interface {
+	CreateProgram(ctx context.Context, program *Program) (*Program, error)
+	GetProgramByID(ctx context.Context, id int) (*Program, error)
+	ListPrograms(ctx context.Context, userID int) ([]*Program, error)
+	UpdateProgram(ctx context.Context, program *Program) (*Program, error)
+	DeleteProgram(ctx context.Context, id int) error
+	Enroll(ctx context.Context, enrollment *Enrollment) error
+	GetEnrollmentByID(ctx context.Context, id int) (*Enrollment, error)
+	ListEnrollments(ctx context.Context, userID int) ([]*Enrollment, error)
+	UpdateEnrollmentStatus(ctx context.Context, id int, status string) error
+	GetSchedule(ctx context.Context, userID int, today, from, to time.Time) ([]*ScheduledSession, error)
+}
+
+// float64Array scans and writes DOUBLE PRECISION[] columns, mapping NULL to an empty slice.
+type float64Array []float64
+
+func (a *float64Array) Scan(src any) error {
+	var arr pgtype.Float8Array
+	if err := arr.Scan(src); err != nil {
+		return err
+	}
+	var values []float64
+	if err := arr.AssignTo(&values); err != nil {
+		return err
+	}
+	if values == nil {
+		values = []float64{}
+	}
+	*a = values
+	return nil
+}
+
+func (a float64Array) Value() (driver.Value, error) {
+	var arr pgtype.Float8Array
+	values := []float64(a)
+	if values == nil {
+		values = []float64{}
+	}
+	if err := arr.Set(values); err != nil {
+		return nil, err
+	}
+	return arr.Value()
+}
+
+func (store *PostgresProgramStore) CreateProgram(ctx context.Context, program *Program) (*Program, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO programs (user_id, name, description, weeks)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at`
+	err = tx.QueryRowContext(ctx, query, program.UserID, program.Name, program.Description, program.Weeks).
+		Scan(&program.ID, &program.CreatedAt, &program.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = saveProgramDays(ctx, tx, program)
+	if err != nil {
+		return nil, err
+	}
+	err = insertProgressionRules(ctx, tx, program)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return program, nil
+}
+
+func (store *PostgresProgramStore) GetProgramByID(ctx context.Context, id int) (*Program, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	program := Program{Days: []ProgramDay{}, Rules: []ProgressionRule{}}
+	query := `SELECT id, user_id, name, description, weeks, created_at, updated_at FROM programs WHERE id = $1`
+	err := store.db.QueryRowContext(ctx, query, id).
+		Scan(&program.ID, &program.UserID, &program.Name, &program.Description, &program.Weeks, &program.CreatedAt, &program.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	dayQuery := `SELECT d.id, d.week, d.day, d.template_id, t.title
+		FROM program_days d
+		JOIN workout_templates t ON t.id = d.template_id
+		WHERE d.program_id = $1
+		ORDER BY d.week, d.day`
+	rows, err := store.db.QueryContext(ctx, dayQuery, program.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var day ProgramDay
+		err := rows.Scan(&day.ID, &day.Week, &day.Day, &day.TemplateID, &day.TemplateTitle)
+		if err != nil {
+			return nil, err
+		}
+		program.Days = append(program.Days, day)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ruleQuery := `SELECT ` + ruleColumns + `
+		FROM progression_rules r
+		JOIN exercises e ON e.id = r.exercise_id
+		WHERE r.program_id = $1
+		ORDER BY e.name`
+	ruleRows, err := store.db.QueryContext(ctx, ruleQuery, program.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer ruleRows.Close()
+	for ruleRows.Next() {
+		rule, err := scanProgressionRule(ruleRows)
+		if err != nil {
+			return nil, err
+		}
+		program.Rules = append(program.Rules, rule)
+	}
+	if err = ruleRows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &program, nil
+}
+
+// ListPrograms returns the user's programs by name, without days and rules.
+func (store *PostgresProgramStore) ListPrograms(ctx context.Context, userID int) ([]*Program, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	query := `SELECT id, user_id, name, description, weeks, created_at, updated_at
+		FROM programs
+		WHERE user_id = $1
+		ORDER BY name, id`
+	rows, err := store.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	programs := []*Program{}
+	for rows.Next() {
+		program := Program{Days: []ProgramDay{}, Rules: []ProgressionRule{}}
+		err := rows.Scan(&program.ID, &program.UserID, &program.Name, &program.Description, &program.Weeks, &program.CreatedAt, &program.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		programs = append(programs, &program)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return programs, nil
+}
+
+// UpdateProgram saves the program with its days and rules. Days keep their
+// ids as long as their week and day stay, so logged workouts stay linked;
+// workouts of removed days are unlinked.
+func (store *PostgresProgramStore) UpdateProgram(ctx context.Context, program *Program) (*Program, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE programs
+		SET name = $1, description = $2, weeks = $3, updated_at = NOW()
+		WHERE id = $4
+		RETURNING user_id, updated_at`
+	err = tx.QueryRowContext(ctx, query, program.Name, program.Description, program.Weeks, program.ID).
+		Scan(&program.UserID, &program.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = saveProgramDays(ctx, tx, program)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM progression_rules WHERE program_id = $1`, program.ID)
+	if err != nil {
+		return nil, err
+	}
+	err = insertProgressionRules(ctx, tx, program)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return program, nil
+}
+
+func (store *PostgresProgramStore) DeleteProgram(ctx context.Context, id int) error {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	result, err := store.db.ExecContext(ctx, `DELETE FROM programs WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// saveProgramDays upserts program.Days by week and day and drops the days no
+// longer listed, unlinking workouts that fulfilled them.
+func saveProgramDays(ctx context.Context, tx *sql.Tx, program *Program) error {
+	if program.Days == nil {
+		program.Days = []ProgramDay{}
+	}
+
+	keep := make([]int, 0, len(program.Days))
+	for i := range program.Days {
+		day := &program.Days[i]
+		query := `SELECT title FROM workout_templates WHERE id = $1 AND user_id = $2`
+		err := tx.QueryRowContext(ctx, query, day.TemplateID, program.UserID).Scan(&day.TemplateTitle)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUnknownTemplate
+		}
+		if err != nil {
+			return err
+		}
+
+		upsert := `INSERT INTO program_days (program_id, week, day, template_id)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (program_id, week, day) DO UPDATE SET template_id = EXCLUDED.template_id
+			RETURNING id`
+		err = tx.QueryRowContext(ctx, upsert, program.ID, day.Week, day.Day, day.TemplateID).Scan(&day.ID)
+		if err != nil {
+			return err
+		}
+		keep = append(keep, day.ID)
+	}
+
+	removed := `SELECT id FROM program_days WHERE program_id = $1 AND NOT (id = ANY($2))`
+	rows, err := tx.QueryContext(ctx, removed, program.ID, int64Array(keep))
+	if err != nil {
+		return err
+	}
+	var removedIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		removedIDs = append(removedIDs, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range removedIDs {
+		_, err = tx.ExecContext(ctx, `UPDATE workouts SET enrollment_id = NULL, program_day_id = NULL WHERE program_day_id = $1`, id)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM program_days WHERE id = $1`, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func insertProgressionRules(ctx context.Context, tx *sql.Tx, program *Program) error {
+	if program.Rules == nil {
+		program.Rules = []ProgressionRule{}
+	}
+	for i := range program.Rules {
+		rule := &program.Rules[i]
+		if rule.Percentages == nil {
+			rule.Percentages = []float64{}
+		}
+
+		query := `SELECT name FROM exercises WHERE id = $1 AND (user_id IS NULL OR user_id = $2)`
+		err := tx.QueryRowContext(ctx, query, rule.ExerciseID, program.UserID).Scan(&rule.ExerciseName)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUnknownExercise
+		}
+		if err != nil {
+			return err
+		}
+
+		insert := `INSERT INTO progression_rules (program_id, exercise_id, rule_type, base_weight, percentages, increment, round_to)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id`
+		err = tx.QueryRowContext(ctx, insert, program.ID, rule.ExerciseID, rule.RuleType, rule.BaseWeight, float64Array(rule.Percentages), rule.Increment, rule.RoundTo).
+			Scan(&rule.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const ruleColumns = `r.id, r.exercise_id, e.name, r.rule_type, r.base_weight, r.percentages, r.increment, r.round_to`
+
+func scanProgressionRule(row rowScanner, extra ...any) (ProgressionRule, error) {
+	var rule ProgressionRule
+	dest := append(extra, &rule.ID, &rule.ExerciseID, &rule.ExerciseName, &rule.RuleType, &rule.BaseWeight, (*float64Array)(&rule.Percentages), &rule.Increment, &rule.RoundTo)
+	err := row.Scan(dest...)
+	return rule, err
+}
+
+// Enroll starts the user on a program. A program can only have one active
+// enrollment per user at a time.
+func (store *PostgresProgramStore) Enroll(ctx context.Context, enrollment *Enrollment) error {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	if enrollment.Status == "" {
+		enrollment.Status = EnrollmentActive
+	}
+	query := `INSERT INTO program_enrollments (user_id, program_id, start_date, status)
+		VALUES ($1, $2, $3::date, $4)
+		RETURNING id, (SELECT name FROM programs WHERE id = $2), created_at`
+	err := store.db.QueryRowContext(ctx, query, enrollment.UserID, enrollment.ProgramID, enrollment.StartDate, enrollment.Status).
+		Scan(&enrollment.ID, &enrollment.ProgramName, &enrollment.CreatedAt)
+	if isUniqueViolation(err, "program_enrollments_active_key") {
+		return ErrAlreadyEnrolled
+	}
+	return err
+}
+
+const enrollmentColumns = `pe.id, pe.user_id, pe.program_id, p.name, to_char(pe.start_date, 'YYYY-MM-DD'), pe.status, pe.created_at`
+
+func scanEnrollment(row rowScanner) (*Enrollment, error) {
+	var e Enrollment
+	err := row.Scan(&e.ID, &e.UserID, &e.ProgramID, &e.ProgramName, &e.StartDate, &e.Status, &e.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (store *PostgresProgramStore) GetEnrollmentByID(ctx context.Context, id int) (*Enrollment, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	query := `SELECT ` + enrollmentColumns + `
+		FROM program_enrollments pe
+		JOIN programs p ON p.id = pe.program_id
+		WHERE pe.id = $1`
+	return scanEnrollment(store.db.QueryRowContext(ctx, query, id))
+}
+
+// ListEnrollments returns the user's enrollments, active ones first.
+func (store *PostgresProgramStore) ListEnrollments(ctx context.Context, userID int) ([]*Enrollment, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	query := `SELECT ` + enrollmentColumns + `
+		FROM program_enrollments pe
+		JOIN programs p ON p.id = pe.program_id
+		WHERE pe.user_id = $1
+		ORDER BY pe.status = 'active' DESC, pe.start_date DESC, pe.id DESC`
+	rows, err := store.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	enrollments := []*Enrollment{}
+	for rows.Next() {
+		enrollment, err := scanEnrollment(rows)
+		if err != nil {
+			return nil, err
+		}
+		enrollments = append(enrollments, enrollment)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return enrollments, nil
+}
+
+func (store *PostgresProgramStore) UpdateEnrollmentStatus(ctx context.Context, id int, status string) error {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	result, err := store.db.ExecContext(ctx, `UPDATE program_enrollments SET status = $1 WHERE id = $2`, status, id)
+	if isUniqueViolation(err, "program_enrollments_active_key") {
+		return ErrAlreadyEnrolled
+	}
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// sessionDate is the calendar date of program day d for enrollment pe.
+const sessionDate = `(pe.start_date + (d.week - 1) * 7 + (d.day - 1))`
+
+// GetSchedule places the days of the user's active enrollments on the
+// calendar between from and to (dates, to exclusive). Sessions before today
+// without a linked workout are missed.
+func (store *PostgresProgramStore) GetSchedule(ctx context.Context, userID int, today, from, to time.Time) ([]*ScheduledSession, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	query := `SELECT pe.id, p.id, p.name, d.id, d.week, d.day, to_char(` + sessionDate + `, 'YYYY-MM-DD'),
+			d.template_id, t.title, w.id
+		FROM program_enrollments pe
+		JOIN programs p ON p.id = pe.program_id
+		JOIN program_days d ON d.program_id = p.id AND d.week <= p.weeks
+		JOIN workout_templates t ON t.id = d.template_id
+		LEFT JOIN workouts w ON w.enrollment_id = pe.id AND w.program_day_id = d.id
+		WHERE pe.user_id = $1 AND pe.status = 'active'
+			AND ` + sessionDate + ` >= $2::date AND ` + sessionDate + ` < $3::date
+		ORDER BY ` + sessionDate + `, pe.id, d.week, d.day`
+	rows, err := store.db.QueryContext(ctx, query, userID, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	todayDate := today.Format(time.DateOnly)
+	sessions := []*ScheduledSession{}
+	byDay := map[int]*ScheduledSession{}
+	for rows.Next() {
+		session := ScheduledSession{Targets: []SessionTarget{}}
+		var workoutID sql.NullInt64
+		err := rows.Scan(&session.EnrollmentID, &session.ProgramID, &session.ProgramName, &session.ProgramDayID,
+			&session.Week, &session.Day, &session.Date, &session.TemplateID, &session.TemplateTitle, &workoutID)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case workoutID.Valid:
+			id := int(workoutID.Int64)
+			session.WorkoutID = &id
+			session.Status = SessionCompleted
+		case session.Date < todayDate:
+			session.Status = SessionMissed
+		default:
+			session.Status = SessionUpcoming
+		}
+		sessions = append(sessions, &session)
+		byDay[session.ProgramDayID] = &session
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// targets for the rules whose exercise the session's template trains
+	ruleQuery := `SELECT d.id, ` + ruleColumns + `
+		FROM program_enrollments pe
+		JOIN program_days d ON d.program_id = pe.program_id
+		JOIN progression_rules r ON r.program_id = pe.program_id
+		JOIN exercises e ON e.id = r.exercise_id
+		WHERE pe.user_id = $1 AND pe.status = 'active'
+			AND EXISTS (SELECT 1 FROM template_entries te WHERE te.template_id = d.template_id AND te.exercise_id = r.exercise_id)
+		ORDER BY d.id, e.name`
+	ruleRows, err := store.db.QueryContext(ctx, ruleQuery, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer ruleRows.Close()
+	for ruleRows.Next() {
+		var dayID int
+		rule, err := scanProgressionRule(ruleRows, &dayID)
+		if err != nil {
+			return nil, err
+		}
+		session, ok := byDay[dayID]
+		if !ok {
+			continue
+		}
+		session.Targets = append(session.Targets, SessionTarget{
+			ExerciseID:   rule.ExerciseID,
+			ExerciseName: rule.ExerciseName,
+			Weight:       rule.TargetWeight(session.Week),
+		})
+	}
+	if err = ruleRows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// int64Array writes an integer slice as a BIGINT[] parameter.
+func int64Array(values []int) driver.Valuer {
+	var arr pgtype.Int8Array
+	ints := make([]int64, len(values))
+	for i, v := range values {
+		ints[i] = int64(v)
+	}
+	_ = arr.Set(ints)
+	return &arr
+}
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressionRuleTargetWeight(t *testing.T) {
+	fiveThreeOne := ProgressionRule{
+		RuleType:    RuleTypePercentage,
+		BaseWeight:  100,
+		Percentages: []float64{0.85, 0.9, 0.95, 0.6},
+		Increment:   5,
+		RoundTo:     2.5,
+	}
+	assert.InDelta(t, 85, fiveThreeOne.TargetWeight(1), 0.001)
+	assert.InDelta(t, 95, fiveThreeOne.TargetWeight(3), 0.001)
+	assert.InDelta(t, 60, fiveThreeOne.TargetWeight(4), 0.001)
+	// the second cycle runs off a training max of 105
+	assert.InDelta(t, 90, fiveThreeOne.TargetWeight(5), 0.001)
+
+	linear := ProgressionRule{RuleType: RuleTypeLinear, BaseWeight: 60, Increment: 2.5}
+	assert.InDelta(t, 60, linear.TargetWeight(1), 0.001)
+	assert.InDelta(t, 67.5, linear.TargetWeight(4), 0.001)
+}
+
+func TestScheduleTracksLinkedWorkouts(t *testing.T) {
+	db := SetupTestDB(t)
+	defer db.Close()
+
+	templateStore := NewPostgresTemplateStore(db, 5*time.Second)
+	programStore := NewPostgresProgramStore(db, 5*time.Second)
+	workoutStore := NewPostgresWorkoutStore(db, 5*time.Second)
+	user := createTestUser(t, db)
+
+	template, err := templateStore.CreateTemplate(context.Background(), &WorkoutTemplate{
+		UserID:  user.ID,
+		Title:   "squat day",
+		Entries: []TemplateEntry{{ExerciseName: "Back Squat", Sets: []WorkoutSet{{Reps: IntPtr(5)}}, OrderIndex: 1}},
+	})
+	require.NoError(t, err)
+	squatID := template.Entries[0].ExerciseID
+
+	program, err := programStore.CreateProgram(context.Background(), &Program{
+		UserID: user.ID,
+		Name:   "squat every week",
+		Weeks:  2,
+		Days:   []ProgramDay{{Week: 1, Day: 1, TemplateID: template.ID}, {Week: 2, Day: 1, TemplateID: template.ID}},
+		Rules:  []ProgressionRule{{ExerciseID: squatID, RuleType: RuleTypeLinear, BaseWeight: 100, Increment: 5}},
+	})
+	require.NoError(t, err)
+
+	enrollment := Enrollment{UserID: user.ID, ProgramID: program.ID, StartDate: "2025-03-03"}
+	require.NoError(t, programStore.Enroll(context.Background(), &enrollment))
+	assert.ErrorIs(t, programStore.Enroll(context.Background(), &Enrollment{UserID: user.ID, ProgramID: program.ID, StartDate: "2025-03-10"}), ErrAlreadyEnrolled)
+
+	_, err = workoutStore.CreateWorkout(context.Background(), &Workout{
+		UserID:       user.ID,
+		Title:        "squat day",
+		EnrollmentID: &enrollment.ID,
+		ProgramDayID: &program.Days[0].ID,
+	})
+	require.NoError(t, err)
+	_, err = workoutStore.CreateWorkout(context.Background(), &Workout{
+		UserID:       user.ID,
+		Title:        "squat day again",
+		EnrollmentID: &enrollment.ID,
+		ProgramDayID: &program.Days[0].ID,
+	})
+	assert.ErrorIs(t, err, ErrSessionAlreadyLogged)
+
+	today := time.Date(2025, 3, 12, 0, 0, 0, 0, time.UTC)
+	sessions, err := programStore.GetSchedule(context.Background(), user.ID, today, today.AddDate(0, 0, -30), today.AddDate(0, 0, 30))
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, SessionCompleted, sessions[0].Status)
+	assert.Equal(t, "2025-03-10", sessions[1].Date)
+	assert.Equal(t, SessionMissed, sessions[1].Status)
+	require.Len(t, sessions[1].Targets, 1)
+	assert.InDelta(t, 105, sessions[1].Targets[0].Weight, 0.001)
+
+	assert.ErrorIs(t, templateStore.DeleteTemplate(context.Background(), template.ID), ErrTemplateInUse)
+}
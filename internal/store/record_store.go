@@ -0,0 +1,201 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	RecordMaxWeight    = "max_weight"
+	RecordMaxReps      = "max_reps"
+	RecordEstimated1RM = "estimated_1rm"
+	RecordMaxDuration  = "max_duration"
+)
+
+// PersonalRecord is a set that beat every earlier set of its exercise in one
+// measure. Value is kilograms for max_weight and estimated_1rm, reps for
+// max_reps and seconds for max_duration; Weight is the load a max_reps record
+// was set at, nil for bodyweight.
+type PersonalRecord struct {
+	ID           int      `json:"id"`
+	ExerciseID   int      `json:"exercise_id"`
+	ExerciseName string   `json:"exercise_name"`
+	RecordType   string   `json:"record_type"`
+	Value        float64  `json:"value"`
+	Weight       *float64 `json:"weight"`
+	WeightUnit   string   `json:"weight_unit,omitempty"` // set by the api layer for weight-based records
+	// RelativeStrength is Value over the user's latest body weight, set by the
+	// api layer for weight-based records when the body weight is known.
+	RelativeStrength *float64  `json:"relative_strength,omitempty"`
+	WorkoutID        int       `json:"workout_id"`
+	SetID            int       `json:"set_id"`
+	AchievedAt       time.Time `json:"achieved_at"`
+}
+
+// IsWeightRecord reports whether Value is a weight rather than a count or duration.
+func (pr *PersonalRecord) IsWeightRecord() bool {
+	return pr.RecordType == RecordMaxWeight || pr.RecordType == RecordEstimated1RM
+}
+
+type PostgresRecordStore struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func NewPostgresRecordStore(db *sql.DB, queryTimeout time.Duration) *PostgresRecordStore {
+	return &PostgresRecordStore{db: db, queryTimeout: queryTimeout}
+}
+
+type RecordStore interface {
+	ListPersonalRecords(ctx context.Context, userID int) ([]*PersonalRecord, error)
+	GetRecordHistory(ctx context.Context, userID, exerciseID int) ([]*PersonalRecord, error)
+}
+
+const recordColumns = `pr.id, pr.exercise_id, e.name, pr.record_type, pr.value, pr.weight, pr.workout_id, pr.set_id, pr.achieved_at`
+
+// ListPersonalRecords returns the user's current best for every exercise and
+// record type; max_reps has one best per weight.
+func (store *PostgresRecordStore) ListPersonalRecords(ctx context.Context, userID int) ([]*PersonalRecord, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	query := `SELECT ` + recordColumns + `
+		FROM (
+			SELECT DISTINCT ON (exercise_id, record_type, weight) *
+			FROM personal_records
+			WHERE user_id = $1
+			ORDER BY exercise_id, record_type, weight, value DESC, achieved_at
+		) pr
+		JOIN exercises e ON e.id = pr.exercise_id
+		ORDER BY e.name, pr.record_type, pr.weight NULLS FIRST`
+	return queryRecords(ctx, store.db, query, userID)
+}
+
+// GetRecordHistory returns every record the user set on the exercise, oldest first.
+func (store *PostgresRecordStore) GetRecordHistory(ctx context.Context, userID, exerciseID int) ([]*PersonalRecord, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	query := `SELECT ` + recordColumns + `
+		FROM personal_records pr
+		JOIN exercises e ON e.id = pr.exercise_id
+		WHERE pr.user_id = $1 AND pr.exercise_id = $2
+		ORDER BY pr.record_type, pr.achieved_at, pr.id`
+	return queryRecords(ctx, store.db, query, userID, exerciseID)
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func queryRecords(ctx context.Context, db queryer, query string, args ...any) ([]*PersonalRecord, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []*PersonalRecord{}
+	for rows.Next() {
+		var record PersonalRecord
+		err := rows.Scan(&record.ID, &record.ExerciseID, &record.ExerciseName, &record.RecordType, &record.Value, &record.Weight, &record.WorkoutID, &record.SetID, &record.AchievedAt)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, &record)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// recomputeRecordsQuery rebuilds the record history of one user's exercise
+// from their sets. Warm-up sets never count. A set only becomes a record when
+// it strictly beats every set performed before it, ties going to the earlier set.
+// Estimated 1RM uses Brzycki up to 10 reps and Epley beyond, where the two agree.
+const recomputeRecordsQuery = `
+	WITH sets AS (
+		SELECT w.user_id, we.exercise_id, w.id AS workout_id, ws.id AS set_id, w.performed_at,
+			we.order_index, ws.set_number, ws.reps, ws.weight, ws.duration_seconds
+		FROM workout_sets ws
+		JOIN workout_entries we ON we.id = ws.entry_id
+		JOIN workouts w ON w.id = we.workout_id
+		WHERE w.user_id = $1 AND we.exercise_id = $2 AND ws.set_type <> 'warmup'
+	),
+	candidates AS (
+		SELECT *, 'max_weight' AS record_type, weight AS value, NULL::DECIMAL AS at_weight
+		FROM sets WHERE weight > 0 AND reps > 0
+		UNION ALL
+		SELECT *, 'max_reps', reps, weight
+		FROM sets WHERE reps > 0
+		UNION ALL
+		SELECT *, 'estimated_1rm',
+			CASE WHEN reps <= 10 THEN weight * 36 / (37 - reps) ELSE weight * (1 + reps / 30.0) END,
+			NULL
+		FROM sets WHERE weight > 0 AND reps > 0
+		UNION ALL
+		SELECT *, 'max_duration', duration_seconds, NULL
+		FROM sets WHERE duration_seconds > 0
+	),
+	ranked AS (
+		SELECT *, MAX(value) OVER (
+			PARTITION BY record_type, at_weight
+			ORDER BY performed_at, workout_id, order_index, set_number
+			ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING
+		) AS previous_best
+		FROM candidates
+	)
+	INSERT INTO personal_records (user_id, exercise_id, record_type, value, weight, workout_id, set_id, achieved_at)
+	SELECT user_id, exercise_id, record_type, value, at_weight, workout_id, set_id, performed_at
+	FROM ranked
+	WHERE previous_best IS NULL OR value > previous_best`
+
+// recomputePersonalRecords replaces the records of the given exercises with
+// ones derived from the user's current sets. It runs inside the transaction
+// that changed the sets so records never disagree with the workouts.
+func recomputePersonalRecords(ctx context.Context, tx *sql.Tx, userID int, exerciseIDs []int) error {
+	if len(exerciseIDs) == 0 {
+		return nil
+	}
+
+	// Concurrent writes for a user take turns, or each would delete only the
+	// records it can see and insert a full history of its own. NO KEY UPDATE
+	// leaves foreign keys to the user free, so workouts can still be inserted.
+	_, err := tx.ExecContext(ctx, `SELECT 1 FROM users WHERE id = $1 FOR NO KEY UPDATE`, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, exerciseID := range exerciseIDs {
+		_, err := tx.ExecContext(ctx, `DELETE FROM personal_records WHERE user_id = $1 AND exercise_id = $2`, userID, exerciseID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, recomputeRecordsQuery, userID, exerciseID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// workoutExerciseIDs lists the distinct exercises logged in a workout.
+func workoutExerciseIDs(ctx context.Context, tx *sql.Tx, workoutID int) ([]int, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT DISTINCT exercise_id FROM workout_entries WHERE workout_id = $1`, workoutID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPersonalRecordsFollowWorkoutChanges(t *testing.T) {
+	db := SetupTestDB(t)
+	defer db.Close()
+
+	workoutStore := NewPostgresWorkoutStore(db, 5*time.Second)
+	recordStore := NewPostgresRecordStore(db, 5*time.Second)
+	user := createTestUser(t, db)
+	day := time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC)
+
+	logBench := func(performedAt time.Time, sets ...WorkoutSet) *Workout {
+		workout, err := workoutStore.CreateWorkout(context.Background(), &Workout{
+			UserID:          user.ID,
+			Title:           "bench",
+			DurationMinutes: 30,
+			PerformedAt:     performedAt,
+			Entries:         []WorkoutEntry{{ExerciseName: "Bench Press", Sets: sets, OrderIndex: 1}},
+		})
+		require.NoError(t, err)
+		return workout
+	}
+
+	first := logBench(day,
+		WorkoutSet{SetType: SetTypeWarmup, Reps: IntPtr(5), Weight: FloatPtr(200)},
+		WorkoutSet{Reps: IntPtr(5), Weight: FloatPtr(100)},
+	)
+	second := logBench(day.AddDate(0, 0, 7), WorkoutSet{Reps: IntPtr(3), Weight: FloatPtr(105)})
+	assert.NotEmpty(t, second.Records)
+
+	bestOf := func(recordType string) float64 {
+		records, err := recordStore.ListPersonalRecords(context.Background(), user.ID)
+		require.NoError(t, err)
+		for _, record := range records {
+			if record.RecordType == recordType && record.Weight == nil {
+				return record.Value
+			}
+		}
+		t.Fatalf("no %s record", recordType)
+		return 0
+	}
+	// the heavier warm-up set never counts
+	assert.Equal(t, 105.0, bestOf(RecordMaxWeight))
+	assert.InDelta(t, 112.5, bestOf(RecordEstimated1RM), 0.01)
+
+	history, err := recordStore.GetRecordHistory(context.Background(), user.ID, first.Entries[0].ExerciseID)
+	require.NoError(t, err)
+	var weights []float64
+	for _, record := range history {
+		if record.RecordType == RecordMaxWeight {
+			weights = append(weights, record.Value)
+		}
+	}
+	assert.Equal(t, []float64{100, 105}, weights)
+
+	require.NoError(t, workoutStore.DeleteWorkout(context.Background(), second.ID))
+	assert.Equal(t, 100.0, bestOf(RecordMaxWeight))
+}
+
+func TestConcurrentWorkoutsKeepOneRecordHistory(t *testing.T) {
+	db := SetupTestDB(t)
+	defer db.Close()
+
+	workoutStore := NewPostgresWorkoutStore(db, 5*time.Second)
+	recordStore := NewPostgresRecordStore(db, 5*time.Second)
+	user := createTestUser(t, db)
+	day := time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC)
+
+	// the same set every day sets a record only the first time
+	workouts := make([]*Workout, 4)
+	errs := make([]error, len(workouts))
+	var wg sync.WaitGroup
+	for i := range workouts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			workouts[i], errs[i] = workoutStore.CreateWorkout(context.Background(), &Workout{
+				UserID:          user.ID,
+				Title:           "bench",
+				DurationMinutes: 30,
+				PerformedAt:     day.AddDate(0, 0, i),
+				Entries:         []WorkoutEntry{{ExerciseName: "Bench Press", Sets: []WorkoutSet{{Reps: IntPtr(5), Weight: FloatPtr(100)}}, OrderIndex: 1}},
+			})
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+
+	history, err := recordStore.GetRecordHistory(context.Background(), user.ID, workouts[0].Entries[0].ExerciseID)
+	require.NoError(t, err)
+	var maxWeights int
+	for _, record := range history {
+		if record.RecordType == RecordMaxWeight && record.Weight == nil {
+			maxWeights++
+		}
+	}
+	assert.Equal(t, 1, maxWeights)
+}
@@ -0,0 +1,235 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+)
+
+func IsValidStatsInterval(interval string) bool {
+	return interval == IntervalWeek || interval == IntervalMonth
+}
+
+// StatsRange selects the workouts a stats query covers. From and To are
+// calendar dates in Timezone, To exclusive; buckets start on the Monday of a
+// week or the first of a month in that zone.
+type StatsRange struct {
+	Interval string
+	From     time.Time
+	To       time.Time
+	Timezone string
+}
+
+// TrainingBucket totals one week or month. Volume is the sum of reps times
+// weight over non-warm-up sets, in kilograms.
+type TrainingBucket struct {
+	PeriodStart     string  `json:"period_start"`
+	Workouts        int     `json:"workouts"`
+	ActiveDays      int     `json:"active_days"`
+	DurationMinutes int     `json:"duration_minutes"`
+	Calories        int     `json:"calories"`
+	Sets            int     `json:"sets"`
+	Reps            int     `json:"reps"`
+	Volume          float64 `json:"volume"`
+}
+
+type MuscleGroupVolume struct {
+	MuscleGroup string  `json:"muscle_group"`
+	Sets        int     `json:"sets"`
+	Reps        int     `json:"reps"`
+	Volume      float64 `json:"volume"`
+}
+
+// ProgressionPoint summarises one exercise within a bucket; weights are kilograms.
+type ProgressionPoint struct {
+	PeriodStart        string   `json:"period_start"`
+	Workouts           int      `json:"workouts"`
+	Sets               int      `json:"sets"`
+	Reps               int      `json:"reps"`
+	Volume             float64  `json:"volume"`
+	MaxWeight          *float64 `json:"max_weight"`
+	Estimated1RM       *float64 `json:"estimated_1rm"`
+	MaxDurationSeconds *int     `json:"max_duration_seconds"`
+	Distance           *float64 `json:"distance"` // meters
+}
+
+type PostgresStatsStore struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func NewPostgresStatsStore(db *sql.DB, queryTimeout time.Duration) *PostgresStatsStore {
+	return &PostgresStatsStore{db: db, queryTimeout: queryTimeout}
+}
+
+type StatsStore interface {
+	TrainingStats(ctx context.Context, userID int, r StatsRange) ([]TrainingBucket, error)
+	MuscleGroupStats(ctx context.Context, userID int, r StatsRange) ([]MuscleGroupVolume, error)
+	ExerciseProgression(ctx context.Context, userID, exerciseID int, r StatsRange) ([]ProgressionPoint, error)
+}
+
+// rangedWorkoutsCTE selects the user's workouts in the range with their local
+// bucket and day. It expects $1 user id, $2 interval, $3 time zone, $4 and $5
+// the local from and to dates.
+const rangedWorkoutsCTE = `
+	ranged AS (
+		SELECT w.id, w.duration_minutes, COALESCE(w.calories_burned, 0) AS calories,
+			date_trunc($2, w.performed_at AT TIME ZONE $3)::date AS bucket,
+			(w.performed_at AT TIME ZONE $3)::date AS day
+		FROM workouts w
+		WHERE w.user_id = $1
+			AND w.performed_at >= $4::date::timestamp AT TIME ZONE $3
+			AND w.performed_at < $5::date::timestamp AT TIME ZONE $3
+	)`
+
+func (r StatsRange) args(userID int) []any {
+	return []any{userID, r.Interval, r.Timezone, r.From.Format(time.DateOnly), r.To.Format(time.DateOnly)}
+}
+
+// TrainingStats returns one bucket per week or month of the range, including
+// empty ones, so clients can chart the series directly.
+func (store *PostgresStatsStore) TrainingStats(ctx context.Context, userID int, r StatsRange) ([]TrainingBucket, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	query := `
+		WITH ` + rangedWorkoutsCTE + `,
+		buckets AS (
+			SELECT generate_series(
+				date_trunc($2, $4::date::timestamp),
+				$5::date::timestamp - INTERVAL '1 day',
+				('1 ' || $2)::interval
+			)::date AS bucket
+		),
+		workout_totals AS (
+			SELECT bucket, COUNT(*) AS workouts, COUNT(DISTINCT day) AS active_days,
+				SUM(duration_minutes) AS duration, SUM(calories) AS calories
+			FROM ranged
+			GROUP BY bucket
+		),
+		set_totals AS (
+			SELECT ranged.bucket, COUNT(ws.id) AS sets, COALESCE(SUM(ws.reps), 0) AS reps,
+				COALESCE(SUM(ws.reps * ws.weight), 0) AS volume
+			FROM ranged
+			JOIN workout_entries we ON we.workout_id = ranged.id
+			JOIN workout_sets ws ON ws.entry_id = we.id
+			WHERE ws.set_type <> 'warmup'
+			GROUP BY ranged.bucket
+		)
+		SELECT to_char(b.bucket, 'YYYY-MM-DD'), COALESCE(wt.workouts, 0), COALESCE(wt.active_days, 0),
+			COALESCE(wt.duration, 0), COALESCE(wt.calories, 0),
+			COALESCE(st.sets, 0), COALESCE(st.reps, 0), COALESCE(st.volume, 0)
+		FROM buckets b
+		LEFT JOIN workout_totals wt ON wt.bucket = b.bucket
+		LEFT JOIN set_totals st ON st.bucket = b.bucket
+		ORDER BY b.bucket`
+
+	rows, err := store.db.QueryContext(ctx, query, r.args(userID)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := []TrainingBucket{}
+	for rows.Next() {
+		var b TrainingBucket
+		err := rows.Scan(&b.PeriodStart, &b.Workouts, &b.ActiveDays, &b.DurationMinutes, &b.Calories, &b.Sets, &b.Reps, &b.Volume)
+		if err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, b)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
+
+// MuscleGroupStats totals the range per muscle group. A set counts towards
+// every group its exercise works.
+func (store *PostgresStatsStore) MuscleGroupStats(ctx context.Context, userID int, r StatsRange) ([]MuscleGroupVolume, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	query := `
+		WITH ` + rangedWorkoutsCTE + `
+		SELECT mg.muscle_group, COUNT(*), COALESCE(SUM(ws.reps), 0), COALESCE(SUM(ws.reps * ws.weight), 0) AS volume
+		FROM ranged
+		JOIN workout_entries we ON we.workout_id = ranged.id
+		JOIN workout_sets ws ON ws.entry_id = we.id
+		JOIN exercises e ON e.id = we.exercise_id
+		CROSS JOIN LATERAL unnest(e.muscle_groups) AS mg(muscle_group)
+		WHERE ws.set_type <> 'warmup'
+		GROUP BY mg.muscle_group
+		ORDER BY volume DESC, mg.muscle_group`
+
+	rows, err := store.db.QueryContext(ctx, query, r.args(userID)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []MuscleGroupVolume{}
+	for rows.Next() {
+		var g MuscleGroupVolume
+		err := rows.Scan(&g.MuscleGroup, &g.Sets, &g.Reps, &g.Volume)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// ExerciseProgression returns the buckets in which the exercise was trained,
+// with the best weight, estimated 1RM and duration reached in each.
+func (store *PostgresStatsStore) ExerciseProgression(ctx context.Context, userID, exerciseID int, r StatsRange) ([]ProgressionPoint, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	query := `
+		WITH ` + rangedWorkoutsCTE + `
+		SELECT to_char(ranged.bucket, 'YYYY-MM-DD'),
+			COUNT(DISTINCT ranged.id),
+			COUNT(ws.id),
+			COALESCE(SUM(ws.reps), 0),
+			COALESCE(SUM(ws.reps * ws.weight), 0),
+			MAX(ws.weight) FILTER (WHERE ws.reps > 0),
+			MAX(CASE WHEN ws.reps <= 10 THEN ws.weight * 36 / (37 - ws.reps) ELSE ws.weight * (1 + ws.reps / 30.0) END) FILTER (WHERE ws.reps > 0 AND ws.weight > 0),
+			MAX(ws.duration_seconds),
+			SUM(ws.distance)
+		FROM ranged
+		JOIN workout_entries we ON we.workout_id = ranged.id
+		JOIN workout_sets ws ON ws.entry_id = we.id
+		WHERE we.exercise_id = $6 AND ws.set_type <> 'warmup'
+		GROUP BY ranged.bucket
+		ORDER BY ranged.bucket`
+
+	rows, err := store.db.QueryContext(ctx, query, append(r.args(userID), exerciseID)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []ProgressionPoint{}
+	for rows.Next() {
+		var p ProgressionPoint
+		err := rows.Scan(&p.PeriodStart, &p.Workouts, &p.Sets, &p.Reps, &p.Volume, &p.MaxWeight, &p.Estimated1RM, &p.MaxDurationSeconds, &p.Distance)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return points, nil
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrainingStatsBucketsInUserTimezone(t *testing.T) {
+	db := SetupTestDB(t)
+	defer db.Close()
+
+	workoutStore := NewPostgresWorkoutStore(db, 5*time.Second)
+	statsStore := NewPostgresStatsStore(db, 5*time.Second)
+	user := createTestUser(t, db)
+
+	// Sunday evening in New York is already Monday in UTC
+	for _, performedAt := range []time.Time{
+		time.Date(2025, 3, 3, 3, 0, 0, 0, time.UTC),
+		time.Date(2025, 3, 4, 18, 0, 0, 0, time.UTC),
+	} {
+		_, err := workoutStore.CreateWorkout(context.Background(), &Workout{
+			UserID:          user.ID,
+			Title:           "squats",
+			DurationMinutes: 40,
+			CaloriesBurned:  300,
+			PerformedAt:     performedAt,
+			Entries: []WorkoutEntry{{ExerciseName: "Back Squat", OrderIndex: 1, Sets: []WorkoutSet{
+				{SetType: SetTypeWarmup, Reps: IntPtr(5), Weight: FloatPtr(60)},
+				{Reps: IntPtr(5), Weight: FloatPtr(100)},
+				{Reps: IntPtr(5), Weight: FloatPtr(100)},
+			}}},
+		})
+		require.NoError(t, err)
+	}
+
+	buckets, err := statsStore.TrainingStats(context.Background(), user.ID, StatsRange{
+		Interval: IntervalWeek,
+		From:     time.Date(2025, 2, 24, 0, 0, 0, 0, time.UTC),
+		To:       time.Date(2025, 3, 17, 0, 0, 0, 0, time.UTC),
+		Timezone: "America/New_York",
+	})
+	require.NoError(t, err)
+	require.Len(t, buckets, 3)
+
+	assert.Equal(t, "2025-02-24", buckets[0].PeriodStart)
+	assert.Equal(t, 1, buckets[0].Workouts)
+	assert.Equal(t, 2, buckets[0].Sets)
+	assert.Equal(t, 1000.0, buckets[0].Volume)
+	assert.Equal(t, "2025-03-03", buckets[1].PeriodStart)
+	assert.Equal(t, 1, buckets[1].Workouts)
+	assert.Equal(t, 0, buckets[2].Workouts)
+
+	groups, err := statsStore.MuscleGroupStats(context.Background(), user.ID, StatsRange{
+		Interval: IntervalMonth,
+		From:     time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+		To:       time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+		Timezone: "America/New_York",
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, groups)
+	assert.Equal(t, 2000.0, groups[0].Volume)
+}
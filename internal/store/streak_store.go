@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type PostgresStreakStore struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func NewPostgresStreakStore(db *sql.DB, queryTimeout time.Duration) *PostgresStreakStore {
+	return &PostgresStreakStore{db: db, queryTimeout: queryTimeout}
+}
+
+type StreakStore interface {
+	GetStreaks(ctx context.Context, userID int, today time.Time, opts StreakOptions) (*Streaks, error)
+}
+
+// GetStreaks computes the user's streaks as of today, a date in their
+// timezone, from the workout_days summary rather than the workouts.
+func (store *PostgresStreakStore) GetStreaks(ctx context.Context, userID int, today time.Time, opts StreakOptions) (*Streaks, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	query := `SELECT day - DATE '1970-01-01', workouts FROM workout_days WHERE user_id = $1 AND day <= $2::date ORDER BY day`
+	rows, err := store.db.QueryContext(ctx, query, userID, today.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []workoutDay
+	for rows.Next() {
+		var d workoutDay
+		err = rows.Scan(&d.day, &d.workouts)
+		if err != nil {
+			return nil, err
+		}
+		days = append(days, d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return computeStreaks(days, today, opts), nil
+}
+
+// localDay is the day performed_at ($2) falls on in the timezone of user $1.
+const localDay = `(SELECT ($2::timestamptz AT TIME ZONE timezone)::date FROM users WHERE id = $1)`
+
+// addWorkoutDay counts a workout of the user performed at performedAt in
+// workout_days. It runs in the transaction writing the workout.
+func addWorkoutDay(ctx context.Context, tx *sql.Tx, userID int, performedAt time.Time) error {
+	query := `INSERT INTO workout_days (user_id, day, workouts) VALUES ($1, ` + localDay + `, 1)
+		ON CONFLICT (user_id, day) DO UPDATE SET workouts = workout_days.workouts + 1`
+	_, err := tx.ExecContext(ctx, query, userID, performedAt)
+	return err
+}
+
+// removeWorkoutDay undoes addWorkoutDay, dropping days left without workouts.
+func removeWorkoutDay(ctx context.Context, tx *sql.Tx, userID int, performedAt time.Time) error {
+	query := `DELETE FROM workout_days WHERE user_id = $1 AND day = ` + localDay + ` AND workouts = 1`
+	_, err := tx.ExecContext(ctx, query, userID, performedAt)
+	if err != nil {
+		return err
+	}
+
+	query = `UPDATE workout_days SET workouts = workouts - 1 WHERE user_id = $1 AND day = ` + localDay
+	_, err = tx.ExecContext(ctx, query, userID, performedAt)
+	return err
+}
+
+// rebuildWorkoutDays recounts the user's workout_days from their workouts,
+// needed once the days they fall on move with a new timezone.
+func rebuildWorkoutDays(ctx context.Context, tx *sql.Tx, userID int) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM workout_days WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO workout_days (user_id, day, workouts)
+		SELECT w.user_id, (w.performed_at AT TIME ZONE u.timezone)::date, COUNT(*)
+		FROM workouts w
+		JOIN users u ON u.id = w.user_id
+		WHERE w.user_id = $1
+		GROUP BY 1, 2`
+	_, err = tx.ExecContext(ctx, query, userID)
+	return err
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkoutDaysFollowWorkouts(t *testing.T) {
+	db := SetupTestDB(t)
+	defer db.Close()
+
+	streakStore := NewPostgresStreakStore(db, 5*time.Second)
+	workoutStore := NewPostgresWorkoutStore(db, 5*time.Second)
+	userStore := NewPostgresUserStore(db, 5*time.Second)
+	user := createTestUser(t, db)
+	today := time.Date(2025, 3, 12, 0, 0, 0, 0, time.UTC)
+	opts := StreakOptions{WeeklyTarget: 1}
+
+	logWorkout := func(performedAt time.Time) *Workout {
+		workout, err := workoutStore.CreateWorkout(context.Background(), &Workout{UserID: user.ID, Title: "run", DurationMinutes: 30, PerformedAt: performedAt})
+		require.NoError(t, err)
+		return workout
+	}
+	logWorkout(time.Date(2025, 3, 10, 18, 0, 0, 0, time.UTC))
+	second := logWorkout(time.Date(2025, 3, 11, 7, 0, 0, 0, time.UTC))
+	evening := logWorkout(time.Date(2025, 3, 11, 23, 30, 0, 0, time.UTC))
+
+	streaks, err := streakStore.GetStreaks(context.Background(), user.ID, today, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 2, streaks.Daily.Current.Length)
+	assert.Equal(t, 3, streaks.Consistency.Workouts30)
+
+	// moving a workout off its day keeps the day while another remains
+	second.PerformedAt = time.Date(2025, 3, 8, 7, 0, 0, 0, time.UTC)
+	_, err = workoutStore.UpdateWorkout(context.Background(), second)
+	require.NoError(t, err)
+	streaks, err = streakStore.GetStreaks(context.Background(), user.ID, today, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 2, streaks.Daily.Current.Length)
+	assert.Equal(t, 3, streaks.Consistency.TrainingDays30)
+
+	// the late workout falls on the next day in Tokyo
+	user.Timezone = "Asia/Tokyo"
+	_, err = userStore.UpdateUser(context.Background(), user)
+	require.NoError(t, err)
+	streaks, err = streakStore.GetStreaks(context.Background(), user.ID, today, opts)
+	require.NoError(t, err)
+	assert.Equal(t, "2025-03-12", *streaks.Consistency.LastTrainingDay)
+	assert.Equal(t, 2, streaks.Daily.Current.Length)
+
+	require.NoError(t, workoutStore.DeleteWorkout(context.Background(), evening.ID))
+	streaks, err = streakStore.GetStreaks(context.Background(), user.ID, today, opts)
+	require.NoError(t, err)
+	assert.Equal(t, "2025-03-11", *streaks.Consistency.LastTrainingDay)
+	assert.Equal(t, 2, streaks.Consistency.Workouts30)
+}
@@ -0,0 +1,159 @@
+package store
+
+import (
+	"math"
+	"time"
+)
+
+// StreakOptions configures how forgiving streaks are. RestDays is how many
+// days without training may sit between two training days of a daily streak;
+// a week extends a weekly streak when it has at least WeeklyTarget training
+// days, and RestWeeks weeks short of that may sit between two that do.
+type StreakOptions struct {
+	RestDays     int
+	WeeklyTarget int
+	RestWeeks    int
+}
+
+// Streak is a run of training days or weeks. Length counts the days or weeks
+// that met the target; Start and End (YYYY-MM-DD) are the first and last of
+// them, weeks given by their Monday. Both are nil for an empty streak.
+type Streak struct {
+	Length int     `json:"length"`
+	Start  *string `json:"start"`
+	End    *string `json:"end"`
+}
+
+type StreakSummary struct {
+	Current Streak `json:"current"`
+	Longest Streak `json:"longest"`
+}
+
+// Consistency sums up recent training: the last 30 days and the last 12
+// weeks, both ending today and counting the current week.
+type Consistency struct {
+	TrainingDays30    int     `json:"training_days_30"`
+	Workouts30        int     `json:"workouts_30"`
+	Percent30         float64 `json:"percent_30"`
+	WeeksOnTarget12   int     `json:"weeks_on_target_12"`
+	DaysPerWeek12     float64 `json:"days_per_week_12"`
+	LastTrainingDay   *string `json:"last_training_day"`
+	DaysSinceTraining *int    `json:"days_since_training"`
+}
+
+type Streaks struct {
+	Daily       StreakSummary `json:"daily"`
+	Weekly      StreakSummary `json:"weekly"`
+	Consistency Consistency   `json:"consistency"`
+}
+
+// workoutDay is a row of workout_days, day counted since the Unix epoch.
+type workoutDay struct {
+	day      int
+	workouts int
+}
+
+// run is a streak over day or week numbers.
+type run struct {
+	first, last, length int
+}
+
+func dayNumber(t time.Time) int {
+	return int(math.Floor(epochDays(t)))
+}
+
+// weekNumber numbers weeks starting on Monday; the epoch was a Thursday.
+func weekNumber(day int) int {
+	return int(math.Floor(float64(day+3) / 7))
+}
+
+func weekMonday(week int) int {
+	return week*7 - 3
+}
+
+func dayString(day int) *string {
+	date := time.Unix(int64(day)*86400, 0).UTC().Format(time.DateOnly)
+	return &date
+}
+
+// findRuns splits ascending points into runs whose neighbours are at most
+// maxGap apart. It returns the run still going at now, one that may still be
+// extended without breaking it, and the longest run, the latest on ties.
+func findRuns(points []int, maxGap, now int) (current, longest run) {
+	var r run
+	for i, point := range points {
+		if i == 0 || point-points[i-1] > maxGap {
+			r = run{first: point}
+		}
+		r.last, r.length = point, r.length+1
+		if r.length >= longest.length {
+			longest = r
+		}
+	}
+	if len(points) > 0 && now-r.last <= maxGap {
+		current = r
+	}
+	return current, longest
+}
+
+func (r run) streak(date func(int) int) Streak {
+	if r.length == 0 {
+		return Streak{}
+	}
+	return Streak{Length: r.length, Start: dayString(date(r.first)), End: dayString(date(r.last))}
+}
+
+// computeStreaks derives streaks and consistency from days, ascending and
+// holding only days with workouts, as of today in the user's timezone.
+func computeStreaks(days []workoutDay, today time.Time, opts StreakOptions) *Streaks {
+	now := dayNumber(today)
+	thisWeek := weekNumber(now)
+
+	var trainingDays, weeks []int
+	var consistency Consistency
+	perWeek := map[int]int{}
+	for _, d := range days {
+		if d.day > now {
+			break
+		}
+		trainingDays = append(trainingDays, d.day)
+		week := weekNumber(d.day)
+		perWeek[week]++
+		if perWeek[week] == opts.WeeklyTarget {
+			weeks = append(weeks, week)
+		}
+		if now-d.day < 30 {
+			consistency.TrainingDays30++
+			consistency.Workouts30 += d.workouts
+		}
+		if thisWeek-week < 12 {
+			consistency.DaysPerWeek12++
+		}
+	}
+
+	identity := func(day int) int { return day }
+	streaks := &Streaks{}
+	current, longest := findRuns(trainingDays, opts.RestDays+1, now)
+	streaks.Daily = StreakSummary{Current: current.streak(identity), Longest: longest.streak(identity)}
+	// the current week is not over yet, so falling short of the target so far
+	// does not end a weekly streak
+	current, longest = findRuns(weeks, opts.RestWeeks+1, thisWeek)
+	streaks.Weekly = StreakSummary{Current: current.streak(weekMonday), Longest: longest.streak(weekMonday)}
+
+	for week := thisWeek - 11; week <= thisWeek; week++ {
+		if opts.WeeklyTarget > 0 && perWeek[week] >= opts.WeeklyTarget {
+			consistency.WeeksOnTarget12++
+		}
+	}
+	consistency.Percent30 = math.Round(float64(consistency.TrainingDays30)/30*1000) / 10
+	consistency.DaysPerWeek12 = math.Round(consistency.DaysPerWeek12/12*10) / 10
+	if len(trainingDays) > 0 {
+		last := trainingDays[len(trainingDays)-1]
+		since := now - last
+		consistency.LastTrainingDay = dayString(last)
+		consistency.DaysSinceTraining = &since
+	}
+	streaks.Consistency = consistency
+
+	return streaks
+}
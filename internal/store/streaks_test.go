@@ -0,0 +1,95 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func trainingDays(dates ...string) []workoutDay {
+	days := make([]workoutDay, 0, len(dates))
+	for _, date := range dates {
+		t, _ := time.Parse(time.DateOnly, date)
+		days = append(days, workoutDay{day: dayNumber(t), workouts: 1})
+	}
+	return days
+}
+
+func TestWeekNumberStartsOnMonday(t *testing.T) {
+	monday := dayNumber(time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC))
+	sunday := dayNumber(time.Date(2025, 3, 16, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, weekNumber(monday), weekNumber(sunday))
+	assert.Equal(t, weekNumber(monday)-1, weekNumber(monday-1))
+	assert.Equal(t, "2025-03-10", *dayString(weekMonday(weekNumber(sunday))))
+}
+
+func TestDailyStreaks(t *testing.T) {
+	// Wednesday, 2025-03-12
+	today := time.Date(2025, 3, 12, 0, 0, 0, 0, time.UTC)
+	days := trainingDays("2025-03-01", "2025-03-02", "2025-03-03", "2025-03-04", "2025-03-09", "2025-03-11")
+
+	streaks := computeStreaks(days, today, StreakOptions{WeeklyTarget: 1})
+	// not training yet today keeps yesterday's streak going
+	assert.Equal(t, 1, streaks.Daily.Current.Length)
+	assert.Equal(t, "2025-03-11", *streaks.Daily.Current.Start)
+	assert.Equal(t, 4, streaks.Daily.Longest.Length)
+	assert.Equal(t, "2025-03-01", *streaks.Daily.Longest.Start)
+	assert.Equal(t, "2025-03-04", *streaks.Daily.Longest.End)
+
+	// a rest day in between no longer breaks it
+	streaks = computeStreaks(days, today, StreakOptions{RestDays: 1, WeeklyTarget: 1})
+	assert.Equal(t, 2, streaks.Daily.Current.Length)
+	assert.Equal(t, "2025-03-09", *streaks.Daily.Current.Start)
+
+	streaks = computeStreaks(days, today.AddDate(0, 0, 2), StreakOptions{WeeklyTarget: 1})
+	assert.Equal(t, Streak{}, streaks.Daily.Current)
+	assert.Equal(t, 4, streaks.Daily.Longest.Length)
+}
+
+func TestWeeklyStreaks(t *testing.T) {
+	today := time.Date(2025, 3, 12, 0, 0, 0, 0, time.UTC)
+	days := trainingDays(
+		"2025-02-10", "2025-02-12", // two days
+		"2025-02-17",               // one day
+		"2025-02-24", "2025-02-26", // two days
+		"2025-03-03", "2025-03-05", // two days
+		"2025-03-11", // one day so far this week
+	)
+
+	streaks := computeStreaks(days, today, StreakOptions{WeeklyTarget: 2})
+	// the unfinished week does not break the streak
+	assert.Equal(t, 2, streaks.Weekly.Current.Length)
+	assert.Equal(t, "2025-02-24", *streaks.Weekly.Current.Start)
+	assert.Equal(t, "2025-03-03", *streaks.Weekly.Current.End)
+	assert.Equal(t, 2, streaks.Weekly.Longest.Length)
+
+	streaks = computeStreaks(days, today, StreakOptions{WeeklyTarget: 2, RestWeeks: 1})
+	assert.Equal(t, 3, streaks.Weekly.Current.Length)
+	assert.Equal(t, "2025-02-10", *streaks.Weekly.Current.Start)
+
+	streaks = computeStreaks(days, today, StreakOptions{WeeklyTarget: 1})
+	assert.Equal(t, 5, streaks.Weekly.Current.Length)
+	assert.Equal(t, 5, streaks.Consistency.WeeksOnTarget12)
+}
+
+func TestConsistency(t *testing.T) {
+	today := time.Date(2025, 3, 12, 0, 0, 0, 0, time.UTC)
+	days := trainingDays("2024-12-01", "2025-02-10", "2025-03-01", "2025-03-11", "2025-03-20")
+	days[3].workouts = 2
+
+	consistency := computeStreaks(days, today, StreakOptions{WeeklyTarget: 1}).Consistency
+	// days after today are left out
+	assert.Equal(t, 2, consistency.TrainingDays30)
+	assert.Equal(t, 3, consistency.Workouts30)
+	assert.Equal(t, 6.7, consistency.Percent30)
+	assert.Equal(t, 0.3, consistency.DaysPerWeek12)
+	require.NotNil(t, consistency.LastTrainingDay)
+	assert.Equal(t, "2025-03-11", *consistency.LastTrainingDay)
+	assert.Equal(t, 1, *consistency.DaysSinceTraining)
+
+	empty := computeStreaks(nil, today, StreakOptions{WeeklyTarget: 1})
+	assert.Equal(t, StreakSummary{}, empty.Daily)
+	assert.Nil(t, empty.Consistency.DaysSinceTraining)
+}
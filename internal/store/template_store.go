@@ -0,0 +1,251 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// WorkoutTemplate is a reusable plan for a workout: the same shape as Workout
+// without anything that only exists once it was performed.
+type WorkoutTemplate struct {
+	ID              int             `json:"id"`
+	UserID          int             `json:"user_id"`
+	Title           string          `json:"title"`
+	Description     string          `json:"description"`
+	DurationMinutes int             `json:"duration_minutes"`
+	Entries         []TemplateEntry `json:"entries"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+}
+
+// TemplateEntry is a planned exercise; its sets hold targets rather than results.
+type TemplateEntry struct {
+	ID           int          `json:"id"`
+	TemplateID   int          `json:"template_id"`
+	ExerciseID   int          `json:"exercise_id"`
+	ExerciseName string       `json:"exercise_name"`
+	Sets         []WorkoutSet `json:"sets"`
+	Notes        string       `json:"notes"`
+	OrderIndex   int          `json:"order_index"`
+	// RestTargetSeconds is the planned rest between sets; a set's own
+	// rest_seconds overrides it.
+	RestTargetSeconds *int `json:"rest_target_seconds"`
+}
+
+type PostgresTemplateStore struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+func NewPostgresTemplateStore(db *sql.DB, queryTimeout time.Duration) *PostgresTemplateStore {
+	return &PostgresTemplateStore{db: db, queryTimeout: queryTimeout}
+}
+
+type TemplateStore interface {
+	CreateTemplate(ctx context.Context, template *WorkoutTemplate) (*WorkoutTemplate, error)
+	GetTemplateByID(ctx context.Context, id int) (*WorkoutTemplate, error)
+	ListTemplates(ctx context.Context, userID int) ([]*WorkoutTemplate, error)
+	UpdateTemplate(ctx context.Context, template *WorkoutTemplate) (*WorkoutTemplate, error)
+	DeleteTemplate(ctx context.Context, id int) error
+}
+
+func (store *PostgresTemplateStore) CreateTemplate(ctx context.Context, template *WorkoutTemplate) (*WorkoutTemplate, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO workout_templates (user_id, title, description, duration_minutes)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at`
+	err = tx.QueryRowContext(ctx, query, template.UserID, template.Title, template.Description, template.DurationMinutes).
+		Scan(&template.ID, &template.CreatedAt, &template.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertTemplateEntries(ctx, tx, template)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+func (store *PostgresTemplateStore) GetTemplateByID(ctx context.Context, id int) (*WorkoutTemplate, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	template := WorkoutTemplate{Entries: []TemplateEntry{}}
+	query := `SELECT id, user_id, title, description, duration_minutes, created_at, updated_at FROM workout_templates WHERE id = $1`
+	err := store.db.QueryRowContext(ctx, query, id).
+		Scan(&template.ID, &template.UserID, &template.Title, &template.Description, &template.DurationMinutes, &template.CreatedAt, &template.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	entryQuery := `SELECT id, template_id, exercise_id, exercise_name, notes, order_index, rest_target_seconds FROM template_entries WHERE template_id = $1 ORDER BY order_index`
+	rows, err := store.db.QueryContext(ctx, entryQuery, template.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entryIndex := map[int]int{}
+	for rows.Next() {
+		entry := TemplateEntry{Sets: []WorkoutSet{}}
+		err := rows.Scan(&entry.ID, &entry.TemplateID, &entry.ExerciseID, &entry.ExerciseName, &entry.Notes, &entry.OrderIndex, &entry.RestTargetSeconds)
+		if err != nil {
+			return nil, err
+		}
+		entryIndex[entry.ID] = len(template.Entries)
+		template.Entries = append(template.Entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	setQuery := `SELECT ` + templateSetColumns + `
+		FROM template_sets ws
+		JOIN template_entries te ON te.id = ws.entry_id
+		WHERE te.template_id = $1
+		ORDER BY ws.entry_id, ws.set_number`
+	sets, err := querySets(ctx, store.db, setQuery, template.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, set := range sets {
+		entry := &template.Entries[entryIndex[set.EntryID]]
+		entry.Sets = append(entry.Sets, set)
+	}
+
+	return &template, nil
+}
+
+// ListTemplates returns the user's templates by title, without their entries.
+func (store *PostgresTemplateStore) ListTemplates(ctx context.Context, userID int) ([]*WorkoutTemplate, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	query := `SELECT id, user_id, title, description, duration_minutes, created_at, updated_at
+		FROM workout_templates
+		WHERE user_id = $1
+		ORDER BY title, id`
+	rows, err := store.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []*WorkoutTemplate{}
+	for rows.Next() {
+		template := WorkoutTemplate{Entries: []TemplateEntry{}}
+		err := rows.Scan(&template.ID, &template.UserID, &template.Title, &template.Description, &template.DurationMinutes, &template.CreatedAt, &template.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, &template)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// UpdateTemplate saves the template and replaces all of its entries.
+func (store *PostgresTemplateStore) UpdateTemplate(ctx context.Context, template *WorkoutTemplate) (*WorkoutTemplate, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE workout_templates
+		SET title = $1, description = $2, duration_minutes = $3, updated_at = NOW()
+		WHERE id = $4
+		RETURNING updated_at`
+	err = tx.QueryRowContext(ctx, query, template.Title, template.Description, template.DurationMinutes, template.ID).Scan(&template.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM template_entries WHERE template_id = $1`, template.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertTemplateEntries(ctx, tx, template)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// DeleteTemplate removes a template. It fails with ErrTemplateInUse while a
+// program still schedules it.
+func (store *PostgresTemplateStore) DeleteTemplate(ctx context.Context, id int) error {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	result, err := store.db.ExecContext(ctx, `DELETE FROM workout_templates WHERE id = $1`, id)
+	if isForeignKeyViolation(err) {
+		return ErrTemplateInUse
+	}
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func insertTemplateEntries(ctx context.Context, tx *sql.Tx, template *WorkoutTemplate) error {
+	if template.Entries == nil {
+		template.Entries = []TemplateEntry{}
+	}
+	for i := range template.Entries {
+		entry := &template.Entries[i]
+		err := resolveExercise(ctx, tx, template.UserID, &entry.ExerciseID, &entry.ExerciseName, entry.Sets)
+		if err != nil {
+			return err
+		}
+
+		query := `INSERT INTO template_entries (template_id, exercise_id, exercise_name, notes, order_index, rest_target_seconds) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+		err = tx.QueryRowContext(ctx, query, template.ID, entry.ExerciseID, entry.ExerciseName, entry.Notes, entry.OrderIndex, entry.RestTargetSeconds).Scan(&entry.ID)
+		if err != nil {
+			return err
+		}
+		entry.TemplateID = template.ID
+
+		if entry.Sets == nil {
+			entry.Sets = []WorkoutSet{}
+		}
+		err = insertSets(ctx, tx, "template_sets", entry.ID, entry.Sets)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateCRUD(t *testing.T) {
+	db := SetupTestDB(t)
+	defer db.Close()
+
+	templateStore := NewPostgresTemplateStore(db, 5*time.Second)
+	user := createTestUser(t, db)
+
+	template, err := templateStore.CreateTemplate(context.Background(), &WorkoutTemplate{
+		UserID: user.ID,
+		Title:  "leg day",
+		Entries: []TemplateEntry{
+			{ExerciseName: "squat", Sets: []WorkoutSet{{Reps: IntPtr(5), Weight: FloatPtr(100)}, {Reps: IntPtr(5), Weight: FloatPtr(100)}}, OrderIndex: 1},
+			{ExerciseName: "Plank", Sets: []WorkoutSet{{DurationSeconds: IntPtr(60)}}, OrderIndex: 2},
+		},
+	})
+	require.NoError(t, err)
+
+	fetched, err := templateStore.GetTemplateByID(context.Background(), template.ID)
+	require.NoError(t, err)
+	require.Len(t, fetched.Entries, 2)
+	assert.Equal(t, "Back Squat", fetched.Entries[0].ExerciseName)
+	assert.Len(t, fetched.Entries[0].Sets, 2)
+
+	fetched.Entries = fetched.Entries[:1]
+	_, err = templateStore.UpdateTemplate(context.Background(), fetched)
+	require.NoError(t, err)
+
+	fetched, err = templateStore.GetTemplateByID(context.Background(), template.ID)
+	require.NoError(t, err)
+	assert.Len(t, fetched.Entries, 1)
+
+	templates, err := templateStore.ListTemplates(context.Background(), user.ID)
+	require.NoError(t, err)
+	assert.Len(t, templates, 1)
+
+	require.NoError(t, templateStore.DeleteTemplate(context.Background(), template.ID))
+	assert.ErrorIs(t, templateStore.DeleteTemplate(context.Background(), template.ID), sql.ErrNoRows)
+}
+
+func TestGetLatestExerciseSets(t *testing.T) {
+	db := SetupTestDB(t)
+	defer db.Close()
+
+	workoutStore := NewPostgresWorkoutStore(db, 5*time.Second)
+	user := createTestUser(t, db)
+	day := time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC)
+
+	var exerciseID int
+	for i, weight := range []float64{100, 110} {
+		workout, err := workoutStore.CreateWorkout(context.Background(), &Workout{
+			UserID:      user.ID,
+			Title:       "squat",
+			PerformedAt: day.AddDate(0, 0, i),
+			Entries:     []WorkoutEntry{{ExerciseName: "Back Squat", Sets: []WorkoutSet{{Reps: IntPtr(5), Weight: FloatPtr(weight)}}, OrderIndex: 1}},
+		})
+		require.NoError(t, err)
+		exerciseID = workout.Entries[0].ExerciseID
+	}
+
+	sets, err := workoutStore.GetLatestExerciseSets(context.Background(), user.ID, exerciseID)
+	require.NoError(t, err)
+	require.Len(t, sets, 1)
+	assert.InDelta(t, 110, *sets[0].Weight, 0.001)
+}
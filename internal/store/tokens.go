@@ -1,42 +1,78 @@
 package store
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"time"
 
 	"github.com/sachanritik1/go-lang/internal/tokens"
 )
 
+var (
+	ErrInvalidToken = errors.New("token is invalid or expired")
+	// ErrTokenReused is returned when an already rotated refresh token is presented again.
+	ErrTokenReused = errors.New("refresh token has already been used")
+)
+
+// Session describes one login: every token sharing a family.
+type Session struct {
+	ID         string     `json:"id"`
+	UserAgent  string     `json:"user_agent"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Expiry     time.Time  `json:"expiry"`
+}
+
 type PostgresTokenStore struct {
-	db *sql.DB
+	db           *sql.DB
+	queryTimeout time.Duration
 }
 
-func NewPostgresTokenStore(db *sql.DB) *PostgresTokenStore {
-	return &PostgresTokenStore{db: db}
+func NewPostgresTokenStore(db *sql.DB, queryTimeout time.Duration) *PostgresTokenStore {
+	return &PostgresTokenStore{db: db, queryTimeout: queryTimeout}
 }
 
 type TokenStore interface {
-	Insert(token *tokens.Token) error
-	CreateNewToken(userID int64, ttlMinutes int64, scope string) (*tokens.Token, error)
-	DeleteAllTokensForUser(userID int64, scope string) error
+	Insert(ctx context.Context, token *tokens.Token) error
+	CreateNewToken(ctx context.Context, userID int64, ttl time.Duration, scope string) (*tokens.Token, error)
+	CreateTokenPair(ctx context.Context, userID int64, authTTL, refreshTTL time.Duration, userAgent string) (*tokens.Token, *tokens.Token, error)
+	RotateRefreshToken(ctx context.Context, refreshPlainText string, authTTL, refreshTTL time.Duration, userAgent string) (*tokens.Token, *tokens.Token, error)
+	TouchToken(ctx context.Context, plainText string) error
+	ListSessions(ctx context.Context, userID int64) ([]*Session, error)
+	DeleteToken(ctx context.Context, plainText string) error
+	DeleteOtherSessions(ctx context.Context, userID int64, keepPlainText string) error
+	DeleteTokenFamily(ctx context.Context, family string) error
+	DeleteAllTokensForUser(ctx context.Context, userID int64, scope string) error
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
 }
 
-func (pts *PostgresTokenStore) Insert(token *tokens.Token) error {
+func insertToken(ctx context.Context, db execer, token *tokens.Token) error {
 	query := `
-		INSERT INTO tokens (hash, user_id, expiry, scope)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO tokens (hash, user_id, expiry, scope, family_id, user_agent)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
 	`
-	_, err := pts.db.Exec(query, token.Hash, token.UserID, token.Expiry, token.Scope)
+	_, err := db.ExecContext(ctx, query, token.Hash, token.UserID, token.Expiry, token.Scope, token.Family, token.UserAgent)
 	return err
 }
 
-func (pts *PostgresTokenStore) CreateNewToken(userID int64, ttlMinutes int64, scope string) (*tokens.Token, error) {
-	token, err := tokens.GenerateToken(userID, time.Duration(ttlMinutes)*time.Minute, scope)
+func (pts *PostgresTokenStore) Insert(ctx context.Context, token *tokens.Token) error {
+	ctx, cancel := withQueryTimeout(ctx, pts.queryTimeout)
+	defer cancel()
+
+	return insertToken(ctx, pts.db, token)
+}
+
+func (pts *PostgresTokenStore) CreateNewToken(ctx context.Context, userID int64, ttl time.Duration, scope string) (*tokens.Token, error) {
+	token, err := tokens.GenerateToken(userID, ttl, scope)
 	if err != nil {
 		return nil, err
 	}
 
-	err = pts.Insert(token)
+	err = pts.Insert(ctx, token)
 	if err != nil {
 		return nil, err
 	}
@@ -44,11 +80,223 @@ func (pts *PostgresTokenStore) CreateNewToken(userID int64, ttlMinutes int64, sc
 	return token, nil
 }
 
-func (pts *PostgresTokenStore) DeleteAllTokensForUser(userID int64, scope string) error {
+// CreateTokenPair starts a new token family with an authentication and a refresh token.
+func (pts *PostgresTokenStore) CreateTokenPair(ctx context.Context, userID int64, authTTL, refreshTTL time.Duration, userAgent string) (*tokens.Token, *tokens.Token, error) {
+	ctx, cancel := withQueryTimeout(ctx, pts.queryTimeout)
+	defer cancel()
+
+	family, err := tokens.NewFamilyID()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := pts.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	authToken, refreshToken, err := insertTokenPair(ctx, tx, userID, family, authTTL, refreshTTL, userAgent)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, nil, err
+	}
+	return authToken, refreshToken, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a fresh pair in the same family.
+// Presenting a token that was already rotated revokes the entire family and
+// returns ErrTokenReused, since only a leaked copy could be replayed.
+func (pts *PostgresTokenStore) RotateRefreshToken(ctx context.Context, refreshPlainText string, authTTL, refreshTTL time.Duration, userAgent string) (*tokens.Token, *tokens.Token, error) {
+	ctx, cancel := withQueryTimeout(ctx, pts.queryTimeout)
+	defer cancel()
+
+	tx, err := pts.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT user_id, expiry, COALESCE(family_id, ''), rotated_at
+		FROM tokens
+		WHERE hash = $1 AND scope = $2
+		FOR UPDATE
+	`
+	var (
+		userID    int64
+		expiry    time.Time
+		family    string
+		rotatedAt sql.NullTime
+	)
+	err = tx.QueryRowContext(ctx, query, tokens.Hash(refreshPlainText), tokens.ScopeRefresh).Scan(&userID, &expiry, &family, &rotatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if rotatedAt.Valid {
+		_, err = tx.ExecContext(ctx, `DELETE FROM tokens WHERE family_id = $1`, family)
+		if err != nil {
+			return nil, nil, err
+		}
+		err = tx.Commit()
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrTokenReused
+	}
+	if !expiry.After(time.Now()) || family == "" {
+		return nil, nil, ErrInvalidToken
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE tokens SET rotated_at = NOW() WHERE hash = $1`, tokens.Hash(refreshPlainText))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	authToken, refreshToken, err := insertTokenPair(ctx, tx, userID, family, authTTL, refreshTTL, userAgent)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, nil, err
+	}
+	return authToken, refreshToken, nil
+}
+
+func insertTokenPair(ctx context.Context, tx *sql.Tx, userID int64, family string, authTTL, refreshTTL time.Duration, userAgent string) (*tokens.Token, *tokens.Token, error) {
+	authToken, err := tokens.GenerateToken(userID, authTTL, tokens.ScopeAuth)
+	if err != nil {
+		return nil, nil, err
+	}
+	refreshToken, err := tokens.GenerateToken(userID, refreshTTL, tokens.ScopeRefresh)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, token := range []*tokens.Token{authToken, refreshToken} {
+		token.Family = family
+		token.UserAgent = userAgent
+		err = insertToken(ctx, tx, token)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return authToken, refreshToken, nil
+}
+
+// TouchToken records that a token was just used. Writes are throttled to one a
+// minute per token so authenticated traffic does not turn into a write per request.
+func (pts *PostgresTokenStore) TouchToken(ctx context.Context, plainText string) error {
+	ctx, cancel := withQueryTimeout(ctx, pts.queryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE tokens SET last_used_at = NOW()
+		WHERE hash = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+	`
+	_, err := pts.db.ExecContext(ctx, query, tokens.Hash(plainText))
+	return err
+}
+
+// ListSessions returns the user's token families that still hold a usable token.
+func (pts *PostgresTokenStore) ListSessions(ctx context.Context, userID int64) ([]*Session, error) {
+	ctx, cancel := withQueryTimeout(ctx, pts.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT family_id,
+			(ARRAY_AGG(user_agent ORDER BY created_at DESC))[1],
+			MIN(created_at),
+			MAX(last_used_at),
+			MAX(expiry)
+		FROM tokens
+		WHERE user_id = $1 AND family_id IS NOT NULL
+		GROUP BY family_id
+		HAVING MAX(expiry) FILTER (WHERE rotated_at IS NULL) > NOW()
+		ORDER BY MAX(COALESCE(last_used_at, created_at)) DESC
+	`
+	rows, err := pts.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+	for rows.Next() {
+		var session Session
+		err := rows.Scan(&session.ID, &session.UserAgent, &session.CreatedAt, &session.LastUsedAt, &session.Expiry)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// DeleteToken revokes the session the token belongs to, or just the token
+// itself when it was issued without a family.
+func (pts *PostgresTokenStore) DeleteToken(ctx context.Context, plainText string) error {
+	ctx, cancel := withQueryTimeout(ctx, pts.queryTimeout)
+	defer cancel()
+
+	query := `
+		DELETE FROM tokens
+		WHERE hash = $1
+			OR family_id = (SELECT family_id FROM tokens WHERE hash = $1)
+	`
+	_, err := pts.db.ExecContext(ctx, query, tokens.Hash(plainText))
+	return err
+}
+
+// DeleteOtherSessions revokes every session of the user except the one keepPlainText belongs to.
+func (pts *PostgresTokenStore) DeleteOtherSessions(ctx context.Context, userID int64, keepPlainText string) error {
+	ctx, cancel := withQueryTimeout(ctx, pts.queryTimeout)
+	defer cancel()
+
+	query := `
+		DELETE FROM tokens
+		WHERE user_id = $1 AND scope IN ($2, $3)
+			AND hash <> $4
+			AND (family_id IS NULL OR family_id IS DISTINCT FROM (SELECT family_id FROM tokens WHERE hash = $4))
+	`
+	_, err := pts.db.ExecContext(ctx, query, userID, tokens.ScopeAuth, tokens.ScopeRefresh, tokens.Hash(keepPlainText))
+	return err
+}
+
+func (pts *PostgresTokenStore) DeleteTokenFamily(ctx context.Context, family string) error {
+	ctx, cancel := withQueryTimeout(ctx, pts.queryTimeout)
+	defer cancel()
+
+	query := `
+		DELETE FROM tokens
+		WHERE family_id = $1
+	`
+	_, err := pts.db.ExecContext(ctx, query, family)
+	return err
+}
+
+func (pts *PostgresTokenStore) DeleteAllTokensForUser(ctx context.Context, userID int64, scope string) error {
+	ctx, cancel := withQueryTimeout(ctx, pts.queryTimeout)
+	defer cancel()
+
 	query := `
 		DELETE FROM tokens
 		WHERE user_id = $1 AND scope = $2
 	`
-	_, err := pts.db.Exec(query, userID, scope)
+	_, err := pts.db.ExecContext(ctx, query, userID, scope)
 	return err
 }
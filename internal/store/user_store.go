@@ -1,13 +1,24 @@
 package store
 
 import (
+	"context"
 	"crypto/sha256"
 	"database/sql"
+	"errors"
 	"time"
 
+	"github.com/sachanritik1/go-lang/internal/units"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrDuplicateEmail    = errors.New("a user with this email address already exists")
+	ErrDuplicateUsername = errors.New("a user with this username already exists")
+	// ErrUnknownTimezone is returned for a time zone the database does not
+	// know, which would make every query bucketing by local date fail.
+	ErrUnknownTimezone = errors.New("unknown time zone")
+)
+
 type password struct {
 	plainText *string
 	hash      []byte
@@ -34,14 +45,30 @@ func (p *password) Matches(plainText string) (bool, error) {
 	return true, nil
 }
 
+// dummyPassword hashes no one's password at the cost Set uses.
+var dummyPassword = password{hash: []byte("$2a$12$jgw9NgxxQIdNAPLM4fRZCO8qoPrFtU.JxB26t15y5Ay2ziLPXSxgC")}
+
+// MatchesDummyPassword checks plainText against a fixed hash and always
+// fails. Logins naming no user call it so that they cost as much bcrypt work
+// as a wrong password and do not reveal which usernames exist.
+func MatchesDummyPassword(plainText string) bool {
+	_, _ = dummyPassword.Matches(plainText)
+	return false
+}
+
 type User struct {
-	ID           int       `json:"id"`
-	Username     string    `json:"username"`
-	Email        string    `json:"email"`
-	PasswordHash password  `json:"-"` // "-" to omit from JSON responses
-	Bio          string    `json:"bio"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID                int       `json:"id"`
+	Username          string    `json:"username"`
+	Email             string    `json:"email"`
+	PasswordHash      password  `json:"-"` // "-" to omit from JSON responses
+	Bio               string    `json:"bio"`
+	Activated         bool      `json:"activated"`
+	Units             string    `json:"units"`       // preferred unit system, units.Metric or units.Imperial
+	Timezone          string    `json:"timezone"`    // IANA zone name, used to bucket workouts by local date
+	BodyWeight        *float64  `json:"body_weight"` // kilograms inside the store; follows the latest body_weight measurement
+	ProfileBodyWeight *float64  `json:"-"`           // kilograms the user entered; UpdateUser writes it, BodyWeight falls back to it
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
 }
 
 var AnonymousUser = &User{}
@@ -51,85 +78,170 @@ func (u *User) IsAnonymous() bool {
 }
 
 type PostgresUserStore struct {
-	db *sql.DB
+	db           *sql.DB
+	queryTimeout time.Duration
 }
 
-func NewPostgresUserStore(db *sql.DB) *PostgresUserStore {
-	return &PostgresUserStore{db: db}
+func NewPostgresUserStore(db *sql.DB, queryTimeout time.Duration) *PostgresUserStore {
+	return &PostgresUserStore{db: db, queryTimeout: queryTimeout}
 }
 
 type UserStore interface {
-	CreateUser(user *User) error
-	GetUserByID(id int) (*User, error)
-	GetUserByUsername(username string) (*User, error)
-	UpdateUser(user *User) (*User, error)
-	DeleteUser(id int) error
-	GetUserTokens(scope, tokenPlainText string) (*User, error)
+	CreateUser(ctx context.Context, user *User) error
+	GetUserByID(ctx context.Context, id int) (*User, error)
+	GetUserByUsername(ctx context.Context, username string) (*User, error)
+	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	UpdateUser(ctx context.Context, user *User) (*User, error)
+	DeleteUser(ctx context.Context, id int) error
+	GetUserTokens(ctx context.Context, scope, tokenPlainText string) (*User, error)
 }
 
-func (s *PostgresUserStore) CreateUser(user *User) error {
+// userColumns lists the columns scanUser expects, qualified for queries that alias users as u.
+const userColumns = `u.id, u.username, u.email, u.password_hash, u.bio, u.activated, u.units, u.timezone, u.body_weight, u.profile_body_weight, u.created_at, u.updated_at`
+
+func scanUser(row *sql.Row) (*User, error) {
+	user := &User{}
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash.hash, &user.Bio, &user.Activated, &user.Units, &user.Timezone, &user.BodyWeight, &user.ProfileBodyWeight, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *PostgresUserStore) CreateUser(ctx context.Context, user *User) error {
+	ctx, cancel := withQueryTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	if user.Units == "" {
+		user.Units = units.Metric
+	}
+	if user.Timezone == "" {
+		user.Timezone = "UTC"
+	}
+
 	query := `
-		INSERT INTO users (username, email, password_hash, bio)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO users (username, email, password_hash, bio, activated, units, timezone)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id, created_at, updated_at
 		`
-	err := s.db.QueryRow(query, user.Username, user.Email, user.PasswordHash.hash, user.Bio).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
-	if err != nil {
+	err := s.db.QueryRowContext(ctx, query, user.Username, user.Email, user.PasswordHash.hash, user.Bio, user.Activated, user.Units, user.Timezone).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	switch {
+	case isUniqueViolation(err, "users_email_key"):
+		return ErrDuplicateEmail
+	case isUniqueViolation(err, "users_username_key"):
+		return ErrDuplicateUsername
+	case err != nil:
 		return err
 	}
 	return nil
 }
 
-func (store *PostgresUserStore) GetUserByID(id int) (*User, error) {
-	query := `SELECT id, username, email, password_hash, bio, created_at, updated_at FROM users WHERE id = $1`
-	user := &User{}
-	err := store.db.QueryRow(query, id).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash.hash, &user.Bio, &user.CreatedAt, &user.UpdatedAt)
+func (store *PostgresUserStore) GetUserByID(ctx context.Context, id int) (*User, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	query := `SELECT ` + userColumns + ` FROM users u WHERE u.id = $1`
+	return scanUser(store.db.QueryRowContext(ctx, query, id))
+}
+
+func (store *PostgresUserStore) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	query := `SELECT ` + userColumns + ` FROM users u WHERE u.username = $1`
+	return scanUser(store.db.QueryRowContext(ctx, query, username))
+}
+
+func (store *PostgresUserStore) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	query := `SELECT ` + userColumns + ` FROM users u WHERE u.email = $1`
+	return scanUser(store.db.QueryRowContext(ctx, query, email))
+}
+
+func (store *PostgresUserStore) UpdateUser(ctx context.Context, user *User) (*User, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	tx, err := store.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
-	return user, nil
-}
+	defer tx.Rollback()
 
-func (store *PostgresUserStore) GetUserByUsername(username string) (*User, error) {
-	query := `SELECT id, username, email, password_hash, bio, created_at, updated_at FROM users WHERE username = $1`
-	user := &User{}
-	err := store.db.QueryRow(query, username).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash.hash, &user.Bio, &user.CreatedAt, &user.UpdatedAt)
+	var previousTimezone string
+	err = tx.QueryRowContext(ctx, `SELECT timezone FROM users WHERE id = $1 FOR UPDATE`, user.ID).Scan(&previousTimezone)
 	if err != nil {
 		return nil, err
 	}
-	return user, nil
-}
 
-func (store *PostgresUserStore) UpdateUser(user *User) (*User, error) {
-	query := `UPDATE users SET email = $1, password_hash = $2, bio = $3, updated_at = NOW() WHERE id = $4 RETURNING updated_at`
-	err := store.db.QueryRow(query, user.Email, user.PasswordHash, user.Bio, user.ID).Scan(&user.UpdatedAt)
+	if user.Timezone != previousTimezone {
+		var known bool
+		err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM pg_timezone_names WHERE name = $1)`, user.Timezone).Scan(&known)
+		if err != nil {
+			return nil, err
+		}
+		if !known {
+			return nil, ErrUnknownTimezone
+		}
+	}
+
+	query := `UPDATE users SET email = $1, password_hash = $2, bio = $3, activated = $4, units = $5, timezone = $6, profile_body_weight = $7, updated_at = NOW() WHERE id = $8 RETURNING updated_at`
+	err = tx.QueryRowContext(ctx, query, user.Email, user.PasswordHash.hash, user.Bio, user.Activated, user.Units, user.Timezone, user.ProfileBodyWeight, user.ID).Scan(&user.UpdatedAt)
+	if isUniqueViolation(err, "users_email_key") {
+		return nil, ErrDuplicateEmail
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// body_weight is never written directly: it follows the latest reading and
+	// only falls back to the profile weight without one
+	err = syncBodyWeight(ctx, tx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.QueryRowContext(ctx, `SELECT body_weight FROM users WHERE id = $1`, user.ID).Scan(&user.BodyWeight)
+	if err != nil {
+		return nil, err
+	}
+
+	// workouts fall on other days in the new timezone
+	if user.Timezone != previousTimezone {
+		err = rebuildWorkoutDays(ctx, tx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
 	if err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
-func (store *PostgresUserStore) DeleteUser(id int) error {
+func (store *PostgresUserStore) DeleteUser(ctx context.Context, id int) error {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
 	query := `DELETE FROM users WHERE id = $1`
-	_, err := store.db.Exec(query, id)
+	_, err := store.db.ExecContext(ctx, query, id)
 	return err
 }
 
-func (store *PostgresUserStore) GetUserTokens(scope, tokenPlainText string) (*User, error) {
+func (store *PostgresUserStore) GetUserTokens(ctx context.Context, scope, tokenPlainText string) (*User, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
 	tokenHash := sha256.Sum256([]byte(tokenPlainText))
 	hashBytes := tokenHash[:]
 	query := `
-		SELECT u.id, u.username, u.email, u.password_hash, u.bio, u.created_at, u.updated_at
+		SELECT ` + userColumns + `
 		FROM users u
 		INNER JOIN tokens t ON u.id = t.user_id
 		WHERE t.scope = $1 AND t.hash = $2 AND t.expiry > $3
 	`
-	user := &User{
-		PasswordHash: password{},
-	}
-	err := store.db.QueryRow(query, scope, hashBytes, time.Now()).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash.hash, &user.Bio, &user.CreatedAt, &user.UpdatedAt)
-	if err != nil {
-		return nil, err
-	}
-	return user, nil
+	return scanUser(store.db.QueryRowContext(ctx, query, scope, hashBytes, time.Now()))
 }
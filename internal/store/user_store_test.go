@@ -0,0 +1,29 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateUserRejectsUnknownTimezone(t *testing.T) {
+	db := SetupTestDB(t)
+	defer db.Close()
+
+	userStore := NewPostgresUserStore(db, 5*time.Second)
+	user := createTestUser(t, db)
+
+	user.Timezone = "Mars/Olympus_Mons"
+	_, err := userStore.UpdateUser(context.Background(), user)
+	assert.ErrorIs(t, err, ErrUnknownTimezone)
+
+	user.Timezone = "Europe/Berlin"
+	_, err = userStore.UpdateUser(context.Background(), user)
+	require.NoError(t, err)
+	fetched, err := userStore.GetUserByID(context.Background(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Europe/Berlin", fetched.Timezone)
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/sachanritik1/go-lang/internal/calories"
+)
+
+// estimateCalories sets the calories of workout to an estimate from its
+// entries and its owner's body weight, and saves it.
+func estimateCalories(ctx context.Context, tx *sql.Tx, workout *Workout) error {
+	var bodyWeight *float64
+	err := tx.QueryRowContext(ctx, `SELECT body_weight FROM users WHERE id = $1`, workout.UserID).Scan(&bodyWeight)
+	if err != nil {
+		return err
+	}
+	weight := calories.DefaultBodyWeight
+	if bodyWeight != nil {
+		weight = *bodyWeight
+	}
+
+	workout.CaloriesBurned = calories.Estimate(weight, workout.DurationMinutes, workoutActivities(workout.Entries))
+	workout.CaloriesEstimated = true
+	query := `UPDATE workouts SET calories_burned = $1, calories_estimated = TRUE WHERE id = $2`
+	_, err = tx.ExecContext(ctx, query, workout.CaloriesBurned, workout.ID)
+	return err
+}
+
+// refreshEstimatedCalories estimates the calories of a workout again after
+// its entries or duration changed, unless they were entered.
+func refreshEstimatedCalories(ctx context.Context, tx *sql.Tx, workout *Workout) error {
+	query := `SELECT duration_minutes, calories_estimated FROM workouts WHERE id = $1`
+	err := tx.QueryRowContext(ctx, query, workout.ID).Scan(&workout.DurationMinutes, &workout.CaloriesEstimated)
+	if err != nil || !workout.CaloriesEstimated {
+		return err
+	}
+
+	err = loadWorkoutEntries(ctx, tx, workout)
+	if err != nil {
+		return err
+	}
+	return estimateCalories(ctx, tx, workout)
+}
+
+func workoutActivities(entries []WorkoutEntry) []calories.Activity {
+	activities := make([]calories.Activity, 0, len(entries))
+	for _, entry := range entries {
+		activity := calories.Activity{Exercise: entry.ExerciseName, RestTargetSeconds: entry.RestTargetSeconds}
+		for _, set := range entry.Sets {
+			activity.Sets = append(activity.Sets, calories.Set{
+				Reps:            set.Reps,
+				DurationSeconds: set.DurationSeconds,
+				StartedAt:       set.StartedAt,
+				CompletedAt:     set.CompletedAt,
+				RestSeconds:     set.RestSeconds,
+			})
+		}
+		activities = append(activities, activity)
+	}
+	return activities
+}
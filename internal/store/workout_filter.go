@@ -0,0 +1,120 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	SortByDate     = "date"
+	SortByDuration = "duration"
+	SortByCalories = "calories"
+)
+
+const (
+	DefaultWorkoutPageSize = 20
+	MaxWorkoutPageSize     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// workoutSortColumns maps a sort key to the SQL expression ordered on.
+var workoutSortColumns = map[string]string{
+	SortByDate:     "performed_at",
+	SortByDuration: "duration_minutes",
+	SortByCalories: "COALESCE(calories_burned, 0)",
+}
+
+func IsValidWorkoutSort(sort string) bool {
+	_, ok := workoutSortColumns[sort]
+	return ok
+}
+
+// WorkoutFilter narrows and orders ListWorkouts. Zero values mean "no filter".
+type WorkoutFilter struct {
+	From        *time.Time
+	To          *time.Time
+	Search      string
+	Exercise    string // catalog name or alias
+	ExerciseID  *int
+	MinDuration *int
+	MinCalories *int
+
+	Sort       string
+	Descending bool
+	Limit      int
+	// Cursor is the opaque NextCursor of a previous page.
+	Cursor string
+}
+
+// WorkoutPage is one page of ListWorkouts results.
+type WorkoutPage struct {
+	Workouts   []*Workout
+	NextCursor string
+}
+
+// workoutCursor records where a page ended: the sort key of the last row plus
+// its id as a tie-breaker, so paging stays stable while rows are inserted.
+type workoutCursor struct {
+	Sort       string     `json:"s"`
+	Descending bool       `json:"d"`
+	Time       *time.Time `json:"t,omitempty"`
+	Int        *int       `json:"i,omitempty"`
+	ID         int        `json:"id"`
+}
+
+func (c workoutCursor) encode() string {
+	js, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+func decodeWorkoutCursor(s string, filter WorkoutFilter) (*workoutCursor, error) {
+	js, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c workoutCursor
+	err = json.Unmarshal(js, &c)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	// a cursor is only meaningful for the ordering it was issued under
+	if c.Sort != filter.Sort || c.Descending != filter.Descending {
+		return nil, ErrInvalidCursor
+	}
+	if (c.Sort == SortByDate) != (c.Time != nil) || (c.Sort != SortByDate && c.Int == nil) {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+func (c workoutCursor) value() any {
+	if c.Time != nil {
+		return *c.Time
+	}
+	return *c.Int
+}
+
+func cursorAfter(w *Workout, filter WorkoutFilter) workoutCursor {
+	c := workoutCursor{Sort: filter.Sort, Descending: filter.Descending, ID: w.ID}
+	switch filter.Sort {
+	case SortByDate:
+		t := w.PerformedAt
+		c.Time = &t
+	case SortByDuration:
+		c.Int = &w.DurationMinutes
+	case SortByCalories:
+		c.Int = &w.CaloriesBurned
+	}
+	return c
+}
+
+// escapeLike makes s match literally inside an ILIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
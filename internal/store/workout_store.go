@@ -1,83 +1,238 @@
 package store
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sachanritik1/go-lang/internal/units"
 )
 
 type Workout struct {
-	ID              int            `json:"id"`
-	Title           string         `json:"title"`
-	Description     string         `json:"description"`
-	DurationMinutes int            `json:"duration_minutes"`
-	CaloriesBurned  int            `json:"calories_burned"`
-	Entries         []WorkoutEntry `json:"entries"`
-	UserID          int            `json:"user_id"`
+	ID              int    `json:"id"`
+	Title           string `json:"title"`
+	Description     string `json:"description"`
+	DurationMinutes int    `json:"duration_minutes"`
+	CaloriesBurned  int    `json:"calories_burned"`
+	// CaloriesEstimated is set when CaloriesBurned was estimated rather than
+	// entered; workouts saved without calories get an estimate.
+	CaloriesEstimated bool           `json:"calories_estimated"`
+	Entries           []WorkoutEntry `json:"entries"`
+	UserID            int            `json:"user_id"`
+	PerformedAt       time.Time      `json:"performed_at"` // when the session happened; defaults to when it was logged
+	// Status is in_progress while a live session is being logged set by set;
+	// StartedAt and FinishedAt bound such a session.
+	Status     string     `json:"status"`
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	// EnrollmentID and ProgramDayID link the workout to the scheduled program
+	// session it fulfils; both or neither are set.
+	EnrollmentID *int `json:"enrollment_id"`
+	ProgramDayID *int `json:"program_day_id"`
+	// Records are the personal records currently held by sets of this workout.
+	Records []*PersonalRecord `json:"records,omitempty"`
+	// Timing is derived from the sets whenever the entries are loaded.
+	Timing *WorkoutTiming `json:"timing,omitempty"`
+}
+
+const (
+	WorkoutInProgress = "in_progress"
+	WorkoutCompleted  = "completed"
+)
+
+var ErrWorkoutNotInProgress = errors.New("workout is not in progress")
+
+func IsValidWorkoutStatus(status string) bool {
+	return status == WorkoutInProgress || status == WorkoutCompleted
 }
 
 type WorkoutEntry struct {
+	ID           int          `json:"id"`
+	WorkoutID    int          `json:"workout_id"`
+	ExerciseID   int          `json:"exercise_id"`
+	ExerciseName string       `json:"exercise_name"` // copy of the catalog name; matched against it when ExerciseID is unset
+	Sets         []WorkoutSet `json:"sets"`
+	Notes        string       `json:"notes"`
+	OrderIndex   int          `json:"order_index"`
+	// RestTargetSeconds is the rest clients should time between sets.
+	RestTargetSeconds *int `json:"rest_target_seconds"`
+}
+
+const (
+	SetTypeWarmup  = "warmup"
+	SetTypeWorking = "working"
+	SetTypeDrop    = "drop"
+	SetTypeFailure = "failure"
+)
+
+func IsValidSetType(setType string) bool {
+	switch setType {
+	case SetTypeWarmup, SetTypeWorking, SetTypeDrop, SetTypeFailure:
+		return true
+	}
+	return false
+}
+
+// WorkoutSet is one set of an entry. Exactly one of Reps and DurationSeconds is set.
+// Inside the store Weight is always kilograms and Distance meters; WeightUnit and
+// DistanceUnit remember what the set was logged in. The api layer converts both
+// ways so clients see their own units.
+type WorkoutSet struct {
 	ID              int      `json:"id"`
-	WorkoutID       int      `json:"workout_id"`
-	ExerciseName    string   `json:"exercise_name"`
-	Sets            int      `json:"sets"`
+	EntryID         int      `json:"entry_id"`
+	SetNumber       int      `json:"set_number"` // 1-based position within the entry, assigned on save
+	SetType         string   `json:"set_type"`
 	Reps            *int     `json:"reps"` // pass by pointer to distinguish between zero and null
 	DurationSeconds *int     `json:"duration_seconds"`
 	Weight          *float64 `json:"weight"`
-	Notes           string   `json:"notes"`
-	OrderIndex      int      `json:"order_index"`
+	WeightUnit      string   `json:"weight_unit"`
+	Distance        *float64 `json:"distance"`
+	DistanceUnit    string   `json:"distance_unit"`
+	RPE             *float64 `json:"rpe"`
+	// StartedAt and CompletedAt time the set; RestSeconds is the rest taken
+	// after it, derived from the next set's start when not given.
+	StartedAt   *time.Time `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	RestSeconds *int       `json:"rest_seconds"`
 }
 
 type PostgresWorkoutStore struct {
-	db *sql.DB
+	db           *sql.DB
+	queryTimeout time.Duration
 }
 
-func NewPostgresWorkoutStore(db *sql.DB) *PostgresWorkoutStore {
-	return &PostgresWorkoutStore{db: db}
+func NewPostgresWorkoutStore(db *sql.DB, queryTimeout time.Duration) *PostgresWorkoutStore {
+	return &PostgresWorkoutStore{db: db, queryTimeout: queryTimeout}
 }
 
 type WorkoutStore interface {
-	CreateWorkout(workout *Workout) (*Workout, error)
-	GetWorkoutByID(id int) (*Workout, error)
-	UpdateWorkout(workout *Workout) (*Workout, error)
-	DeleteWorkout(id int) error
-	ListWorkouts(userID int) ([]*Workout, error)
-	GetWorkoutOwner(id int) (int, error)
+	CreateWorkout(ctx context.Context, workout *Workout) (*Workout, error)
+	GetWorkoutByID(ctx context.Context, id int) (*Workout, error)
+	UpdateWorkout(ctx context.Context, workout *Workout) (*Workout, error)
+	DeleteWorkout(ctx context.Context, id int) error
+	ListWorkouts(ctx context.Context, userID int, filter WorkoutFilter) (*WorkoutPage, error)
+	GetWorkoutOwner(ctx context.Context, id int) (int, error)
+	GetLatestExerciseSets(ctx context.Context, userID, exerciseID int) ([]WorkoutSet, error)
+	AddWorkoutEntry(ctx context.Context, workoutID int, entry *WorkoutEntry) error
+	UpdateWorkoutEntry(ctx context.Context, workoutID int, entry *WorkoutEntry) error
+	DeleteWorkoutEntry(ctx context.Context, workoutID, entryID int) error
+	GetWorkoutEntry(ctx context.Context, workoutID, entryID int) (*WorkoutEntry, error)
+	FinishWorkout(ctx context.Context, workoutID int, finishedAt time.Time) (*Workout, error)
 }
 
-func (store *PostgresWorkoutStore) CreateWorkout(workout *Workout) (*Workout, error) {
-	tx, err := store.db.Begin()
+func (store *PostgresWorkoutStore) CreateWorkout(ctx context.Context, workout *Workout) (*Workout, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	tx, err := store.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
+	err = checkScheduledSession(ctx, tx, workout)
+	if err != nil {
+		return nil, err
+	}
+
+	// a live session starts now unless told otherwise and happens when it starts
+	if workout.Status == "" {
+		workout.Status = WorkoutCompleted
+	}
+	if workout.Status == WorkoutInProgress && workout.StartedAt == nil {
+		startedAt := workout.PerformedAt
+		if startedAt.IsZero() {
+			startedAt = time.Now()
+		}
+		workout.StartedAt = &startedAt
+	}
+	if workout.PerformedAt.IsZero() && workout.StartedAt != nil {
+		workout.PerformedAt = *workout.StartedAt
+	}
+
+	workout.CaloriesEstimated = workout.CaloriesEstimated || workout.CaloriesBurned == 0
+
 	// Implementation goes here
-	query := `INSERT INTO workouts (user_id, title, description, duration_minutes, calories_burned) VALUES ($1, $2, $3, $4, $5) RETURNING id`
-	err = tx.QueryRow(query, workout.UserID, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned).Scan(&workout.ID)
+	query := `INSERT INTO workouts (user_id, title, description, duration_minutes, calories_burned, calories_estimated, performed_at, enrollment_id, program_day_id, status, started_at, finished_at)
+		VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7, NOW()), $8, $9, $10, $11, $12)
+		RETURNING id, performed_at, created_at, updated_at`
+	err = tx.QueryRowContext(ctx, query, workout.UserID, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned, workout.CaloriesEstimated, nullTime(workout.PerformedAt), workout.EnrollmentID, workout.ProgramDayID, workout.Status, workout.StartedAt, workout.FinishedAt).
+		Scan(&workout.ID, &workout.PerformedAt, &workout.CreatedAt, &workout.UpdatedAt)
+	if isUniqueViolation(err, "workouts_session_key") {
+		return nil, ErrSessionAlreadyLogged
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	for _, entry := range workout.Entries {
-		entryQuery := `INSERT INTO workout_entries (workout_id, exercise_name, sets, duration_seconds, reps, weight, notes, order_index) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
-		err = tx.QueryRow(entryQuery, workout.ID, entry.ExerciseName, entry.Sets, entry.DurationSeconds, entry.Reps, entry.Weight, entry.Notes, entry.OrderIndex).Scan(&entry.ID)
+	err = addWorkoutDay(ctx, tx, workout.UserID, workout.PerformedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range workout.Entries {
+		err = insertWorkoutEntry(ctx, tx, workout, &workout.Entries[i])
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	if workout.CaloriesEstimated {
+		err = estimateCalories(ctx, tx, workout)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = recomputePersonalRecords(ctx, tx, workout.UserID, entryExerciseIDs(nil, workout.Entries))
+	if err != nil {
+		return nil, err
+	}
+	workout.Records, err = queryRecords(ctx, tx, workoutRecordsQuery, workout.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		return nil, err
 	}
+	workout.Timing = computeWorkoutTiming(workout)
 
 	return workout, nil
 }
-func (store *PostgresWorkoutStore) GetWorkoutByID(id int) (*Workout, error) {
-	query := `SELECT id, title, description, duration_minutes, calories_burned FROM workouts WHERE id = $1`
-	row := store.db.QueryRow(query, id)
+
+const workoutRecordsQuery = `SELECT ` + recordColumns + `
+	FROM personal_records pr
+	JOIN exercises e ON e.id = pr.exercise_id
+	WHERE pr.workout_id = $1
+	ORDER BY e.name, pr.record_type, pr.weight NULLS FIRST`
+
+// entryExerciseIDs appends the exercises of entries missing from ids.
+func entryExerciseIDs(ids []int, entries []WorkoutEntry) []int {
+	for _, entry := range entries {
+		if !slices.Contains(ids, entry.ExerciseID) {
+			ids = append(ids, entry.ExerciseID)
+		}
+	}
+	return ids
+}
+
+func (store *PostgresWorkoutStore) GetWorkoutByID(ctx context.Context, id int) (*Workout, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	query := `SELECT id, user_id, title, COALESCE(description, ''), duration_minutes, COALESCE(calories_burned, 0), calories_estimated, performed_at, created_at, updated_at, enrollment_id, program_day_id, status, started_at, finished_at FROM workouts WHERE id = $1`
+	row := store.db.QueryRowContext(ctx, query, id)
 
 	var workout Workout
-	err := row.Scan(&workout.ID, &workout.Title, &workout.Description, &workout.DurationMinutes, &workout.CaloriesBurned)
+	err := row.Scan(&workout.ID, &workout.UserID, &workout.Title, &workout.Description, &workout.DurationMinutes, &workout.CaloriesBurned, &workout.CaloriesEstimated, &workout.PerformedAt, &workout.CreatedAt, &workout.UpdatedAt, &workout.EnrollmentID, &workout.ProgramDayID, &workout.Status, &workout.StartedAt, &workout.FinishedAt)
 	if err == sql.ErrNoRows {
 		return nil, sql.ErrNoRows
 	}
@@ -85,95 +240,295 @@ func (store *PostgresWorkoutStore) GetWorkoutByID(id int) (*Workout, error) {
 		return nil, err
 	}
 
-	entryQuery := `SELECT id, workout_id, exercise_name, sets, duration_seconds, reps, weight, notes, order_index FROM workout_entries WHERE workout_id = $1 ORDER BY order_index`
-	rows, err := store.db.Query(entryQuery, workout.ID)
+	err = loadWorkoutEntries(ctx, store.db, &workout)
 	if err != nil {
 		return nil, err
 	}
+
+	workout.Records, err = queryRecords(ctx, store.db, workoutRecordsQuery, workout.ID)
+	if err != nil {
+		return nil, err
+	}
+	workout.Timing = computeWorkoutTiming(&workout)
+
+	return &workout, nil
+}
+
+// loadWorkoutEntries reads the entries of workout with their sets.
+func loadWorkoutEntries(ctx context.Context, db queryer, workout *Workout) error {
+	workout.Entries = []WorkoutEntry{}
+	entryQuery := `SELECT id, workout_id, exercise_id, exercise_name, notes, order_index, rest_target_seconds FROM workout_entries WHERE workout_id = $1 ORDER BY order_index`
+	rows, err := db.QueryContext(ctx, entryQuery, workout.ID)
+	if err != nil {
+		return err
+	}
 	defer rows.Close()
 
+	entryIndex := map[int]int{}
 	for rows.Next() {
-		var entry WorkoutEntry
-		err := rows.Scan(&entry.ID, &entry.WorkoutID, &entry.ExerciseName, &entry.Sets, &entry.DurationSeconds, &entry.Reps, &entry.Weight, &entry.Notes, &entry.OrderIndex)
+		entry := WorkoutEntry{Sets: []WorkoutSet{}}
+		err := rows.Scan(&entry.ID, &entry.WorkoutID, &entry.ExerciseID, &entry.ExerciseName, &entry.Notes, &entry.OrderIndex, &entry.RestTargetSeconds)
 		if err != nil {
-			return nil, err
+			return err
 		}
+		entryIndex[entry.ID] = len(workout.Entries)
 		workout.Entries = append(workout.Entries, entry)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return err
 	}
 
-	return &workout, nil
+	setQuery := `SELECT ` + setColumns + `
+		FROM workout_sets ws
+		JOIN workout_entries we ON we.id = ws.entry_id
+		WHERE we.workout_id = $1
+		ORDER BY ws.entry_id, ws.set_number`
+	sets, err := querySets(ctx, db, setQuery, workout.ID)
+	if err != nil {
+		return err
+	}
+	for _, set := range sets {
+		entry := &workout.Entries[entryIndex[set.EntryID]]
+		entry.Sets = append(entry.Sets, set)
+	}
+	return nil
 }
 
-func (store *PostgresWorkoutStore) UpdateWorkout(workout *Workout) (*Workout, error) {
-	tx, err := store.db.Begin()
+func (store *PostgresWorkoutStore) UpdateWorkout(ctx context.Context, workout *Workout) (*Workout, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	tx, err := store.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	// Implementation goes here
+	err = checkScheduledSession(ctx, tx, workout)
+	if err != nil {
+		return nil, err
+	}
 
-	query := `UPDATE workouts SET title = $1, description = $2, duration_minutes = $3, calories_burned = $4 WHERE id = $5`
-	result, err := tx.Exec(query, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned, workout.ID)
+	workout.CaloriesEstimated = workout.CaloriesEstimated || workout.CaloriesBurned == 0
+
+	// the previous time decides which day the workout moves away from
+	var previousPerformedAt time.Time
+	err = tx.QueryRowContext(ctx, `SELECT performed_at FROM workouts WHERE id = $1 FOR UPDATE`, workout.ID).Scan(&previousPerformedAt)
 	if err != nil {
 		return nil, err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	// Implementation goes here
+
+	query := `UPDATE workouts
+		SET title = $1, description = $2, duration_minutes = $3, calories_burned = $4, calories_estimated = $5, performed_at = COALESCE($6, performed_at),
+			enrollment_id = $7, program_day_id = $8, updated_at = NOW()
+		WHERE id = $9
+		RETURNING user_id, performed_at, updated_at, status, started_at, finished_at`
+	err = tx.QueryRowContext(ctx, query, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned, workout.CaloriesEstimated, nullTime(workout.PerformedAt), workout.EnrollmentID, workout.ProgramDayID, workout.ID).
+		Scan(&workout.UserID, &workout.PerformedAt, &workout.UpdatedAt, &workout.Status, &workout.StartedAt, &workout.FinishedAt)
+	if isUniqueViolation(err, "workouts_session_key") {
+		return nil, ErrSessionAlreadyLogged
+	}
 	if err != nil {
 		return nil, err
 	}
-	if rowsAffected == 0 {
-		return nil, sql.ErrNoRows
+
+	if !previousPerformedAt.Equal(workout.PerformedAt) {
+		err = removeWorkoutDay(ctx, tx, workout.UserID, previousPerformedAt)
+		if err != nil {
+			return nil, err
+		}
+		err = addWorkoutDay(ctx, tx, workout.UserID, workout.PerformedAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// records of exercises dropped from the workout need recomputing too
+	exerciseIDs, err := workoutExerciseIDs(ctx, tx, workout.ID)
+	if err != nil {
+		return nil, err
 	}
 
 	// delete existing entries
 	deleteQuery := `DELETE FROM workout_entries WHERE workout_id = $1`
-	_, err = tx.Exec(deleteQuery, workout.ID)
+	_, err = tx.ExecContext(ctx, deleteQuery, workout.ID)
 	if err != nil {
 		return nil, err
 	}
 
 	// insert updated entries
 	for i := range workout.Entries {
-		entry := &workout.Entries[i] // pointer to actual element
-		entryQuery := `INSERT INTO workout_entries
-        (workout_id, exercise_name, sets, duration_seconds, reps, weight, notes, order_index)
-        VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING id`
-		var insertedID int
-		err = tx.QueryRow(entryQuery,
-			workout.ID,
-			entry.ExerciseName,
-			entry.Sets,
-			entry.DurationSeconds,
-			entry.Reps,
-			entry.Weight,
-			entry.Notes,
-			entry.OrderIndex,
-		).Scan(&insertedID)
+		err = insertWorkoutEntry(ctx, tx, workout, &workout.Entries[i])
 		if err != nil {
 			return nil, err
 		}
+	}
 
-		entry.ID = insertedID        // update real element
-		entry.WorkoutID = workout.ID // set foreign-key field in struct
+	if workout.CaloriesEstimated {
+		err = estimateCalories(ctx, tx, workout)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = recomputePersonalRecords(ctx, tx, workout.UserID, entryExerciseIDs(exerciseIDs, workout.Entries))
+	if err != nil {
+		return nil, err
+	}
+	workout.Records, err = queryRecords(ctx, tx, workoutRecordsQuery, workout.ID)
+	if err != nil {
+		return nil, err
 	}
 
 	err = tx.Commit()
 	if err != nil {
 		return nil, err
 	}
+	workout.Timing = computeWorkoutTiming(workout)
 
 	return workout, nil
 }
 
-func (store *PostgresWorkoutStore) DeleteWorkout(id int) error {
-	query := `DELETE FROM workouts WHERE id = $1`
-	_, err := store.db.Exec(query, id)
+// checkScheduledSession verifies that a workout linked to a scheduled session
+// links to a day of the program of one of its owner's enrollments.
+func checkScheduledSession(ctx context.Context, tx *sql.Tx, workout *Workout) error {
+	if workout.EnrollmentID == nil && workout.ProgramDayID == nil {
+		return nil
+	}
+	if workout.EnrollmentID == nil || workout.ProgramDayID == nil {
+		return ErrUnknownSession
+	}
+
+	var exists bool
+	query := `SELECT EXISTS (
+		SELECT 1 FROM program_enrollments pe
+		JOIN program_days d ON d.program_id = pe.program_id
+		WHERE pe.id = $1 AND d.id = $2 AND pe.user_id = $3
+	)`
+	err := tx.QueryRowContext(ctx, query, *workout.EnrollmentID, *workout.ProgramDayID, workout.UserID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrUnknownSession
+	}
+	return nil
+}
+
+// insertWorkoutEntry writes entry and its sets, numbering the sets in order.
+func insertWorkoutEntry(ctx context.Context, tx *sql.Tx, workout *Workout, entry *WorkoutEntry) error {
+	err := resolveExercise(ctx, tx, workout.UserID, &entry.ExerciseID, &entry.ExerciseName, entry.Sets)
+	if err != nil {
+		return err
+	}
+
+	entryQuery := `INSERT INTO workout_entries (workout_id, exercise_id, exercise_name, notes, order_index, rest_target_seconds) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	err = tx.QueryRowContext(ctx, entryQuery, workout.ID, entry.ExerciseID, entry.ExerciseName, entry.Notes, entry.OrderIndex, entry.RestTargetSeconds).Scan(&entry.ID)
+	if err != nil {
+		return err
+	}
+	entry.WorkoutID = workout.ID
+
+	if entry.Sets == nil {
+		entry.Sets = []WorkoutSet{}
+	}
+	return insertSets(ctx, tx, "workout_sets", entry.ID, entry.Sets)
+}
+
+const setColumns = `ws.id, ws.entry_id, ws.set_number, ws.set_type, ws.reps, ws.duration_seconds, ws.weight, ws.weight_unit, ws.distance, ws.distance_unit, ws.rpe,
+	ws.started_at, ws.completed_at, ws.rest_seconds`
+
+// templateSetColumns is setColumns for template_sets, which have no timing.
+const templateSetColumns = `ws.id, ws.entry_id, ws.set_number, ws.set_type, ws.reps, ws.duration_seconds, ws.weight, ws.weight_unit, ws.distance, ws.distance_unit, ws.rpe,
+	NULL::timestamptz, NULL::timestamptz, ws.rest_seconds`
+
+// querySets runs a query selecting setColumns from a set table aliased ws.
+func querySets(ctx context.Context, db queryer, query string, args ...any) ([]WorkoutSet, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sets := []WorkoutSet{}
+	for rows.Next() {
+		var set WorkoutSet
+		err := rows.Scan(&set.ID, &set.EntryID, &set.SetNumber, &set.SetType, &set.Reps, &set.DurationSeconds, &set.Weight, &set.WeightUnit, &set.Distance, &set.DistanceUnit, &set.RPE,
+			&set.StartedAt, &set.CompletedAt, &set.RestSeconds)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, set)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return sets, nil
+}
+
+// insertSets writes sets under entryID into table, numbering them in order.
+// Workout and template sets share the same shape, except that template sets
+// are never performed and so do not store when they were started or completed.
+func insertSets(ctx context.Context, tx *sql.Tx, table string, entryID int, sets []WorkoutSet) error {
+	timed := table != "template_sets"
+	for i := range sets {
+		set := &sets[i]
+		set.EntryID = entryID
+		set.SetNumber = i + 1
+		if set.SetType == "" {
+			set.SetType = SetTypeWorking
+		}
+		if set.WeightUnit == "" {
+			set.WeightUnit = units.Kilograms
+		}
+		if set.DistanceUnit == "" {
+			set.DistanceUnit = units.Kilometers
+		}
+		if !timed {
+			set.StartedAt, set.CompletedAt = nil, nil
+		} else if set.RestSeconds == nil && set.CompletedAt != nil && i+1 < len(sets) && sets[i+1].StartedAt != nil {
+			if rest := int(sets[i+1].StartedAt.Sub(*set.CompletedAt).Round(time.Second).Seconds()); rest >= 0 {
+				set.RestSeconds = &rest
+			}
+		}
+		setQuery := `INSERT INTO ` + table + ` (entry_id, set_number, set_type, reps, duration_seconds, weight, weight_unit, distance, distance_unit, rpe, rest_seconds)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id`
+		args := []any{set.EntryID, set.SetNumber, set.SetType, set.Reps, set.DurationSeconds, set.Weight, set.WeightUnit, set.Distance, set.DistanceUnit, set.RPE, set.RestSeconds}
+		if timed {
+			setQuery = `INSERT INTO ` + table + ` (entry_id, set_number, set_type, reps, duration_seconds, weight, weight_unit, distance, distance_unit, rpe, rest_seconds, started_at, completed_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id`
+			args = append(args, set.StartedAt, set.CompletedAt)
+		}
+		err := tx.QueryRowContext(ctx, setQuery, args...).Scan(&set.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (store *PostgresWorkoutStore) DeleteWorkout(ctx context.Context, id int) error {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	exerciseIDs, err := workoutExerciseIDs(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	query := `DELETE FROM workouts WHERE id = $1 RETURNING user_id, performed_at`
+	var userID int
+	var performedAt time.Time
+	err = tx.QueryRowContext(ctx, query, id).Scan(&userID, &performedAt)
 	if err == sql.ErrNoRows {
 		return sql.ErrNoRows
 	}
@@ -181,58 +536,389 @@ func (store *PostgresWorkoutStore) DeleteWorkout(id int) error {
 		return err
 	}
 
-	return nil
+	err = removeWorkoutDay(ctx, tx, userID, performedAt)
+	if err != nil {
+		return err
+	}
+
+	// later sets may have become records once this workout's are gone
+	err = recomputePersonalRecords(ctx, tx, userID, exerciseIDs)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (store *PostgresWorkoutStore) ListWorkouts(userID int) ([]*Workout, error) {
-	query := `SELECT id, title, description, duration_minutes, calories_burned FROM workouts WHERE user_id = $1`
-	rows, err := store.db.Query(query, userID)
+// ListWorkouts returns one page of the user's workouts matching filter,
+// ordered by filter.Sort with the workout id breaking ties.
+func (store *PostgresWorkoutStore) ListWorkouts(ctx context.Context, userID int, filter WorkoutFilter) (*WorkoutPage, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	if filter.Sort == "" {
+		filter.Sort = SortByDate
+	}
+	sortColumn, ok := workoutSortColumns[filter.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown workout sort %q", filter.Sort)
+	}
+	if filter.Limit <= 0 || filter.Limit > MaxWorkoutPageSize {
+		filter.Limit = DefaultWorkoutPageSize
+	}
+
+	args := []any{userID}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions := []string{"user_id = $1"}
+	if filter.From != nil {
+		conditions = append(conditions, "performed_at >= "+arg(*filter.From))
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "performed_at < "+arg(*filter.To))
+	}
+	if filter.Search != "" {
+		conditions = append(conditions, "title ILIKE "+arg("%"+escapeLike(filter.Search)+"%"))
+	}
+	if filter.MinDuration != nil {
+		conditions = append(conditions, "duration_minutes >= "+arg(*filter.MinDuration))
+	}
+	if filter.MinCalories != nil {
+		conditions = append(conditions, "calories_burned >= "+arg(*filter.MinCalories))
+	}
+	if filter.Exercise != "" {
+		name := arg(normalizeExerciseName(filter.Exercise))
+		conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM workout_entries we
+			JOIN exercises e ON e.id = we.exercise_id
+			WHERE we.workout_id = workouts.id AND (e.normalized_name = `+name+` OR `+name+` = ANY(e.aliases)))`)
+	}
+	if filter.ExerciseID != nil {
+		conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM workout_entries we
+			WHERE we.workout_id = workouts.id AND we.exercise_id = `+arg(*filter.ExerciseID)+`)`)
+	}
+
+	direction, comparison := "ASC", ">"
+	if filter.Descending {
+		direction, comparison = "DESC", "<"
+	}
+	if filter.Cursor != "" {
+		cursor, err := decodeWorkoutCursor(filter.Cursor, filter)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s (%s, %s)", sortColumn, comparison, arg(cursor.value()), arg(cursor.ID)))
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, user_id, title, COALESCE(description, ''), duration_minutes, COALESCE(calories_burned, 0), calories_estimated, performed_at, created_at, updated_at, enrollment_id, program_day_id, status, started_at, finished_at
+		FROM workouts
+		WHERE %s
+		ORDER BY %s %s, id %s
+		LIMIT %s`,
+		strings.Join(conditions, " AND "), sortColumn, direction, direction, arg(filter.Limit+1))
+
+	rows, err := store.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	workoutMap := make(map[int]*Workout)
-
+	page := &WorkoutPage{Workouts: []*Workout{}}
 	for rows.Next() {
 		var w Workout
 
 		err := rows.Scan(
 			&w.ID,
+			&w.UserID,
 			&w.Title,
 			&w.Description,
 			&w.DurationMinutes,
 			&w.CaloriesBurned,
+			&w.CaloriesEstimated,
+			&w.PerformedAt,
+			&w.CreatedAt,
+			&w.UpdatedAt,
+			&w.EnrollmentID,
+			&w.ProgramDayID,
+			&w.Status,
+			&w.StartedAt,
+			&w.FinishedAt,
 		)
 		if err != nil {
 			return nil, err
 		}
 
-		if _, exists := workoutMap[w.ID]; !exists {
-			w.Entries = []WorkoutEntry{}
-			workoutMap[w.ID] = &w
-		}
-
+		w.Entries = []WorkoutEntry{}
+		page.Workouts = append(page.Workouts, &w)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
-	var workouts []*Workout
-	for _, w := range workoutMap {
-		workouts = append(workouts, w)
+	// the extra row only signals that another page exists
+	if len(page.Workouts) > filter.Limit {
+		page.Workouts = page.Workouts[:filter.Limit]
+		page.NextCursor = cursorAfter(page.Workouts[filter.Limit-1], filter).encode()
 	}
 
-	return workouts, nil
+	return page, nil
 }
 
-func (store *PostgresWorkoutStore) GetWorkoutOwner(id int) (int, error) {
+func (store *PostgresWorkoutStore) GetWorkoutOwner(ctx context.Context, id int) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
 	query := `SELECT user_id FROM workouts WHERE id = $1`
 	var userID int
-	err := store.db.QueryRow(query, id).Scan(&userID)
+	err := store.db.QueryRowContext(ctx, query, id).Scan(&userID)
 	if err != nil {
 		return 0, err
 	}
 	return userID, nil
 }
+
+// GetLatestExerciseSets returns the sets of the most recent entry the user
+// logged for the exercise, or none if they never did.
+func (store *PostgresWorkoutStore) GetLatestExerciseSets(ctx context.Context, userID, exerciseID int) ([]WorkoutSet, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	query := `SELECT ` + setColumns + `
+		FROM workout_sets ws
+		WHERE ws.entry_id = (
+			SELECT we.id
+			FROM workout_entries we
+			JOIN workouts w ON w.id = we.workout_id
+			WHERE w.user_id = $1 AND we.exercise_id = $2
+			ORDER BY w.performed_at DESC, we.order_index DESC
+			LIMIT 1
+		)
+		ORDER BY ws.set_number`
+	return querySets(ctx, store.db, query, userID, exerciseID)
+}
+
+// touchWorkout bumps updated_at of a workout whose entries are about to change
+// and returns its owner. The row stays locked until tx ends, so concurrent
+// changes to one live session apply one at a time.
+func touchWorkout(ctx context.Context, tx *sql.Tx, workoutID int) (*Workout, error) {
+	workout := Workout{ID: workoutID}
+	query := `UPDATE workouts SET updated_at = NOW() WHERE id = $1 RETURNING user_id`
+	err := tx.QueryRowContext(ctx, query, workoutID).Scan(&workout.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return &workout, nil
+}
+
+// AddWorkoutEntry appends one entry with its sets to a workout. Without an
+// OrderIndex the entry goes after the existing ones.
+func (store *PostgresWorkoutStore) AddWorkoutEntry(ctx context.Context, workoutID int, entry *WorkoutEntry) error {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	workout, err := touchWorkout(ctx, tx, workoutID)
+	if err != nil {
+		return err
+	}
+
+	if entry.OrderIndex == 0 {
+		query := `SELECT COALESCE(MAX(order_index), 0) + 1 FROM workout_entries WHERE workout_id = $1`
+		err = tx.QueryRowContext(ctx, query, workoutID).Scan(&entry.OrderIndex)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = insertWorkoutEntry(ctx, tx, workout, entry)
+	if err != nil {
+		return err
+	}
+
+	err = recomputePersonalRecords(ctx, tx, workout.UserID, []int{entry.ExerciseID})
+	if err != nil {
+		return err
+	}
+
+	err = refreshEstimatedCalories(ctx, tx, workout)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (store *PostgresWorkoutStore) GetWorkoutEntry(ctx context.Context, workoutID, entryID int) (*WorkoutEntry, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	entry := WorkoutEntry{}
+	query := `SELECT id, workout_id, exercise_id, exercise_name, notes, order_index, rest_target_seconds FROM workout_entries WHERE id = $1 AND workout_id = $2`
+	err := store.db.QueryRowContext(ctx, query, entryID, workoutID).
+		Scan(&entry.ID, &entry.WorkoutID, &entry.ExerciseID, &entry.ExerciseName, &entry.Notes, &entry.OrderIndex, &entry.RestTargetSeconds)
+	if err != nil {
+		return nil, err
+	}
+
+	setQuery := `SELECT ` + setColumns + ` FROM workout_sets ws WHERE ws.entry_id = $1 ORDER BY ws.set_number`
+	entry.Sets, err = querySets(ctx, store.db, setQuery, entry.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// UpdateWorkoutEntry saves one entry of a workout and replaces its sets,
+// leaving the other entries alone. It returns sql.ErrNoRows if the entry is
+// not part of the workout.
+func (store *PostgresWorkoutStore) UpdateWorkoutEntry(ctx context.Context, workoutID int, entry *WorkoutEntry) error {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	workout, err := touchWorkout(ctx, tx, workoutID)
+	if err != nil {
+		return err
+	}
+
+	var previousExerciseID int
+	query := `SELECT exercise_id FROM workout_entries WHERE id = $1 AND workout_id = $2`
+	err = tx.QueryRowContext(ctx, query, entry.ID, workoutID).Scan(&previousExerciseID)
+	if err != nil {
+		return err
+	}
+
+	err = resolveExercise(ctx, tx, workout.UserID, &entry.ExerciseID, &entry.ExerciseName, entry.Sets)
+	if err != nil {
+		return err
+	}
+
+	update := `UPDATE workout_entries SET exercise_id = $1, exercise_name = $2, notes = $3, order_index = $4, rest_target_seconds = $5 WHERE id = $6`
+	_, err = tx.ExecContext(ctx, update, entry.ExerciseID, entry.ExerciseName, entry.Notes, entry.OrderIndex, entry.RestTargetSeconds, entry.ID)
+	if err != nil {
+		return err
+	}
+	entry.WorkoutID = workoutID
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM workout_sets WHERE entry_id = $1`, entry.ID)
+	if err != nil {
+		return err
+	}
+	if entry.Sets == nil {
+		entry.Sets = []WorkoutSet{}
+	}
+	err = insertSets(ctx, tx, "workout_sets", entry.ID, entry.Sets)
+	if err != nil {
+		return err
+	}
+
+	err = recomputePersonalRecords(ctx, tx, workout.UserID, entryExerciseIDs([]int{previousExerciseID}, []WorkoutEntry{*entry}))
+	if err != nil {
+		return err
+	}
+
+	err = refreshEstimatedCalories(ctx, tx, workout)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteWorkoutEntry removes one entry of a workout with its sets. It returns
+// sql.ErrNoRows if the entry is not part of the workout.
+func (store *PostgresWorkoutStore) DeleteWorkoutEntry(ctx context.Context, workoutID, entryID int) error {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	workout, err := touchWorkout(ctx, tx, workoutID)
+	if err != nil {
+		return err
+	}
+
+	var exerciseID int
+	query := `DELETE FROM workout_entries WHERE id = $1 AND workout_id = $2 RETURNING exercise_id`
+	err = tx.QueryRowContext(ctx, query, entryID, workoutID).Scan(&exerciseID)
+	if err != nil {
+		return err
+	}
+
+	err = recomputePersonalRecords(ctx, tx, workout.UserID, []int{exerciseID})
+	if err != nil {
+		return err
+	}
+
+	err = refreshEstimatedCalories(ctx, tx, workout)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// FinishWorkout completes a live session at finishedAt and sets its duration
+// from the time since it started. It fails with ErrWorkoutNotInProgress for
+// workouts that are already completed.
+func (store *PostgresWorkoutStore) FinishWorkout(ctx context.Context, workoutID int, finishedAt time.Time) (*Workout, error) {
+	ctx, cancel := withQueryTimeout(ctx, store.queryTimeout)
+	defer cancel()
+
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE workouts
+		SET status = 'completed', finished_at = $2::timestamptz,
+			duration_minutes = ROUND(EXTRACT(EPOCH FROM ($2::timestamptz - COALESCE(started_at, performed_at))) / 60)::int,
+			updated_at = NOW()
+		WHERE id = $1 AND status = 'in_progress'
+		RETURNING user_id`
+	workout := Workout{ID: workoutID}
+	err = tx.QueryRowContext(ctx, query, workoutID, finishedAt).Scan(&workout.UserID)
+	if err == sql.ErrNoRows {
+		if _, err := store.GetWorkoutOwner(ctx, workoutID); err != nil {
+			return nil, err
+		}
+		return nil, ErrWorkoutNotInProgress
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the estimate follows the duration the session turned out to have
+	err = refreshEstimatedCalories(ctx, tx, &workout)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return store.GetWorkoutByID(ctx, workoutID)
+}
@@ -1,8 +1,11 @@
 package store
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"testing"
+	"time"
 
 	_ "github.com/jackc/pgx/v4/stdlib"
 	"github.com/stretchr/testify/assert"
@@ -33,7 +36,7 @@ func TestCreateWorkout(t *testing.T) {
 	db := SetupTestDB(t)
 	defer db.Close()
 
-	store := NewPostgresWorkoutStore(db)
+	store := NewPostgresWorkoutStore(db, 5*time.Second)
 
 	tests := []struct {
 		name    string
@@ -50,11 +53,13 @@ func TestCreateWorkout(t *testing.T) {
 				Entries: []WorkoutEntry{
 					{
 						ExerciseName: "Bench press",
-						Sets:         3,
-						Reps:         IntPtr(10),
-						Weight:       FloatPtr(135.5),
-						Notes:        "warm up properly",
-						OrderIndex:   1,
+						Sets: []WorkoutSet{
+							{SetType: SetTypeWarmup, Reps: IntPtr(10), Weight: FloatPtr(95)},
+							{Reps: IntPtr(8), Weight: FloatPtr(135.5), RPE: FloatPtr(8)},
+							{SetType: SetTypeDrop, Reps: IntPtr(12), Weight: FloatPtr(115)},
+						},
+						Notes:      "warm up properly",
+						OrderIndex: 1,
 					},
 				},
 			},
@@ -70,19 +75,17 @@ func TestCreateWorkout(t *testing.T) {
 				Entries: []WorkoutEntry{
 					{
 						ExerciseName: "Plank",
-						Sets:         3,
-						Reps:         IntPtr(60),
+						Sets:         []WorkoutSet{{DurationSeconds: IntPtr(60)}},
 						Notes:        "keep form",
 						OrderIndex:   1,
 					},
 					{
-						ExerciseName:    "squats",
-						Sets:            4,
-						Reps:            IntPtr(12),
-						DurationSeconds: IntPtr(60),
-						Weight:          FloatPtr(185.0),
-						Notes:           "full depth",
-						OrderIndex:      2,
+						ExerciseName: "squats",
+						Sets: []WorkoutSet{
+							{Reps: IntPtr(12), DurationSeconds: IntPtr(60), Weight: FloatPtr(185.0)},
+						},
+						Notes:      "full depth",
+						OrderIndex: 2,
 					},
 				},
 			},
@@ -92,7 +95,7 @@ func TestCreateWorkout(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			createdWorkout, err := store.CreateWorkout(tt.workout)
+			createdWorkout, err := store.CreateWorkout(context.Background(), tt.workout)
 			if tt.wantErr {
 				assert.Error(t, err)
 				return
@@ -103,7 +106,7 @@ func TestCreateWorkout(t *testing.T) {
 			assert.Equal(t, tt.workout.Description, createdWorkout.Description)
 			assert.Equal(t, tt.workout.DurationMinutes, createdWorkout.DurationMinutes)
 
-			retrieved, err := store.GetWorkoutByID(createdWorkout.ID)
+			retrieved, err := store.GetWorkoutByID(context.Background(), createdWorkout.ID)
 			require.NoError(t, err)
 
 			assert.Equal(t, createdWorkout.ID, retrieved.ID)
@@ -111,7 +114,12 @@ func TestCreateWorkout(t *testing.T) {
 
 			for i := range retrieved.Entries {
 				assert.Equal(t, tt.workout.Entries[i].ExerciseName, retrieved.Entries[i].ExerciseName)
-				assert.Equal(t, tt.workout.Entries[i].Sets, retrieved.Entries[i].Sets)
+				require.Len(t, retrieved.Entries[i].Sets, len(tt.workout.Entries[i].Sets))
+				for j, set := range retrieved.Entries[i].Sets {
+					assert.Equal(t, j+1, set.SetNumber)
+					assert.Equal(t, tt.workout.Entries[i].Sets[j].SetType, set.SetType)
+					assert.Equal(t, tt.workout.Entries[i].Sets[j].Reps, set.Reps)
+				}
 				assert.Equal(t, tt.workout.Entries[i].OrderIndex, retrieved.Entries[i].OrderIndex)
 			}
 
@@ -119,6 +127,79 @@ func TestCreateWorkout(t *testing.T) {
 	}
 }
 
+func TestLiveWorkoutSession(t *testing.T) {
+	db := SetupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db, 5*time.Second)
+	user := createTestUser(t, db)
+	startedAt := time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC)
+
+	workout, err := store.CreateWorkout(context.Background(), &Workout{
+		UserID:    user.ID,
+		Title:     "evening session",
+		Status:    WorkoutInProgress,
+		StartedAt: &startedAt,
+	})
+	require.NoError(t, err)
+	assert.True(t, workout.PerformedAt.Equal(startedAt))
+
+	bench := WorkoutEntry{ExerciseName: "Bench Press", Sets: []WorkoutSet{{Reps: IntPtr(5), Weight: FloatPtr(80)}}}
+	require.NoError(t, store.AddWorkoutEntry(context.Background(), workout.ID, &bench))
+	assert.Equal(t, 1, bench.OrderIndex)
+	row := WorkoutEntry{ExerciseName: "Barbell Row", Sets: []WorkoutSet{{Reps: IntPtr(8)}}}
+	require.NoError(t, store.AddWorkoutEntry(context.Background(), workout.ID, &row))
+	assert.Equal(t, 2, row.OrderIndex)
+
+	bench.Sets = append(bench.Sets, WorkoutSet{Reps: IntPtr(5), Weight: FloatPtr(82.5)})
+	require.NoError(t, store.UpdateWorkoutEntry(context.Background(), workout.ID, &bench))
+	require.NoError(t, store.DeleteWorkoutEntry(context.Background(), workout.ID, row.ID))
+	assert.ErrorIs(t, store.DeleteWorkoutEntry(context.Background(), workout.ID, row.ID), sql.ErrNoRows)
+
+	finished, err := store.FinishWorkout(context.Background(), workout.ID, startedAt.Add(47*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, WorkoutCompleted, finished.Status)
+	assert.Equal(t, 47, finished.DurationMinutes)
+	require.Len(t, finished.Entries, 1)
+	assert.Len(t, finished.Entries[0].Sets, 2)
+	// 47 minutes of bench press at the default 70 kg
+	assert.True(t, finished.CaloriesEstimated)
+	assert.Equal(t, 288, finished.CaloriesBurned)
+
+	_, err = store.FinishWorkout(context.Background(), workout.ID, startedAt.Add(time.Hour))
+	assert.ErrorIs(t, err, ErrWorkoutNotInProgress)
+}
+
+func TestWorkoutCalories(t *testing.T) {
+	db := SetupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db, 5*time.Second)
+	user := createTestUser(t, db)
+	user.BodyWeight = FloatPtr(80)
+	_, err := NewPostgresUserStore(db, 5*time.Second).UpdateUser(context.Background(), user)
+	require.NoError(t, err)
+
+	entered, err := store.CreateWorkout(context.Background(), &Workout{UserID: user.ID, Title: "run", DurationMinutes: 30, CaloriesBurned: 350})
+	require.NoError(t, err)
+	assert.False(t, entered.CaloriesEstimated)
+	assert.Equal(t, 350, entered.CaloriesBurned)
+
+	estimated, err := store.CreateWorkout(context.Background(), &Workout{
+		UserID:  user.ID,
+		Title:   "run",
+		Entries: []WorkoutEntry{{ExerciseName: "Running", Sets: []WorkoutSet{{DurationSeconds: IntPtr(1800)}}}},
+	})
+	require.NoError(t, err)
+	assert.True(t, estimated.CaloriesEstimated)
+	assert.Equal(t, 412, estimated.CaloriesBurned)
+
+	fetched, err := store.GetWorkoutByID(context.Background(), estimated.ID)
+	require.NoError(t, err)
+	assert.True(t, fetched.CaloriesEstimated)
+	assert.Equal(t, 412, fetched.CaloriesBurned)
+}
+
 func IntPtr(i int) *int {
 	return &i
 }
@@ -126,3 +207,54 @@ func IntPtr(i int) *int {
 func FloatPtr(f float64) *float64 {
 	return &f
 }
+
+func createTestUser(t *testing.T, db *sql.DB) *User {
+	t.Helper()
+	userStore := NewPostgresUserStore(db, 5*time.Second)
+	name := fmt.Sprintf("u%d", time.Now().UnixNano()%1_000_000_000)
+	user := &User{Username: name, Email: name + "@example.com", Activated: true}
+	require.NoError(t, user.PasswordHash.Set("Sup3r-secret"))
+	require.NoError(t, userStore.CreateUser(context.Background(), user))
+	return user
+}
+
+func TestListWorkoutsPagination(t *testing.T) {
+	db := SetupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db, 5*time.Second)
+	user := createTestUser(t, db)
+
+	for i := 1; i <= 5; i++ {
+		_, err := store.CreateWorkout(context.Background(), &Workout{
+			UserID:          user.ID,
+			Title:           fmt.Sprintf("session %d", i),
+			DurationMinutes: i * 10,
+			CaloriesBurned:  i * 100,
+		})
+		require.NoError(t, err)
+	}
+
+	filter := WorkoutFilter{Sort: SortByDuration, Descending: true, Limit: 2}
+	var durations []int
+	for {
+		page, err := store.ListWorkouts(context.Background(), user.ID, filter)
+		require.NoError(t, err)
+		for _, w := range page.Workouts {
+			durations = append(durations, w.DurationMinutes)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+	assert.Equal(t, []int{50, 40, 30, 20, 10}, durations)
+
+	page, err := store.ListWorkouts(context.Background(), user.ID, WorkoutFilter{Search: "SESSION 3", MinCalories: IntPtr(100)})
+	require.NoError(t, err)
+	require.Len(t, page.Workouts, 1)
+	assert.Equal(t, "session 3", page.Workouts[0].Title)
+
+	_, err = store.ListWorkouts(context.Background(), user.ID, WorkoutFilter{Sort: SortByDate, Cursor: filter.Cursor})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
@@ -0,0 +1,72 @@
+package store
+
+import "time"
+
+// WorkoutTiming summarises how a workout's time was spent. Work is the time
+// under load: completed_at minus started_at of timed sets, or the logged
+// duration of timed exercises. Density is the non-warm-up volume moved per
+// minute of the session, in kilograms.
+type WorkoutTiming struct {
+	SessionSeconds     *int     `json:"session_seconds"`
+	WorkSeconds        int      `json:"work_seconds"`
+	RestSeconds        int      `json:"rest_seconds"`
+	AverageRestSeconds *float64 `json:"average_rest_seconds"`
+	Density            *float64 `json:"density"`
+	TimedSets          int      `json:"timed_sets"`
+}
+
+// computeWorkoutTiming derives the timing of a workout from its sets. The
+// session spans started_at to finished_at of a live workout, else the first
+// set start to the last set completion, else the logged duration.
+func computeWorkoutTiming(workout *Workout) *WorkoutTiming {
+	timing := &WorkoutTiming{}
+
+	var first, last *time.Time
+	var volume float64
+	restSets := 0
+	for _, entry := range workout.Entries {
+		for _, set := range entry.Sets {
+			switch {
+			case set.StartedAt != nil && set.CompletedAt != nil:
+				timing.WorkSeconds += int(set.CompletedAt.Sub(*set.StartedAt).Round(time.Second).Seconds())
+				timing.TimedSets++
+			case set.DurationSeconds != nil:
+				timing.WorkSeconds += *set.DurationSeconds
+			}
+			if set.StartedAt != nil && (first == nil || set.StartedAt.Before(*first)) {
+				first = set.StartedAt
+			}
+			if set.CompletedAt != nil && (last == nil || set.CompletedAt.After(*last)) {
+				last = set.CompletedAt
+			}
+			if set.RestSeconds != nil {
+				timing.RestSeconds += *set.RestSeconds
+				restSets++
+			}
+			if set.SetType != SetTypeWarmup && set.Reps != nil && set.Weight != nil {
+				volume += float64(*set.Reps) * *set.Weight
+			}
+		}
+	}
+
+	if restSets > 0 {
+		average := float64(timing.RestSeconds) / float64(restSets)
+		timing.AverageRestSeconds = &average
+	}
+
+	var session int
+	switch {
+	case workout.StartedAt != nil && workout.FinishedAt != nil:
+		session = int(workout.FinishedAt.Sub(*workout.StartedAt).Round(time.Second).Seconds())
+	case first != nil && last != nil && last.After(*first):
+		session = int(last.Sub(*first).Round(time.Second).Seconds())
+	default:
+		session = workout.DurationMinutes * 60
+	}
+	if session > 0 {
+		timing.SessionSeconds = &session
+		density := volume / (float64(session) / 60)
+		timing.Density = &density
+	}
+	return timing
+}
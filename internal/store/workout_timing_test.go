@@ -0,0 +1,44 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeWorkoutTiming(t *testing.T) {
+	at := func(minute, second int) *time.Time {
+		ts := time.Date(2025, 3, 1, 18, minute, second, 0, time.UTC)
+		return &ts
+	}
+
+	workout := &Workout{
+		DurationMinutes: 90,
+		Entries: []WorkoutEntry{
+			{Sets: []WorkoutSet{
+				{SetType: SetTypeWarmup, Reps: IntPtr(10), Weight: FloatPtr(40), StartedAt: at(0, 0), CompletedAt: at(0, 30), RestSeconds: IntPtr(90)},
+				{SetType: SetTypeWorking, Reps: IntPtr(5), Weight: FloatPtr(100), StartedAt: at(2, 0), CompletedAt: at(2, 40), RestSeconds: IntPtr(150)},
+			}},
+			{Sets: []WorkoutSet{
+				{SetType: SetTypeWorking, DurationSeconds: IntPtr(60), StartedAt: at(5, 0), CompletedAt: at(10, 0)},
+			}},
+		},
+	}
+
+	timing := computeWorkoutTiming(workout)
+	assert.Equal(t, 30+40+300, timing.WorkSeconds)
+	assert.Equal(t, 3, timing.TimedSets)
+	assert.Equal(t, 240, timing.RestSeconds)
+	require.NotNil(t, timing.AverageRestSeconds)
+	assert.InDelta(t, 120, *timing.AverageRestSeconds, 0.001)
+
+	// the sets span ten minutes, which wins over the logged duration
+	require.NotNil(t, timing.SessionSeconds)
+	assert.Equal(t, 600, *timing.SessionSeconds)
+	require.NotNil(t, timing.Density)
+	assert.InDelta(t, 50, *timing.Density, 0.001)
+
+	assert.Nil(t, computeWorkoutTiming(&Workout{}).SessionSeconds)
+}
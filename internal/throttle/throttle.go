@@ -0,0 +1,117 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Tracker counts failed login attempts per key (a username or a client IP)
+// and decides how long the key is locked out.
+type Tracker interface {
+	// Check returns how long the key must wait before its next attempt; zero means it may proceed.
+	Check(ctx context.Context, key string) (time.Duration, error)
+	// RecordFailure registers a failed attempt and returns the lockout it triggered, if any.
+	RecordFailure(ctx context.Context, key string) (time.Duration, error)
+	// Reset forgets the key's failures, typically after a successful login.
+	Reset(ctx context.Context, key string) error
+	// Prune drops state for keys that are neither locked nor inside the failure window.
+	Prune(ctx context.Context) error
+}
+
+type Policy struct {
+	// MaxAttempts failures are allowed within Window before lockouts start.
+	MaxAttempts int
+	// BaseLockout is the first lockout; each further failure doubles it up to MaxLockout.
+	BaseLockout time.Duration
+	MaxLockout  time.Duration
+	// Window is how long a failure is remembered after the most recent one.
+	Window time.Duration
+}
+
+// Lockout returns the lockout earned by the given number of consecutive failures.
+func (p Policy) Lockout(failures int) time.Duration {
+	over := failures - p.MaxAttempts
+	if over <= 0 {
+		return 0
+	}
+
+	lockout := p.BaseLockout
+	for i := 1; i < over; i++ {
+		lockout *= 2
+		if lockout >= p.MaxLockout {
+			return p.MaxLockout
+		}
+	}
+	return min(lockout, p.MaxLockout)
+}
+
+type entry struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// MemoryTracker keeps attempts in process memory, which suits a single instance.
+type MemoryTracker struct {
+	policy  Policy
+	now     func() time.Time
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+func NewMemoryTracker(policy Policy) *MemoryTracker {
+	return &MemoryTracker{policy: policy, now: time.Now, entries: make(map[string]*entry)}
+}
+
+func (t *MemoryTracker) Check(ctx context.Context, key string) (time.Duration, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.entries[key]
+	if !ok {
+		return 0, nil
+	}
+	return max(e.lockedUntil.Sub(t.now()), 0), nil
+}
+
+func (t *MemoryTracker) RecordFailure(ctx context.Context, key string) (time.Duration, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	e, ok := t.entries[key]
+	if !ok || now.Sub(e.lastFailure) > t.policy.Window {
+		e = &entry{}
+		t.entries[key] = e
+	}
+
+	e.failures++
+	e.lastFailure = now
+	lockout := t.policy.Lockout(e.failures)
+	if lockout > 0 {
+		e.lockedUntil = now.Add(lockout)
+	}
+	return lockout, nil
+}
+
+func (t *MemoryTracker) Reset(ctx context.Context, key string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.entries, key)
+	return nil
+}
+
+func (t *MemoryTracker) Prune(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	for key, e := range t.entries {
+		if now.Sub(e.lastFailure) > t.policy.Window && now.After(e.lockedUntil) {
+			delete(t.entries, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package throttle
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyLockout(t *testing.T) {
+	policy := Policy{MaxAttempts: 3, BaseLockout: time.Second, MaxLockout: 5 * time.Second, Window: time.Minute}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 1, want: 0},
+		{failures: 3, want: 0},
+		{failures: 4, want: time.Second},
+		{failures: 5, want: 2 * time.Second},
+		{failures: 6, want: 4 * time.Second},
+		{failures: 7, want: 5 * time.Second},
+		{failures: 100, want: 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, policy.Lockout(tt.failures), "failures=%d", tt.failures)
+	}
+}
+
+func TestMemoryTracker(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := NewMemoryTracker(Policy{MaxAttempts: 2, BaseLockout: time.Minute, MaxLockout: time.Hour, Window: 10 * time.Minute})
+	tracker.now = func() time.Time { return now }
+
+	for range 2 {
+		lockout, err := tracker.RecordFailure(ctx, "user:bob")
+		require.NoError(t, err)
+		assert.Zero(t, lockout)
+	}
+
+	lockout, err := tracker.RecordFailure(ctx, "user:bob")
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, lockout)
+
+	wait, err := tracker.Check(ctx, "user:bob")
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, wait)
+
+	wait, err = tracker.Check(ctx, "user:alice")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+
+	// failures outside the window start a new count
+	now = now.Add(11 * time.Minute)
+	lockout, err = tracker.RecordFailure(ctx, "user:bob")
+	require.NoError(t, err)
+	assert.Zero(t, lockout)
+
+	require.NoError(t, tracker.Reset(ctx, "user:bob"))
+	wait, err = tracker.Check(ctx, "user:bob")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+
+	_, err = tracker.RecordFailure(ctx, "ip:10.0.0.1")
+	require.NoError(t, err)
+	now = now.Add(time.Hour)
+	require.NoError(t, tracker.Prune(ctx))
+	assert.Empty(t, tracker.entries)
+}
@@ -1,14 +1,18 @@
 package tokens
 
 import (
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base32"
-	"math/rand"
+	"encoding/hex"
 	"time"
 )
 
 const (
-	ScopeAuth = "authentication"
+	ScopeAuth          = "authentication"
+	ScopeRefresh       = "refresh"
+	ScopePasswordReset = "password-reset"
+	ScopeActivation    = "activation"
 )
 
 type Token struct {
@@ -17,6 +21,10 @@ type Token struct {
 	UserID    int64     `json:"-"`
 	Expiry    time.Time `json:"expiry"`
 	Scope     string    `json:"-"`
+	// Family links every token issued from one login so a replayed refresh
+	// token can revoke the whole chain.
+	Family    string `json:"-"`
+	UserAgent string `json:"-"`
 }
 
 func GenerateToken(userID int64, ttl time.Duration, scope string) (*Token, error) {
@@ -33,7 +41,21 @@ func GenerateToken(userID int64, ttl time.Duration, scope string) (*Token, error
 	}
 
 	token.PlainText = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(emptyBytes)
-	hash := sha256.Sum256([]byte(token.PlainText))
-	token.Hash = hash[:]
+	token.Hash = Hash(token.PlainText)
 	return token, nil
 }
+
+// Hash returns the digest under which a plain text token is stored.
+func Hash(plainText string) []byte {
+	hash := sha256.Sum256([]byte(plainText))
+	return hash[:]
+}
+
+func NewFamilyID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,136 @@
+// Package units converts between the measurement units clients may log in and
+// the canonical units the store keeps: kilograms for weight, meters for distance
+// and length.
+package units
+
+import "math"
+
+// Unit systems a user can prefer.
+const (
+	Metric   = "metric"
+	Imperial = "imperial"
+)
+
+const (
+	Kilograms = "kg"
+	Pounds    = "lb"
+)
+
+const (
+	Meters     = "m"
+	Kilometers = "km"
+	Miles      = "mi"
+	// body measurements are taken in centimeters or inches
+	Centimeters = "cm"
+	Inches      = "in"
+)
+
+const poundsPerKilogram = 2.20462262185
+
+var metersPer = map[string]float64{
+	Meters:     1,
+	Kilometers: 1000,
+	Miles:      1609.344,
+}
+
+// lengthMetersPer holds the units body lengths may be given in; they are kept
+// apart from distances, which the database limits to m, km and mi.
+var lengthMetersPer = map[string]float64{
+	Meters:      1,
+	Centimeters: 0.01,
+	Inches:      0.0254,
+}
+
+func IsValidSystem(system string) bool {
+	return system == Metric || system == Imperial
+}
+
+func IsValidWeightUnit(unit string) bool {
+	return unit == Kilograms || unit == Pounds
+}
+
+func IsValidDistanceUnit(unit string) bool {
+	_, ok := metersPer[unit]
+	return ok
+}
+
+func IsValidLengthUnit(unit string) bool {
+	_, ok := lengthMetersPer[unit]
+	return ok
+}
+
+// WeightUnit is the weight unit used for system.
+func WeightUnit(system string) string {
+	if system == Imperial {
+		return Pounds
+	}
+	return Kilograms
+}
+
+// LengthUnit is the unit body measurements are shown in for system.
+func LengthUnit(system string) string {
+	if system == Imperial {
+		return Inches
+	}
+	return Centimeters
+}
+
+// DistanceUnit is the distance unit used for system.
+func DistanceUnit(system string) string {
+	if system == Imperial {
+		return Miles
+	}
+	return Kilometers
+}
+
+func ToKilograms(value float64, unit string) float64 {
+	if unit == Pounds {
+		return value / poundsPerKilogram
+	}
+	return value
+}
+
+func FromKilograms(kilograms float64, unit string) float64 {
+	if unit == Pounds {
+		return kilograms * poundsPerKilogram
+	}
+	return kilograms
+}
+
+// ToMeters converts value in unit to meters; unknown units are taken as meters.
+func ToMeters(value float64, unit string) float64 {
+	if factor, ok := metersPer[unit]; ok {
+		return value * factor
+	}
+	return value
+}
+
+func FromMeters(meters float64, unit string) float64 {
+	if factor, ok := metersPer[unit]; ok {
+		return meters / factor
+	}
+	return meters
+}
+
+// LengthToMeters converts a body length in unit to meters; unknown units are
+// taken as meters.
+func LengthToMeters(value float64, unit string) float64 {
+	if factor, ok := lengthMetersPer[unit]; ok {
+		return value * factor
+	}
+	return value
+}
+
+func LengthFromMeters(meters float64, unit string) float64 {
+	if factor, ok := lengthMetersPer[unit]; ok {
+		return meters / factor
+	}
+	return meters
+}
+
+// Round rounds value to the given number of decimal places, hiding the noise
+// that converting back and forth leaves behind.
+func Round(value float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(value*scale) / scale
+}
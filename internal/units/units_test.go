@@ -0,0 +1,39 @@
+package units
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWeightRoundTrip(t *testing.T) {
+	for _, pounds := range []float64{45, 135.5, 225, 402.5} {
+		kilograms := ToKilograms(pounds, Pounds)
+		assert.Equal(t, pounds, Round(FromKilograms(Round(kilograms, 4), Pounds), 2))
+	}
+	assert.InDelta(t, 100, ToKilograms(220.462, Pounds), 0.001)
+	assert.Equal(t, 80.0, ToKilograms(80, Kilograms))
+}
+
+func TestDistanceConversion(t *testing.T) {
+	assert.Equal(t, 5000.0, ToMeters(5, Kilometers))
+	assert.InDelta(t, 1609.344, ToMeters(1, Miles), 1e-9)
+	assert.InDelta(t, 3.10686, FromMeters(5000, Miles), 1e-5)
+	assert.Equal(t, 400.0, FromMeters(400, Meters))
+	assert.InDelta(t, 81.28, LengthFromMeters(LengthToMeters(32, Inches), Centimeters), 1e-9)
+}
+
+func TestSystemUnits(t *testing.T) {
+	assert.Equal(t, Pounds, WeightUnit(Imperial))
+	assert.Equal(t, Kilograms, WeightUnit(Metric))
+	assert.Equal(t, Miles, DistanceUnit(Imperial))
+	assert.Equal(t, Kilometers, DistanceUnit(Metric))
+	assert.Equal(t, Inches, LengthUnit(Imperial))
+	assert.False(t, IsValidSystem("furlongs"))
+	assert.False(t, IsValidWeightUnit("st"))
+	assert.True(t, IsValidDistanceUnit(Meters))
+	// body lengths are no distances a set may be logged in
+	assert.False(t, IsValidDistanceUnit(Centimeters))
+	assert.True(t, IsValidLengthUnit(Inches))
+	assert.False(t, IsValidLengthUnit(Miles))
+}
@@ -2,9 +2,11 @@ package utils
 
 import (
 	"encoding/json"
-	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -24,14 +26,51 @@ func WriteJSON(w http.ResponseWriter, status int, data Envelope) error {
 	return err
 }
 
+// FieldErrors maps request fields to everything wrong with them.
+type FieldErrors map[string][]string
+
+func (fe FieldErrors) Add(field string, messages ...string) {
+	fe[field] = append(fe[field], messages...)
+}
+
+func WriteFieldErrors(w http.ResponseWriter, status int, fe FieldErrors) error {
+	return WriteJSON(w, status, Envelope{
+		"error":  "validation failed",
+		"fields": fe,
+	})
+}
+
 func ReadIDParam(r *http.Request) (int, error) {
-	idParam := chi.URLParam(r, "id")
-	if idParam == "" {
-		return 0, errors.New("invalid id parameter")
+	return ReadIntParam(r, "id")
+}
+
+// ReadIntParam reads the integer URL parameter name, e.g. "entryID" of
+// /workouts/{id}/entries/{entryID}.
+func ReadIntParam(r *http.Request, name string) (int, error) {
+	param := chi.URLParam(r, name)
+	if param == "" {
+		return 0, fmt.Errorf("invalid %s parameter", name)
 	}
-	id, err := strconv.Atoi(idParam)
+	id, err := strconv.Atoi(param)
 	if err != nil {
-		return 0, errors.New("invalid id parameter")
+		return 0, fmt.Errorf("invalid %s parameter", name)
 	}
 	return id, nil
 }
+
+// ClientIP returns the caller's address. X-Forwarded-For is only honoured when
+// trustForwardedFor is set, since clients can put anything in it.
+func ClientIP(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
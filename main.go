@@ -1,43 +1,42 @@
 package main
 
 import (
-	"flag"
 	"fmt"
-	"net/http"
-	"time"
+	"os"
+	_ "time/tzdata" // timezone validation must not depend on the host's zoneinfo
 
 	"github.com/sachanritik1/go-lang/internal/app"
+	"github.com/sachanritik1/go-lang/internal/config"
 	"github.com/sachanritik1/go-lang/internal/routes"
 )
 
 func main() {
 
-	app, err := app.NewApp()
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	app, err := app.NewApp(cfg)
 	if err != nil {
 		panic(err)
 	}
-	defer app.DB.Close()
 
 	app.Logger.Println("Application started successfully")
 
-	var port int
-	flag.IntVar(&port, "port", 8080, "Port to run the server on")
-	flag.Parse()
-
 	r := routes.SetupRoutes(app)
 
-	server := &http.Server{
-		Addr:         fmt.Sprintf(":%d", port),
-		Handler:      r,
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  time.Second * 10,
-		WriteTimeout: time.Second * 30,
-	}
-	app.Logger.Println("Server is running on port " + fmt.Sprintf("%d", port))
-	err = server.ListenAndServe()
+	err = app.Serve(r)
 
+	// Serve returns only after every handler has; background jobs had until the
+	// shutdown deadline and any still running fail on their cancelled context
+	closeErr := app.DB.Close()
 	if err != nil {
-		app.Logger.Fatalf("Error starting server: %v", err)
+		app.Logger.Fatalf("ERROR: server stopped: %v", err)
+	}
+	if closeErr != nil {
+		app.Logger.Fatalf("ERROR: closing database: %v", closeErr)
 	}
 
 }
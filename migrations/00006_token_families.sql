@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE tokens
+ADD COLUMN family_id TEXT,
+ADD COLUMN rotated_at TIMESTAMP(0) WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_tokens_family_id ON tokens(family_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_tokens_family_id;
+
+ALTER TABLE tokens
+DROP COLUMN rotated_at,
+DROP COLUMN family_id;
+-- +goose StatementEnd
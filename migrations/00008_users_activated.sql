@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+ADD COLUMN activated BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- accounts created before activation existed keep working
+UPDATE users SET activated = TRUE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+DROP COLUMN activated;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_workouts_user_created ON workouts(user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_workouts_user_duration ON workouts(user_id, duration_minutes, id);
+CREATE INDEX IF NOT EXISTS idx_workout_entries_workout_id ON workout_entries(workout_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_workout_entries_workout_id;
+DROP INDEX IF EXISTS idx_workouts_user_duration;
+DROP INDEX IF EXISTS idx_workouts_user_created;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE workouts
+ADD COLUMN performed_at TIMESTAMP WITH TIME ZONE;
+
+-- before this column existed the insertion time was the best record of when a session happened
+UPDATE workouts SET performed_at = COALESCE(created_at, CURRENT_TIMESTAMP);
+
+ALTER TABLE workouts
+ALTER COLUMN performed_at SET NOT NULL,
+ALTER COLUMN performed_at SET DEFAULT CURRENT_TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_workouts_user_performed ON workouts(user_id, performed_at, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_workouts_user_performed;
+
+ALTER TABLE workouts
+DROP COLUMN performed_at;
+-- +goose StatementEnd
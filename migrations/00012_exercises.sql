@@ -0,0 +1,168 @@
+-- +goose Up
+-- +goose StatementBegin
+-- lower-cased with runs of whitespace collapsed; mirrored by normalizeExerciseName in the store package
+CREATE OR REPLACE FUNCTION normalize_exercise_name(name TEXT) RETURNS TEXT AS $$
+    SELECT lower(btrim(regexp_replace(name, '\s+', ' ', 'g')))
+$$ LANGUAGE SQL IMMUTABLE;
+
+CREATE TABLE IF NOT EXISTS exercises (
+    id BIGSERIAL PRIMARY KEY,
+    -- NULL for the built-in catalog, otherwise the owner of a custom exercise
+    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    normalized_name VARCHAR(100) NOT NULL,
+    muscle_groups TEXT[] NOT NULL DEFAULT '{}',
+    equipment VARCHAR(50) NOT NULL DEFAULT '',
+    movement_type VARCHAR(10) NOT NULL DEFAULT 'reps',
+    aliases TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT valid_movement_type CHECK (movement_type IN ('reps', 'timed'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS exercises_owner_name_key ON exercises (COALESCE(user_id, 0), normalized_name);
+CREATE INDEX IF NOT EXISTS idx_exercises_aliases ON exercises USING GIN (aliases);
+
+INSERT INTO exercises (name, muscle_groups, equipment, movement_type, aliases) VALUES
+    ('Bench Press', ARRAY['chest', 'triceps', 'shoulders']::TEXT[], 'barbell', 'reps', ARRAY['barbell bench press', 'flat bench', 'flat bench press', 'bench']::TEXT[]),
+    ('Incline Bench Press', ARRAY['chest', 'shoulders', 'triceps']::TEXT[], 'barbell', 'reps', ARRAY['incline press', 'incline barbell press']::TEXT[]),
+    ('Dumbbell Bench Press', ARRAY['chest', 'triceps', 'shoulders']::TEXT[], 'dumbbell', 'reps', ARRAY['db bench press', 'dumbbell press']::TEXT[]),
+    ('Push-Up', ARRAY['chest', 'triceps', 'shoulders']::TEXT[], 'bodyweight', 'reps', ARRAY['push up', 'pushup', 'press up']::TEXT[]),
+    ('Chest Fly', ARRAY['chest']::TEXT[], 'dumbbell', 'reps', ARRAY['dumbbell fly', 'pec fly', 'flyes']::TEXT[]),
+    ('Dip', ARRAY['chest', 'triceps']::TEXT[], 'bodyweight', 'reps', ARRAY['dips', 'parallel bar dip']::TEXT[]),
+    ('Overhead Press', ARRAY['shoulders', 'triceps']::TEXT[], 'barbell', 'reps', ARRAY['ohp', 'military press', 'shoulder press', 'standing press']::TEXT[]),
+    ('Lateral Raise', ARRAY['shoulders']::TEXT[], 'dumbbell', 'reps', ARRAY['side raise', 'lateral raises']::TEXT[]),
+    ('Face Pull', ARRAY['shoulders', 'upper back']::TEXT[], 'cable', 'reps', ARRAY['face pulls']::TEXT[]),
+    ('Back Squat', ARRAY['quadriceps', 'glutes', 'hamstrings']::TEXT[], 'barbell', 'reps', ARRAY['squat', 'squats', 'barbell squat']::TEXT[]),
+    ('Front Squat', ARRAY['quadriceps', 'glutes']::TEXT[], 'barbell', 'reps', ARRAY['front squats']::TEXT[]),
+    ('Goblet Squat', ARRAY['quadriceps', 'glutes']::TEXT[], 'kettlebell', 'reps', ARRAY['goblet squats']::TEXT[]),
+    ('Leg Press', ARRAY['quadriceps', 'glutes']::TEXT[], 'machine', 'reps', ARRAY['leg presses']::TEXT[]),
+    ('Lunge', ARRAY['quadriceps', 'glutes']::TEXT[], 'dumbbell', 'reps', ARRAY['lunges', 'walking lunge']::TEXT[]),
+    ('Bulgarian Split Squat', ARRAY['quadriceps', 'glutes']::TEXT[], 'dumbbell', 'reps', ARRAY['split squat', 'rear foot elevated split squat']::TEXT[]),
+    ('Leg Extension', ARRAY['quadriceps']::TEXT[], 'machine', 'reps', ARRAY['leg extensions']::TEXT[]),
+    ('Leg Curl', ARRAY['hamstrings']::TEXT[], 'machine', 'reps', ARRAY['hamstring curl', 'leg curls']::TEXT[]),
+    ('Deadlift', ARRAY['hamstrings', 'glutes', 'lower back']::TEXT[], 'barbell', 'reps', ARRAY['conventional deadlift', 'deadlifts']::TEXT[]),
+    ('Romanian Deadlift', ARRAY['hamstrings', 'glutes']::TEXT[], 'barbell', 'reps', ARRAY['rdl', 'stiff leg deadlift']::TEXT[]),
+    ('Hip Thrust', ARRAY['glutes', 'hamstrings']::TEXT[], 'barbell', 'reps', ARRAY['barbell hip thrust', 'hip thrusts', 'glute bridge']::TEXT[]),
+    ('Calf Raise', ARRAY['calves']::TEXT[], 'machine', 'reps', ARRAY['calf raises', 'standing calf raise']::TEXT[]),
+    ('Pull-Up', ARRAY['lats', 'biceps', 'upper back']::TEXT[], 'bodyweight', 'reps', ARRAY['pull up', 'pullup', 'pull ups']::TEXT[]),
+    ('Chin-Up', ARRAY['lats', 'biceps']::TEXT[], 'bodyweight', 'reps', ARRAY['chin up', 'chinup', 'chin ups']::TEXT[]),
+    ('Lat Pulldown', ARRAY['lats', 'biceps']::TEXT[], 'cable', 'reps', ARRAY['pulldown', 'lat pull down']::TEXT[]),
+    ('Barbell Row', ARRAY['upper back', 'lats', 'biceps']::TEXT[], 'barbell', 'reps', ARRAY['bent over row', 'bent-over row', 'pendlay row']::TEXT[]),
+    ('Dumbbell Row', ARRAY['upper back', 'lats', 'biceps']::TEXT[], 'dumbbell', 'reps', ARRAY['one arm row', 'db row']::TEXT[]),
+    ('Seated Cable Row', ARRAY['upper back', 'lats']::TEXT[], 'cable', 'reps', ARRAY['cable row', 'seated row']::TEXT[]),
+    ('Barbell Curl', ARRAY['biceps']::TEXT[], 'barbell', 'reps', ARRAY['curl', 'bicep curl', 'biceps curl']::TEXT[]),
+    ('Dumbbell Curl', ARRAY['biceps']::TEXT[], 'dumbbell', 'reps', ARRAY['db curl', 'hammer curl']::TEXT[]),
+    ('Triceps Pushdown', ARRAY['triceps']::TEXT[], 'cable', 'reps', ARRAY['tricep pushdown', 'rope pushdown']::TEXT[]),
+    ('Skull Crusher', ARRAY['triceps']::TEXT[], 'barbell', 'reps', ARRAY['lying triceps extension', 'skullcrusher']::TEXT[]),
+    ('Plank', ARRAY['core']::TEXT[], 'bodyweight', 'timed', ARRAY['planks', 'front plank']::TEXT[]),
+    ('Side Plank', ARRAY['core']::TEXT[], 'bodyweight', 'timed', ARRAY['side planks']::TEXT[]),
+    ('Crunch', ARRAY['core']::TEXT[], 'bodyweight', 'reps', ARRAY['crunches', 'sit up', 'sit-up', 'situp']::TEXT[]),
+    ('Hanging Leg Raise', ARRAY['core']::TEXT[], 'bodyweight', 'reps', ARRAY['leg raise', 'leg raises']::TEXT[]),
+    ('Russian Twist', ARRAY['core']::TEXT[], 'bodyweight', 'reps', ARRAY['russian twists']::TEXT[]),
+    ('Kettlebell Swing', ARRAY['glutes', 'hamstrings', 'core']::TEXT[], 'kettlebell', 'reps', ARRAY['kb swing', 'swings']::TEXT[]),
+    ('Burpee', ARRAY['full body']::TEXT[], 'bodyweight', 'reps', ARRAY['burpees']::TEXT[]),
+    ('Running', ARRAY['legs', 'cardio']::TEXT[], 'none', 'timed', ARRAY['run', 'jog', 'jogging', 'treadmill']::TEXT[]),
+    ('Cycling', ARRAY['legs', 'cardio']::TEXT[], 'bike', 'timed', ARRAY['bike', 'biking', 'stationary bike', 'spin']::TEXT[]),
+    ('Rowing', ARRAY['full body', 'cardio']::TEXT[], 'rowing machine', 'timed', ARRAY['row', 'rower', 'erg']::TEXT[]),
+    ('Jump Rope', ARRAY['calves', 'cardio']::TEXT[], 'jump rope', 'timed', ARRAY['skipping', 'skipping rope']::TEXT[]),
+    ('Swimming', ARRAY['full body', 'cardio']::TEXT[], 'none', 'timed', ARRAY['swim']::TEXT[]),
+    ('Walking', ARRAY['legs', 'cardio']::TEXT[], 'none', 'timed', ARRAY['walk']::TEXT[]),
+    ('Stair Climber', ARRAY['legs', 'cardio']::TEXT[], 'machine', 'timed', ARRAY['stairmaster', 'stair climb']::TEXT[]),
+    ('Elliptical', ARRAY['legs', 'cardio']::TEXT[], 'machine', 'timed', ARRAY['cross trainer']::TEXT[]);
+
+UPDATE exercises SET
+    normalized_name = normalize_exercise_name(name),
+    aliases = ARRAY(SELECT normalize_exercise_name(a) FROM unnest(aliases) a);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE workout_entries
+ADD COLUMN exercise_id BIGINT REFERENCES exercises(id);
+
+-- entries without a workout have no owner to resolve a custom exercise for
+-- and are unreachable through the API
+DELETE FROM workout_entries WHERE workout_id IS NULL;
+
+-- every spelling a built-in exercise answers to, plus a punctuation-free form for loose matches
+CREATE TEMPORARY TABLE exercise_labels ON COMMIT DROP AS
+SELECT id, label, regexp_replace(label, '[^a-z0-9]+', '', 'g') AS compact
+FROM (
+    SELECT id, normalized_name AS label FROM exercises WHERE user_id IS NULL
+    UNION
+    SELECT id, unnest(aliases) FROM exercises WHERE user_id IS NULL
+) labels;
+
+-- 1. exact name or alias, ignoring case, spacing and punctuation
+UPDATE workout_entries we
+SET exercise_id = l.id
+FROM exercise_labels l
+WHERE we.exercise_id IS NULL
+    AND l.compact = regexp_replace(normalize_exercise_name(we.exercise_name), '[^a-z0-9]+', '', 'g');
+
+-- 2. closest trigram match for typos and word order, when it is convincing enough.
+-- pg_trgm can only be created by a role allowed to; without it the step is
+-- skipped and step 3 keeps those names as custom exercises.
+DO $$
+BEGIN
+    BEGIN
+        CREATE EXTENSION IF NOT EXISTS pg_trgm;
+    EXCEPTION WHEN insufficient_privilege OR undefined_file THEN
+        RAISE NOTICE 'pg_trgm is not available, skipping fuzzy exercise matching';
+    END;
+
+    IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm') THEN
+        UPDATE workout_entries we
+        SET exercise_id = best.id
+        FROM (
+            SELECT DISTINCT ON (we2.id) we2.id AS entry_id, l.id, similarity(l.label, normalize_exercise_name(we2.exercise_name)) AS score
+            FROM workout_entries we2
+            CROSS JOIN exercise_labels l
+            WHERE we2.exercise_id IS NULL
+            ORDER BY we2.id, score DESC
+        ) best
+        WHERE we.id = best.entry_id AND best.score >= 0.5;
+    END IF;
+END
+$$;
+
+-- 3. anything left becomes a custom exercise of the workout's owner
+INSERT INTO exercises (user_id, name, normalized_name, movement_type)
+SELECT DISTINCT ON (w.user_id, normalize_exercise_name(we.exercise_name))
+    w.user_id,
+    btrim(regexp_replace(we.exercise_name, '\s+', ' ', 'g')),
+    normalize_exercise_name(we.exercise_name),
+    CASE WHEN we.duration_seconds IS NOT NULL THEN 'timed' ELSE 'reps' END
+FROM workout_entries we
+JOIN workouts w ON w.id = we.workout_id
+WHERE we.exercise_id IS NULL
+ORDER BY w.user_id, normalize_exercise_name(we.exercise_name), we.id
+ON CONFLICT DO NOTHING;
+
+UPDATE workout_entries we
+SET exercise_id = e.id
+FROM workouts w, exercises e
+WHERE we.exercise_id IS NULL
+    AND w.id = we.workout_id
+    AND e.user_id = w.user_id
+    AND e.normalized_name = normalize_exercise_name(we.exercise_name);
+
+-- exercise_name stays as a display snapshot of the catalog name
+UPDATE workout_entries we
+SET exercise_name = e.name
+FROM exercises e
+WHERE e.id = we.exercise_id;
+
+ALTER TABLE workout_entries
+ALTER COLUMN exercise_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_workout_entries_exercise_id ON workout_entries(exercise_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workout_entries
+DROP COLUMN exercise_id;
+
+DROP TABLE exercises;
+DROP FUNCTION normalize_exercise_name(TEXT);
+-- +goose StatementEnd
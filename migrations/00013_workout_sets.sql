@@ -0,0 +1,67 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS workout_sets (
+    id BIGSERIAL PRIMARY KEY,
+    entry_id BIGINT NOT NULL REFERENCES workout_entries(id) ON DELETE CASCADE,
+    set_number INTEGER NOT NULL,
+    set_type VARCHAR(10) NOT NULL DEFAULT 'working',
+    reps INTEGER,
+    duration_seconds INTEGER,
+    weight DECIMAL(10, 2),
+    rpe DECIMAL(3, 1),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT workout_sets_entry_number_key UNIQUE (entry_id, set_number),
+    CONSTRAINT valid_set_type CHECK (set_type IN ('warmup', 'working', 'drop', 'failure')),
+    CONSTRAINT valid_rpe CHECK (rpe IS NULL OR rpe BETWEEN 1 AND 10),
+    CONSTRAINT valid_workout_set CHECK (
+        (reps IS NOT NULL OR duration_seconds IS NOT NULL) AND
+        (reps IS NULL OR duration_seconds IS NULL)
+    )
+);
+
+-- every old entry becomes that many identical working sets
+INSERT INTO workout_sets (entry_id, set_number, reps, duration_seconds, weight)
+SELECT we.id, n, we.reps, we.duration_seconds, we.weight
+FROM workout_entries we
+CROSS JOIN LATERAL generate_series(1, GREATEST(we.sets, 1)) AS n;
+
+ALTER TABLE workout_entries
+DROP CONSTRAINT valid_workout_entry,
+DROP COLUMN sets,
+DROP COLUMN reps,
+DROP COLUMN duration_seconds,
+DROP COLUMN weight;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workout_entries
+ADD COLUMN sets INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN reps INTEGER,
+ADD COLUMN duration_seconds INTEGER,
+ADD COLUMN weight DECIMAL(10, 2);
+
+-- the old shape only holds one set, so keep the count and the heaviest working set
+UPDATE workout_entries we
+SET sets = s.sets, reps = s.reps, duration_seconds = s.duration_seconds, weight = s.weight
+FROM (
+    SELECT DISTINCT ON (entry_id) entry_id,
+        COUNT(*) OVER (PARTITION BY entry_id) AS sets,
+        reps, duration_seconds, weight
+    FROM workout_sets
+    ORDER BY entry_id, set_type = 'working' DESC, weight DESC NULLS LAST, set_number
+) s
+WHERE s.entry_id = we.id;
+
+-- entries whose sets were all removed get a placeholder so the check holds
+UPDATE workout_entries SET reps = 0 WHERE reps IS NULL AND duration_seconds IS NULL;
+
+ALTER TABLE workout_entries
+ALTER COLUMN sets DROP DEFAULT,
+ADD CONSTRAINT valid_workout_entry CHECK (
+    (reps IS NOT NULL OR duration_seconds IS NOT NULL) AND
+    (reps IS NULL OR duration_seconds IS NULL)
+);
+
+DROP TABLE workout_sets;
+-- +goose StatementEnd
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+ADD COLUMN units VARCHAR(10) NOT NULL DEFAULT 'metric',
+ADD CONSTRAINT valid_units CHECK (units IN ('metric', 'imperial'));
+
+-- weights so far carried no unit and are taken to be kilograms; the extra
+-- precision lets pound values survive the round trip through kilograms
+ALTER TABLE workout_sets
+ALTER COLUMN weight TYPE DECIMAL(12, 4),
+ADD COLUMN weight_unit VARCHAR(2) NOT NULL DEFAULT 'kg',
+ADD COLUMN distance DECIMAL(12, 3),
+ADD COLUMN distance_unit VARCHAR(2) NOT NULL DEFAULT 'km',
+ADD CONSTRAINT valid_weight_unit CHECK (weight_unit IN ('kg', 'lb')),
+ADD CONSTRAINT valid_distance_unit CHECK (distance_unit IN ('m', 'km', 'mi')),
+ADD CONSTRAINT valid_distance CHECK (distance IS NULL OR distance >= 0);
+
+COMMENT ON COLUMN workout_sets.weight IS 'kilograms; weight_unit is the unit it was logged in';
+COMMENT ON COLUMN workout_sets.distance IS 'meters; distance_unit is the unit it was logged in';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workout_sets
+DROP COLUMN distance_unit,
+DROP COLUMN distance,
+DROP COLUMN weight_unit,
+ALTER COLUMN weight TYPE DECIMAL(10, 2);
+
+ALTER TABLE users
+DROP COLUMN units;
+-- +goose StatementEnd
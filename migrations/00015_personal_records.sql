@@ -0,0 +1,66 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Every row is a set that beat all earlier sets of the same exercise at the
+-- time it was performed, so the rows of one record form its history and the
+-- highest value is the current best. Rows are derived from workout_sets and
+-- rebuilt per exercise whenever a workout containing it changes.
+CREATE TABLE IF NOT EXISTS personal_records (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    exercise_id BIGINT NOT NULL REFERENCES exercises(id) ON DELETE CASCADE,
+    record_type VARCHAR(20) NOT NULL,
+    -- kilograms for max_weight and estimated_1rm, reps for max_reps, seconds for max_duration
+    value DECIMAL(12, 4) NOT NULL,
+    -- the weight a max_reps record was set at, in kilograms; NULL for bodyweight
+    weight DECIMAL(12, 4),
+    workout_id BIGINT NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+    set_id BIGINT NOT NULL REFERENCES workout_sets(id) ON DELETE CASCADE,
+    achieved_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    CONSTRAINT valid_record_type CHECK (record_type IN ('max_weight', 'max_reps', 'estimated_1rm', 'max_duration'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_personal_records_user_exercise ON personal_records(user_id, exercise_id, record_type, achieved_at);
+CREATE INDEX IF NOT EXISTS idx_personal_records_workout_id ON personal_records(workout_id);
+
+-- backfill from the sets logged so far; same rules as the store's recompute query
+WITH sets AS (
+    SELECT w.user_id, we.exercise_id, w.id AS workout_id, ws.id AS set_id, w.performed_at,
+        we.order_index, ws.set_number, ws.reps, ws.weight, ws.duration_seconds
+    FROM workout_sets ws
+    JOIN workout_entries we ON we.id = ws.entry_id
+    JOIN workouts w ON w.id = we.workout_id
+    WHERE ws.set_type <> 'warmup'
+),
+candidates AS (
+    SELECT *, 'max_weight' AS record_type, weight AS value, NULL::DECIMAL AS at_weight
+    FROM sets WHERE weight > 0 AND reps > 0
+    UNION ALL
+    SELECT *, 'max_reps', reps, weight
+    FROM sets WHERE reps > 0
+    UNION ALL
+    SELECT *, 'estimated_1rm',
+        CASE WHEN reps <= 10 THEN weight * 36 / (37 - reps) ELSE weight * (1 + reps / 30.0) END,
+        NULL
+    FROM sets WHERE weight > 0 AND reps > 0
+    UNION ALL
+    SELECT *, 'max_duration', duration_seconds, NULL
+    FROM sets WHERE duration_seconds > 0
+),
+ranked AS (
+    SELECT *, MAX(value) OVER (
+        PARTITION BY user_id, exercise_id, record_type, at_weight
+        ORDER BY performed_at, workout_id, order_index, set_number
+        ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING
+    ) AS previous_best
+    FROM candidates
+)
+INSERT INTO personal_records (user_id, exercise_id, record_type, value, weight, workout_id, set_id, achieved_at)
+SELECT user_id, exercise_id, record_type, value, at_weight, workout_id, set_id, performed_at
+FROM ranked
+WHERE previous_best IS NULL OR value > previous_best;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE personal_records;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- IANA zone name used to bucket a user's workouts into local days, weeks and months
+ALTER TABLE users
+ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+-- lets the stats aggregates read sets without touching the heap
+CREATE INDEX IF NOT EXISTS idx_workout_sets_entry_totals ON workout_sets(entry_id) INCLUDE (set_type, reps, weight);
+-- per-exercise progression walks from the exercise to its workouts
+CREATE INDEX IF NOT EXISTS idx_workout_entries_exercise_workout ON workout_entries(exercise_id, workout_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_workout_entries_exercise_workout;
+DROP INDEX IF EXISTS idx_workout_sets_entry_totals;
+
+ALTER TABLE users
+DROP COLUMN timezone;
+-- +goose StatementEnd
@@ -0,0 +1,57 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS workout_templates (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    title VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    duration_minutes INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_workout_templates_user_id ON workout_templates(user_id, title);
+
+CREATE TABLE IF NOT EXISTS template_entries (
+    id BIGSERIAL PRIMARY KEY,
+    template_id BIGINT NOT NULL REFERENCES workout_templates(id) ON DELETE CASCADE,
+    exercise_id BIGINT NOT NULL REFERENCES exercises(id),
+    exercise_name VARCHAR(100) NOT NULL,
+    notes TEXT NOT NULL DEFAULT '',
+    order_index INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_template_entries_template_id ON template_entries(template_id);
+CREATE INDEX IF NOT EXISTS idx_template_entries_exercise_id ON template_entries(exercise_id);
+
+-- planned sets: the same shape as workout_sets, with weights as targets in kilograms
+CREATE TABLE IF NOT EXISTS template_sets (
+    id BIGSERIAL PRIMARY KEY,
+    entry_id BIGINT NOT NULL REFERENCES template_entries(id) ON DELETE CASCADE,
+    set_number INTEGER NOT NULL,
+    set_type VARCHAR(10) NOT NULL DEFAULT 'working',
+    reps INTEGER,
+    duration_seconds INTEGER,
+    weight DECIMAL(12, 4),
+    weight_unit VARCHAR(2) NOT NULL DEFAULT 'kg',
+    distance DECIMAL(12, 3),
+    distance_unit VARCHAR(2) NOT NULL DEFAULT 'km',
+    rpe DECIMAL(3, 1),
+    CONSTRAINT template_sets_entry_number_key UNIQUE (entry_id, set_number),
+    CONSTRAINT valid_template_set_type CHECK (set_type IN ('warmup', 'working', 'drop', 'failure')),
+    CONSTRAINT valid_template_weight_unit CHECK (weight_unit IN ('kg', 'lb')),
+    CONSTRAINT valid_template_distance_unit CHECK (distance_unit IN ('m', 'km', 'mi')),
+    CONSTRAINT valid_template_rpe CHECK (rpe IS NULL OR rpe BETWEEN 1 AND 10),
+    CONSTRAINT valid_template_set CHECK (
+        (reps IS NOT NULL OR duration_seconds IS NOT NULL) AND
+        (reps IS NULL OR duration_seconds IS NULL)
+    )
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE template_sets;
+DROP TABLE template_entries;
+DROP TABLE workout_templates;
+-- +goose StatementEnd
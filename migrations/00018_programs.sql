@@ -0,0 +1,74 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS programs (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    weeks INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT valid_program_weeks CHECK (weeks BETWEEN 1 AND 52)
+);
+
+CREATE INDEX IF NOT EXISTS idx_programs_user_id ON programs(user_id, name);
+
+-- a training day of the program; it falls (week - 1) * 7 + (day - 1) days after the start date
+CREATE TABLE IF NOT EXISTS program_days (
+    id BIGSERIAL PRIMARY KEY,
+    program_id BIGINT NOT NULL REFERENCES programs(id) ON DELETE CASCADE,
+    week INTEGER NOT NULL,
+    day INTEGER NOT NULL,
+    template_id BIGINT NOT NULL REFERENCES workout_templates(id),
+    CONSTRAINT program_days_slot_key UNIQUE (program_id, week, day),
+    CONSTRAINT valid_program_day CHECK (week >= 1 AND day BETWEEN 1 AND 7)
+);
+
+CREATE INDEX IF NOT EXISTS idx_program_days_template_id ON program_days(template_id);
+
+-- base_weight is the training max of percentage rules and the starting weight
+-- of linear ones, in kilograms
+CREATE TABLE IF NOT EXISTS progression_rules (
+    id BIGSERIAL PRIMARY KEY,
+    program_id BIGINT NOT NULL REFERENCES programs(id) ON DELETE CASCADE,
+    exercise_id BIGINT NOT NULL REFERENCES exercises(id),
+    rule_type VARCHAR(20) NOT NULL,
+    base_weight DECIMAL(12, 4) NOT NULL,
+    percentages DOUBLE PRECISION[] NOT NULL DEFAULT '{}',
+    increment DECIMAL(12, 4) NOT NULL DEFAULT 0,
+    round_to DECIMAL(12, 4) NOT NULL DEFAULT 2.5,
+    CONSTRAINT progression_rules_exercise_key UNIQUE (program_id, exercise_id),
+    CONSTRAINT valid_rule_type CHECK (rule_type IN ('percentage', 'linear')),
+    CONSTRAINT valid_rule_percentages CHECK (rule_type <> 'percentage' OR cardinality(percentages) > 0)
+);
+
+CREATE TABLE IF NOT EXISTS program_enrollments (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    program_id BIGINT NOT NULL REFERENCES programs(id) ON DELETE CASCADE,
+    start_date DATE NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'active',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT valid_enrollment_status CHECK (status IN ('active', 'completed', 'cancelled'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_program_enrollments_user_id ON program_enrollments(user_id, status);
+CREATE UNIQUE INDEX IF NOT EXISTS program_enrollments_active_key ON program_enrollments(user_id, program_id) WHERE status = 'active';
+
+-- a workout fulfils at most one scheduled session and a session is fulfilled once
+ALTER TABLE workouts
+    ADD COLUMN enrollment_id BIGINT REFERENCES program_enrollments(id) ON DELETE SET NULL,
+    ADD COLUMN program_day_id BIGINT REFERENCES program_days(id) ON DELETE SET NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS workouts_session_key ON workouts(enrollment_id, program_day_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS workouts_session_key;
+ALTER TABLE workouts DROP COLUMN program_day_id, DROP COLUMN enrollment_id;
+DROP TABLE program_enrollments;
+DROP TABLE progression_rules;
+DROP TABLE program_days;
+DROP TABLE programs;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- workouts logged so far were written in one go and are complete
+ALTER TABLE workouts
+    ADD COLUMN status VARCHAR(12) NOT NULL DEFAULT 'completed',
+    ADD COLUMN started_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN finished_at TIMESTAMP WITH TIME ZONE,
+    ADD CONSTRAINT valid_workout_status CHECK (status IN ('in_progress', 'completed')),
+    ADD CONSTRAINT valid_workout_finish CHECK (finished_at IS NULL OR started_at IS NULL OR finished_at >= started_at);
+
+CREATE INDEX IF NOT EXISTS idx_workouts_in_progress ON workouts(user_id) WHERE status = 'in_progress';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_workouts_in_progress;
+ALTER TABLE workouts
+    DROP CONSTRAINT valid_workout_finish,
+    DROP CONSTRAINT valid_workout_status,
+    DROP COLUMN finished_at,
+    DROP COLUMN started_at,
+    DROP COLUMN status;
+-- +goose StatementEnd
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+-- rest_seconds is the rest taken after a set; on template sets it is the planned rest
+ALTER TABLE workout_sets
+    ADD COLUMN started_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN completed_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN rest_seconds INTEGER,
+    ADD CONSTRAINT valid_set_timing CHECK (completed_at IS NULL OR started_at IS NULL OR completed_at >= started_at),
+    ADD CONSTRAINT valid_set_rest CHECK (rest_seconds IS NULL OR rest_seconds >= 0);
+
+-- templates are never performed, so their sets only carry the planned rest
+ALTER TABLE template_sets
+    ADD COLUMN rest_seconds INTEGER,
+    ADD CONSTRAINT valid_template_set_rest CHECK (rest_seconds IS NULL OR rest_seconds >= 0);
+
+-- the rest clients should time between sets of the entry
+ALTER TABLE workout_entries
+    ADD COLUMN rest_target_seconds INTEGER,
+    ADD CONSTRAINT valid_entry_rest_target CHECK (rest_target_seconds IS NULL OR rest_target_seconds >= 0);
+
+ALTER TABLE template_entries
+    ADD COLUMN rest_target_seconds INTEGER,
+    ADD CONSTRAINT valid_template_entry_rest_target CHECK (rest_target_seconds IS NULL OR rest_target_seconds >= 0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE template_entries DROP COLUMN rest_target_seconds;
+ALTER TABLE workout_entries DROP COLUMN rest_target_seconds;
+ALTER TABLE template_sets DROP COLUMN rest_seconds;
+ALTER TABLE workout_sets DROP COLUMN rest_seconds, DROP COLUMN completed_at, DROP COLUMN started_at;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- body_weight is in kilograms; calorie estimates assume a default without it
+ALTER TABLE users
+    ADD COLUMN body_weight DECIMAL(6, 2),
+    ADD CONSTRAINT valid_body_weight CHECK (body_weight IS NULL OR body_weight > 0);
+
+-- calories_estimated marks calories_burned as computed rather than entered
+ALTER TABLE workouts
+    ADD COLUMN calories_estimated BOOLEAN NOT NULL DEFAULT FALSE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workouts DROP COLUMN calories_estimated;
+ALTER TABLE users DROP COLUMN body_weight;
+-- +goose StatementEnd
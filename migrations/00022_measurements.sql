@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- value is kilograms for body_weight, a percentage for body_fat and meters for
+-- circumferences
+CREATE TABLE IF NOT EXISTS measurements (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    value DECIMAL(10, 4) NOT NULL,
+    measured_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    notes TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT valid_measurement_kind CHECK (kind IN ('body_weight', 'body_fat', 'neck', 'chest', 'waist', 'hips', 'arm', 'forearm', 'thigh', 'calf')),
+    CONSTRAINT valid_measurement_value CHECK (value > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_measurements_user_kind ON measurements(user_id, kind, measured_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS measurements;
+-- +goose StatementEnd
@@ -0,0 +1,46 @@
+-- +goose Up
+-- +goose StatementBegin
+-- target_value is kilograms for strength, volume and body_weight goals and
+-- workouts per week for frequency goals. start_value is the best lift or body
+-- weight when the goal was set. Frequency goals run until target_date.
+CREATE TABLE IF NOT EXISTS goals (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    goal_type TEXT NOT NULL,
+    title VARCHAR(100) NOT NULL DEFAULT '',
+    exercise_id BIGINT REFERENCES exercises(id) ON DELETE CASCADE,
+    target_value DECIMAL(12, 4) NOT NULL,
+    start_value DECIMAL(12, 4),
+    start_date DATE NOT NULL,
+    target_date DATE,
+    status TEXT NOT NULL DEFAULT 'active',
+    achieved_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT valid_goal_type CHECK (goal_type IN ('strength', 'frequency', 'volume', 'body_weight')),
+    CONSTRAINT valid_goal_status CHECK (status IN ('active', 'achieved', 'missed', 'abandoned')),
+    CONSTRAINT valid_goal_target CHECK (target_value > 0),
+    CONSTRAINT valid_goal_dates CHECK (target_date IS NULL OR target_date >= start_date),
+    CONSTRAINT strength_goal_exercise CHECK (goal_type <> 'strength' OR exercise_id IS NOT NULL),
+    CONSTRAINT frequency_goal_target_date CHECK (goal_type <> 'frequency' OR target_date IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS idx_goals_user_id ON goals(user_id, status);
+
+-- every status a goal went through, with the value that triggered it
+CREATE TABLE IF NOT EXISTS goal_events (
+    id BIGSERIAL PRIMARY KEY,
+    goal_id BIGINT NOT NULL REFERENCES goals(id) ON DELETE CASCADE,
+    status TEXT NOT NULL,
+    value DECIMAL(12, 4),
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_goal_events_goal_id ON goal_events(goal_id, occurred_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS goal_events;
+DROP TABLE IF EXISTS goals;
+-- +goose StatementEnd
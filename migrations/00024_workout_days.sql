@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- workout_days counts each user's workouts per day in their timezone. The
+-- workout store keeps it current so streaks never scan the workouts table.
+CREATE TABLE IF NOT EXISTS workout_days (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    workouts INTEGER NOT NULL,
+    PRIMARY KEY (user_id, day),
+    CONSTRAINT valid_workout_day_count CHECK (workouts > 0)
+);
+
+INSERT INTO workout_days (user_id, day, workouts)
+SELECT w.user_id, (w.performed_at AT TIME ZONE u.timezone)::date, COUNT(*)
+FROM workouts w
+JOIN users u ON u.id = w.user_id
+GROUP BY 1, 2;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS workout_days;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- profile_body_weight is the weight the user entered themselves, in kilograms.
+-- body_weight follows the latest body_weight measurement and falls back to it
+-- once no reading is left. Users with readings already had theirs replaced.
+ALTER TABLE users
+    ADD COLUMN profile_body_weight DECIMAL(6, 2),
+    ADD CONSTRAINT valid_profile_body_weight CHECK (profile_body_weight IS NULL OR profile_body_weight > 0);
+
+UPDATE users u
+SET profile_body_weight = u.body_weight
+WHERE NOT EXISTS (
+    SELECT 1 FROM measurements m WHERE m.user_id = u.id AND m.kind = 'body_weight'
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN profile_body_weight;
+-- +goose StatementEnd
@@ -1,6 +1,13 @@
 import { NextResponse } from "next/server";
 
+import { goFetch } from "@/lib/go-api";
+
 export async function POST(req: Request) {
+  // Revoke the session server-side; clearing the cookie alone leaves the token valid.
+  await goFetch("/tokens/authentication", { method: "DELETE" }).catch(
+    () => undefined
+  );
+
   const response = NextResponse.redirect(new URL("/", req.url), {
     status: 303,
   });
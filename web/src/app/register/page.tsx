@@ -36,8 +36,15 @@ export default function RegisterPage() {
       });
       const data = await res.json().catch(() => ({}));
       if (!res.ok) {
+        const { error, fields } = data as {
+          error?: string;
+          fields?: Record<string, string[]>;
+        };
+        const fieldMessages = fields ? Object.values(fields).flat() : [];
         toast.error(
-          (data as { error?: string }).error ?? "Registration failed"
+          fieldMessages.length > 0
+            ? fieldMessages.join("\n")
+            : error ?? "Registration failed"
         );
         return;
       }
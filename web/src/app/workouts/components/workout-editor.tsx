@@ -80,14 +80,22 @@ export default function WorkoutEditor({ workout, canEdit }: Props) {
       return null;
     }
 
+    // the dialog logs uniform working sets; the API stores each one separately
     const newEntry: WorkoutEntry = {
       id: 0,
       workout_id: workout.id,
+      exercise_id: 0,
       exercise_name: exerciseName.trim(),
-      sets,
-      reps: normalizeNumber(reps),
-      duration_seconds: normalizeNumber(durationSeconds),
-      weight: normalizeNumber(weight),
+      sets: Array.from({ length: Math.max(sets, 1) }, (_, i) => ({
+        id: 0,
+        entry_id: 0,
+        set_number: i + 1,
+        set_type: "working" as const,
+        reps: normalizeNumber(reps),
+        duration_seconds: normalizeNumber(durationSeconds),
+        weight: normalizeNumber(weight),
+        rpe: null,
+      })),
       notes,
       order_index: nextOrderIndex,
     };
@@ -353,9 +361,13 @@ export default function WorkoutEditor({ workout, canEdit }: Props) {
                     <TableRow key={`${e.order_index}-${e.exercise_name}`}>
                       <TableCell>{e.order_index}</TableCell>
                       <TableCell>{e.exercise_name}</TableCell>
-                      <TableCell>{e.sets}</TableCell>
-                      <TableCell>{e.reps ?? "-"}</TableCell>
-                      <TableCell>{e.weight ?? "-"}</TableCell>
+                      <TableCell>{e.sets.length}</TableCell>
+                      <TableCell>
+                        {e.sets.map((s) => s.reps ?? "-").join(" / ")}
+                      </TableCell>
+                      <TableCell>
+                        {e.sets.map((s) => s.weight ?? "-").join(" / ")}
+                      </TableCell>
                     </TableRow>
                   ))}
               </TableBody>
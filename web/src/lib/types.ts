@@ -1,15 +1,62 @@
 export type ApiEnvelope<T extends Record<string, unknown>> = T;
 
-export type WorkoutEntry = {
+export type SetType = "warmup" | "working" | "drop" | "failure";
+
+export type Units = "metric" | "imperial";
+
+export type WorkoutSet = {
   id: number;
-  workout_id: number;
-  exercise_name: string;
-  sets: number;
+  entry_id: number;
+  set_number: number;
+  set_type: SetType;
   reps: number | null;
   duration_seconds: number | null;
   weight: number | null;
+  // omitted units fall back to the user's preferred units
+  weight_unit?: "kg" | "lb";
+  distance?: number | null;
+  distance_unit?: "m" | "km" | "mi";
+  rpe: number | null;
+  started_at?: string | null;
+  completed_at?: string | null;
+  // rest taken after the set
+  rest_seconds?: number | null;
+};
+
+export type WorkoutEntry = {
+  id: number;
+  workout_id: number;
+  exercise_id: number;
+  exercise_name: string;
+  sets: WorkoutSet[];
   notes: string;
   order_index: number;
+  rest_target_seconds?: number | null;
+};
+
+export type WorkoutTiming = {
+  session_seconds: number | null;
+  work_seconds: number;
+  rest_seconds: number;
+  average_rest_seconds: number | null;
+  // volume per minute of session, in the user's weight unit
+  density: number | null;
+  timed_sets: number;
+};
+
+export type PersonalRecord = {
+  id: number;
+  exercise_id: number;
+  exercise_name: string;
+  record_type: "max_weight" | "max_reps" | "estimated_1rm" | "max_duration";
+  value: number;
+  weight: number | null;
+  weight_unit?: "kg" | "lb";
+  // value over the user's latest body weight, for weight-based records
+  relative_strength?: number;
+  workout_id: number;
+  set_id: number;
+  achieved_at: string;
 };
 
 export type Workout = {
@@ -18,8 +65,21 @@ export type Workout = {
   description: string;
   duration_minutes: number;
   calories_burned: number;
+  // true when calories_burned was estimated rather than entered
+  calories_estimated: boolean;
   entries: WorkoutEntry[];
   user_id: number;
+  performed_at: string;
+  status: "in_progress" | "completed";
+  started_at: string | null;
+  finished_at: string | null;
+  created_at: string;
+  updated_at: string;
+  // the scheduled program session this workout fulfils
+  enrollment_id: number | null;
+  program_day_id: number | null;
+  records?: PersonalRecord[];
+  timing?: WorkoutTiming;
 };
 
 export type User = {
@@ -27,6 +87,105 @@ export type User = {
   username: string;
   email: string;
   bio: string;
+  activated: boolean;
+  units: Units;
+  timezone: string;
+  // in the user's weight unit
+  body_weight: number | null;
+  created_at: string;
+  updated_at: string;
+};
+
+export type MeasurementKind =
+  | "body_weight"
+  | "body_fat"
+  | "neck"
+  | "chest"
+  | "waist"
+  | "hips"
+  | "arm"
+  | "forearm"
+  | "thigh"
+  | "calf";
+
+export type Measurement = {
+  id: number;
+  user_id: number;
+  kind: MeasurementKind;
+  value: number;
+  unit: "kg" | "lb" | "%" | "cm" | "in";
+  measured_at: string;
+  notes: string;
+  created_at: string;
+  updated_at: string;
+};
+
+export type MeasurementPoint = {
+  measured_at: string;
+  value: number;
+  moving_average: number;
+};
+
+export type GoalType = "strength" | "frequency" | "volume" | "body_weight";
+
+export type GoalStatus = "active" | "achieved" | "missed" | "abandoned";
+
+export type GoalProgress = {
+  current_value: number | null;
+  target: number;
+  percent: number;
+  projected_date: string | null;
+};
+
+export type GoalEvent = {
+  status: GoalStatus;
+  value: number | null;
+  occurred_at: string;
+};
+
+export type Goal = {
+  id: number;
+  user_id: number;
+  goal_type: GoalType;
+  title: string;
+  exercise_id: number | null;
+  exercise_name?: string;
+  target_value: number;
+  start_value: number | null;
+  weight_unit?: "kg" | "lb";
+  start_date: string;
+  target_date: string | null;
+  status: GoalStatus;
+  achieved_at: string | null;
   created_at: string;
   updated_at: string;
+  progress?: GoalProgress;
+  events?: GoalEvent[];
+};
+
+export type Streak = {
+  length: number;
+  start: string | null;
+  end: string | null;
+};
+
+export type StreakSummary = {
+  current: Streak;
+  longest: Streak;
+};
+
+export type Consistency = {
+  training_days_30: number;
+  workouts_30: number;
+  percent_30: number;
+  weeks_on_target_12: number;
+  days_per_week_12: number;
+  last_training_day: string | null;
+  days_since_training: number | null;
+};
+
+export type Streaks = {
+  daily: StreakSummary;
+  weekly: StreakSummary;
+  consistency: Consistency;
 };